@@ -0,0 +1,80 @@
+// Package auth holds the pieces of user authentication that do not depend on
+// storage: password hashing, bearer tokens and the signed-in user carried in a
+// request context.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordLength is the longest password bcrypt takes into account.
+const MaxPasswordLength = 72
+
+// MinPasswordLength is the shortest password accepted for an account.
+const MinPasswordLength = 8
+
+type (
+	userKey     struct{}
+	operatorKey struct{}
+)
+
+// WithUser attaches the nickname of the signed-in user to ctx.
+func WithUser(ctx context.Context, nickname string) context.Context {
+	return context.WithValue(ctx, userKey{}, nickname)
+}
+
+// User returns the nickname attached to ctx, and false for anonymous requests.
+func User(ctx context.Context) (string, bool) {
+	nickname, ok := ctx.Value(userKey{}).(string)
+	return nickname, ok
+}
+
+// WithOperator marks ctx as carrying the admin token of the service operator.
+func WithOperator(ctx context.Context) context.Context {
+	return context.WithValue(ctx, operatorKey{}, true)
+}
+
+// Operator reports whether ctx carries the admin token.
+func Operator(ctx context.Context) bool {
+	operator, _ := ctx.Value(operatorKey{}).(bool)
+	return operator
+}
+
+// HashPassword returns the bcrypt hash stored for password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash. An empty hash, left
+// by accounts created without a password, matches nothing.
+func CheckPassword(hash string, password string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewToken returns a random 256-bit bearer token and the hash it is stored
+// under, so a leaked table does not leak usable tokens.
+func NewToken() (token string, hash string, err error) {
+	var raw [32]byte
+	if _, err = rand.Read(raw[:]); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(raw[:])
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash a bearer token is stored and looked up under.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
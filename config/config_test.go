@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forum.yaml")
+	file := `
+database:
+  url: postgres://forum:secret@db:5432/forum
+  max_connections: 20
+server:
+  listen_addr: ":8080"
+  read_timeout: 5s
+  route_timeouts:
+    GET /api/thread/:slug_or_id/posts: 30s
+`
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FORUM_LISTEN_ADDR", ":9090")
+	t.Setenv("FORUM_WRITE_TIMEOUT", "2s")
+	t.Setenv("FORUM_ADMIN_TOKEN", "hunter2")
+	t.Setenv("FORUM_AUTH_ANONYMOUS", "true")
+	t.Setenv("FORUM_PUBLIC_URL", "https://forum.example.com")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if config.Database.MaxConnections != 20 || config.Server.ReadTimeout.Duration != 5*time.Second {
+		t.Errorf("file values not applied: %+v", config)
+	}
+	if config.Server.ListenAddr != ":9090" || config.Server.WriteTimeout.Duration != 2*time.Second || config.Server.PublicURL != "https://forum.example.com" {
+		t.Errorf("env overrides not applied: %+v", config.Server)
+	}
+	if !config.Auth.Anonymous {
+		t.Errorf("FORUM_AUTH_ANONYMOUS not applied: %+v", config.Auth)
+	}
+	if config.Server.RouteTimeouts["GET /api/thread/:slug_or_id/posts"].Duration != 30*time.Second {
+		t.Errorf("route timeouts not applied: %+v", config.Server.RouteTimeouts)
+	}
+
+	masked := config.Masked()
+	if strings.Contains(masked, "secret") || strings.Contains(masked, "hunter2") || !strings.Contains(masked, "forum:xxxxx@db") {
+		t.Errorf("Masked() leaks or drops credentials:\n%s", masked)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forum.json")
+	if err := ioutil.WriteFile(path, []byte(`{"storage": "memory", "server": {"concurrency": 64}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if config.Storage != "memory" || config.Server.Concurrency != 64 {
+		t.Errorf("json values not applied: %+v", config)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(config *Config)
+	}{
+		{name: "unknown mode", mutate: func(config *Config) { config.Mode = "staging" }},
+		{name: "unknown log level", mutate: func(config *Config) { config.Log.Level = "verbose" }},
+		{name: "unknown status mode", mutate: func(config *Config) { config.Status.Mode = "approximate" }},
+		{name: "expiring tokens", mutate: func(config *Config) { config.Auth.TokenTTL.Duration = 0 }},
+		{name: "webhooks without attempts", mutate: func(config *Config) { config.Webhooks.MaxAttempts = 0 }},
+		{name: "unknown storage", mutate: func(config *Config) { config.Storage = "redis" }},
+		{name: "bad database url", mutate: func(config *Config) { config.Database.URL = "mysql://db" }},
+		{name: "tiny pool", mutate: func(config *Config) { config.Database.MaxConnections = 1 }},
+		{name: "empty listen addr", mutate: func(config *Config) { config.Server.ListenAddr = "" }},
+		{name: "public url without scheme", mutate: func(config *Config) { config.Server.PublicURL = "forum.example.com" }},
+		{name: "public url with path", mutate: func(config *Config) { config.Server.PublicURL = "https://example.com/forum" }},
+		{name: "negative timeout", mutate: func(config *Config) { config.Server.ReadTimeout.Duration = -time.Second }},
+		{name: "route without method", mutate: func(config *Config) {
+			config.Server.RouteTimeouts = map[string]Duration{"/api/service/status": {time.Second}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Default()
+			tt.mutate(config)
+			if err := config.Validate(); err == nil {
+				t.Errorf("Validate() accepted an invalid config")
+			}
+		})
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Validate() rejected defaults: %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"io"
+	"net/http"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+)
+
+// Admin guards the service endpoints. With a token every request must carry
+// "Authorization: Bearer <token>"; without one the endpoints are open only in
+// test mode. Clear is refused outside test mode whatever the token.
+type Admin struct {
+	token    []byte
+	testMode bool
+	audit    zerolog.Logger
+}
+
+func NewAdmin(token string, testMode bool, audit io.Writer) *Admin {
+	return &Admin{
+		token:    []byte(token),
+		testMode: testMode,
+		audit:    zerolog.New(audit).With().Timestamp().Str("log", "audit").Logger(),
+	}
+}
+
+// Protect rejects requests to handler that do not carry the admin token.
+func (admin *Admin) Protect(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if len(admin.token) == 0 {
+			if !admin.testMode {
+				admin.record(ctx, "denied: admin token is not configured")
+				writeJSON(ctx, http.StatusForbidden, models.ErrorMsg{Message: "admin token is not configured"})
+				return
+			}
+			operator(ctx, handler)
+			return
+		}
+
+		token, ok := bearerToken(ctx)
+		if !ok || subtle.ConstantTimeCompare(token, admin.token) != 1 {
+			admin.record(ctx, "denied: bad or missing admin token")
+			ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+			writeJSON(ctx, http.StatusUnauthorized, models.ErrorMsg{Message: "admin token required"})
+			return
+		}
+		operator(ctx, handler)
+	}
+}
+
+// operator runs handler as the service operator, which the policy lets do
+// anything.
+func operator(ctx *fasthttp.RequestCtx, handler fasthttp.RequestHandler) {
+	ctx.SetUserValue(contextKey, auth.WithOperator(requestContext(ctx)))
+	handler(ctx)
+}
+
+// record writes an audit entry for the request.
+func (admin *Admin) record(ctx *fasthttp.RequestCtx, outcome string) {
+	requestID, _ := ctx.UserValue(requestIDKey).(string)
+	admin.audit.Log().
+		Str("request_id", requestID).
+		Bytes("method", ctx.Method()).
+		Bytes("path", ctx.Path()).
+		Str("remote", ctx.RemoteIP().String()).
+		Msg(outcome)
+}
@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"bytes"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAdminProtect(t *testing.T) {
+	ok := func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(http.StatusOK) }
+
+	tests := []struct {
+		name          string
+		token         string
+		testMode      bool
+		authorization string
+		want          int
+	}{
+		{name: "valid token", token: "s3cret", authorization: "Bearer s3cret", want: http.StatusOK},
+		{name: "wrong token", token: "s3cret", authorization: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "missing header", token: "s3cret", want: http.StatusUnauthorized},
+		{name: "not a bearer", token: "s3cret", authorization: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "no token in production", want: http.StatusForbidden},
+		{name: "no token in test mode", testMode: true, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var audit bytes.Buffer
+			admin := NewAdmin(tt.token, tt.testMode, &audit)
+
+			ctx := new(fasthttp.RequestCtx)
+			if tt.authorization != "" {
+				ctx.Request.Header.Set("Authorization", tt.authorization)
+			}
+			admin.Protect(ok)(ctx)
+
+			if got := ctx.Response.StatusCode(); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && !strings.Contains(audit.String(), "denied") {
+				t.Errorf("rejected request was not audited: %q", audit.String())
+			}
+		})
+	}
+}
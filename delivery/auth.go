@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+	"technopark-forum/validation"
+)
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header.
+func bearerToken(ctx *fasthttp.RequestCtx) ([]byte, bool) {
+	const prefix = "Bearer "
+	header := ctx.Request.Header.Peek("Authorization")
+	if len(header) <= len(prefix) || string(header[:len(prefix)]) != prefix {
+		return nil, false
+	}
+	return header[len(prefix):], true
+}
+
+// Authenticate signs the request in as the owner of its bearer token. Requests
+// without an Authorization header go through anonymously; a header that does
+// not check out is rejected rather than downgraded to anonymous.
+func (api *Api) Authenticate(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if len(ctx.Request.Header.Peek("Authorization")) == 0 {
+			handler(ctx)
+			return
+		}
+		token, ok := bearerToken(ctx)
+		if !ok {
+			api.writeError(ctx, models.Unauthorized("expected a bearer token"))
+			return
+		}
+
+		nickname, err := api.usecase.Authenticate(requestContext(ctx), string(token))
+		if err != nil {
+			api.writeError(ctx, err)
+			return
+		}
+		ctx.SetUserValue(userKey, nickname)
+		ctx.SetUserValue(contextKey, auth.WithUser(requestContext(ctx), nickname))
+		handler(ctx)
+	}
+}
+
+// auth
+
+func (api *Api) Login(ctx *fasthttp.RequestCtx) {
+	credentials := new(models.Credentials)
+	if err := readJSON(ctx, credentials); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Credentials(credentials); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	session, err := api.usecase.Login(requestContext(ctx), credentials)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, session)
+}
+
+func (api *Api) Logout(ctx *fasthttp.RequestCtx) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		api.writeError(ctx, models.Unauthorized("expected a bearer token"))
+		return
+	}
+
+	if err := api.usecase.Logout(requestContext(ctx), string(token)); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, models.ErrorMsg{Message: "logged out"})
+}
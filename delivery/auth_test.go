@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"io/ioutil"
+	"net/http"
+	"technopark-forum/auth"
+	"technopark-forum/events"
+	"technopark-forum/models"
+	"technopark-forum/repository"
+	"technopark-forum/usecase"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	service := usecase.NewForumService(repository.NewMemoryStorage(), zerolog.Nop())
+	user := &models.User{Nickname: "jack", Email: "jack@sea.org", Fullname: "Jack", Password: "black pearl"}
+	if _, err := service.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	session, err := service.Login(context.Background(), &models.Credentials{Nickname: "jack", Password: "black pearl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewApi(service, NewAdmin("", true, ioutil.Discard), events.NewHub(events.DefaultBuffer), zerolog.Nop())
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+		wantUser      string
+	}{
+		{name: "anonymous", want: http.StatusOK},
+		{name: "valid token", authorization: "Bearer " + session.Token, want: http.StatusOK, wantUser: "jack"},
+		{name: "unknown token", authorization: "Bearer forged", want: http.StatusUnauthorized},
+		{name: "not a bearer", authorization: "Basic amFjaw==", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seenUser string
+			handler := api.Authenticate(func(ctx *fasthttp.RequestCtx) {
+				seenUser, _ = auth.User(requestContext(ctx))
+				ctx.SetStatusCode(http.StatusOK)
+			})
+
+			var request fasthttp.Request
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Init(&request, nil, nil)
+			handler(ctx)
+
+			if got := ctx.Response.StatusCode(); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+			if seenUser != tt.wantUser {
+				t.Errorf("handler saw user %q, want %q", seenUser, tt.wantUser)
+			}
+			if tt.want == http.StatusUnauthorized && len(ctx.Response.Header.Peek("WWW-Authenticate")) == 0 {
+				t.Error("rejected request has no WWW-Authenticate header")
+			}
+		})
+	}
+}
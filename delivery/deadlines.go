@@ -0,0 +1,95 @@
+package delivery
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"net"
+	"sync"
+	"technopark-forum/logging"
+	"time"
+)
+
+// contextKey is the user value the request context is stored under.
+const contextKey = "forum.context"
+
+// Deadlines bounds how long a handler may work on a request. Routes are keyed
+// by "METHOD /pattern" as registered with the router; other routes get Default,
+// and a zero timeout leaves the request without a deadline.
+//
+// The context does not derive from the RequestCtx, which is canceled as soon
+// as the server starts shutting down: requests in flight are drained, not cut
+// short. fasthttp does not tell a running handler that its client went away
+// either, so the deadline is the only thing that stops a slow query on an
+// abandoned request.
+type Deadlines struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+func (deadlines Deadlines) timeout(route string) time.Duration {
+	if timeout, ok := deadlines.Routes[route]; ok {
+		return timeout
+	}
+	return deadlines.Default
+}
+
+// Wrap makes handler run with a context limited by the timeout for route.
+func (deadlines Deadlines) Wrap(route string, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	timeout := deadlines.timeout(route)
+
+	return func(ctx *fasthttp.RequestCtx) {
+		var requestCtx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			requestCtx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			requestCtx, cancel = context.WithCancel(context.Background())
+		}
+		defer cancel()
+
+		if requestID, ok := ctx.UserValue(requestIDKey).(string); ok {
+			requestCtx = logging.WithRequestID(requestCtx, requestID)
+		}
+		ctx.SetUserValue(contextKey, requestCtx)
+		handler(ctx)
+	}
+}
+
+// requestContext returns the context Wrap attached to ctx, or a background
+// context for handlers registered without deadlines.
+func requestContext(ctx *fasthttp.RequestCtx) context.Context {
+	if requestCtx, ok := ctx.UserValue(contextKey).(context.Context); ok {
+		return requestCtx
+	}
+	return context.Background()
+}
+
+// writeDeadline moves the write deadline of the connection a streamed response
+// goes out on. fasthttp sets server.write_timeout once, before writing the
+// response, which would bound a whole stream; streams instead extend the
+// deadline by wait before every write, so only a client that stops taking
+// data is cut off. Streamed responses close their connection, so the deadline
+// never outlives them.
+type writeDeadline struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	wait    time.Duration
+	expired bool
+}
+
+// extend gives the next write wait, unless the deadline has expired.
+func (deadline *writeDeadline) extend() {
+	deadline.mu.Lock()
+	defer deadline.mu.Unlock()
+	if !deadline.expired {
+		_ = deadline.conn.SetWriteDeadline(time.Now().Add(deadline.wait))
+	}
+}
+
+// expire fails the write in progress, if any, and every later one.
+func (deadline *writeDeadline) expire() {
+	deadline.mu.Lock()
+	defer deadline.mu.Unlock()
+	deadline.expired = true
+	_ = deadline.conn.SetWriteDeadline(time.Now())
+}
@@ -0,0 +1,39 @@
+package delivery
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteDeadline(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	deadline := &writeDeadline{conn: server, wait: 20 * time.Millisecond}
+
+	// Nobody reads yet, as a client that stopped taking data.
+	deadline.extend()
+	if _, err := server.Write([]byte("stalled")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("write to a stalled client error = %v, want a timeout", err)
+	}
+
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, client)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	deadline.extend()
+	if _, err := server.Write([]byte("taken")); err != nil {
+		t.Fatalf("write after extend: %s", err)
+	}
+
+	// Once expired, extending no longer moves the deadline.
+	deadline.expire()
+	deadline.extend()
+	if _, err := server.Write([]byte("cut off")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("write after expire error = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,83 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+)
+
+// errorStatus maps a domain error onto the HTTP status code it is reported with.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError answers the request with the status matching err and an ErrorMsg
+// body. Server-side failures are logged with their cause and answered with a
+// generic message; client errors are left to the access log.
+func (api *Api) writeError(ctx *fasthttp.RequestCtx, err error) {
+	var fieldErrors models.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		writeJSON(ctx, http.StatusBadRequest, models.ValidationErrorMsg{Message: "Invalid input", Errors: fieldErrors})
+		return
+	}
+
+	statusCode := errorStatus(err)
+	message := models.ErrorMessage(err)
+	if statusCode >= http.StatusInternalServerError {
+		event := logging.For(requestContext(ctx), api.log).Error().Err(err)
+		var internal *models.InternalError
+		if errors.As(err, &internal) {
+			event = event.AnErr("cause", internal.Err)
+			message = models.ErrorMessage(internal)
+		} else {
+			message = models.ErrorMsg{Message: http.StatusText(statusCode)}
+		}
+		event.Int("status", statusCode).Msg("request failed")
+	}
+	if statusCode == http.StatusUnauthorized {
+		ctx.Response.Header.Set("WWW-Authenticate", "Bearer")
+	}
+	writeJSON(ctx, statusCode, message)
+}
+
+// readJSON decodes the request body into body, reporting malformed JSON as a
+// field error so it is answered like any other invalid input. An empty body
+// leaves body untouched.
+func readJSON(ctx *fasthttp.RequestCtx, body easyjson.Unmarshaler) error {
+	if len(ctx.PostBody()) == 0 {
+		return nil
+	}
+	if err := easyjson.Unmarshal(ctx.PostBody(), body); err != nil {
+		return models.FieldErrors{{Field: "body", Message: "is not valid JSON"}}
+	}
+	return nil
+}
+
+func writeJSON(ctx *fasthttp.RequestCtx, statusCode int, body easyjson.Marshaler) {
+	response, _ := easyjson.Marshal(body)
+
+	ctx.SetStatusCode(statusCode)
+	ctx.SetContentType("application/json")
+	_, _ = ctx.Write(response)
+}
@@ -0,0 +1,30 @@
+package delivery
+
+import (
+	"errors"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strings"
+	"technopark-forum/models"
+	"testing"
+)
+
+func TestWriteErrorHidesCauses(t *testing.T) {
+	api := &Api{log: zerolog.Nop()}
+	tests := []struct {
+		err     error
+		message string
+	}{
+		{err: models.Internal(models.EntityPost, "7", errors.New(`duplicate key value violates unique constraint "posts_pkey"`)), message: "Internal error on post 7"},
+		{err: errors.New(`ERROR: relation "posts" does not exist (SQLSTATE 42P01)`), message: "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		ctx := new(fasthttp.RequestCtx)
+		api.writeError(ctx, tt.err)
+		body := string(ctx.Response.Body())
+		if ctx.Response.StatusCode() != http.StatusInternalServerError || !strings.Contains(body, `"`+tt.message+`"`) || strings.Contains(body, "posts") {
+			t.Errorf("writeError(%v) answered %d %s", tt.err, ctx.Response.StatusCode(), body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/logging"
+	"time"
+)
+
+const (
+	// exportTimeout bounds a whole export.
+	exportTimeout = time.Hour
+	// exportStallTimeout is how long a write of an export may wait on a
+	// client that takes none of it.
+	exportStallTimeout = time.Minute
+)
+
+// ExportForum serves GET /forum/{slug}/export: the forum, its threads, posts,
+// votes and the users taking part, as newline-delimited JSON. It is for
+// operators only, as it includes deleted content and e-mail addresses.
+func (api *Api) ExportForum(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
+	// Looking the forum up first answers a missing one with a 404 rather than
+	// with an error record in an otherwise empty export.
+	forum, err := api.usecase.GetForum(requestContext(ctx), slug)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, "exported forum "+forum.Slug)
+
+	// The export outlives the handler and so its deadline; it ends when done,
+	// when the client goes away or when the server shuts down. It holds a
+	// snapshot and its database connection throughout, so it is also cut off
+	// after exportTimeout, or once a write has waited on the client for
+	// exportStallTimeout: the connection's write deadline then fails the write
+	// the export is blocked on.
+	base := context.Background()
+	if requestID, ok := ctx.UserValue(requestIDKey).(string); ok {
+		base = logging.WithRequestID(base, requestID)
+	}
+	shutdown := ctx.Done()
+	deadline := &writeDeadline{conn: ctx.Conn(), wait: exportStallTimeout}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetContentType("application/x-ndjson")
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="`+forum.Slug+`.ndjson"`)
+	ctx.Response.Header.Set("Cache-Control", "no-store")
+	ctx.SetConnectionClose()
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		exportCtx, cancel := context.WithTimeout(base, exportTimeout)
+		defer cancel()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-done:
+				return
+			case <-shutdown:
+			case <-exportCtx.Done():
+			}
+			cancel()
+			select {
+			case <-done:
+			default:
+				deadline.expire()
+			}
+		}()
+
+		out := &exportWriter{w: w, deadline: deadline}
+		err := api.usecase.ExportForum(exportCtx, forum.Slug, out)
+		if err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			logging.For(exportCtx, api.log).Error().Err(err).Str("forum", forum.Slug).Msg("export failed")
+		}
+	})
+}
+
+// exportWriter writes an export to its client, moving the write deadline
+// before every write.
+type exportWriter struct {
+	w        *bufio.Writer
+	deadline *writeDeadline
+}
+
+func (out *exportWriter) Write(p []byte) (int, error) {
+	out.deadline.extend()
+	return out.w.Write(p)
+}
+
+func (out *exportWriter) Flush() error {
+	out.deadline.extend()
+	return out.w.Flush()
+}
@@ -0,0 +1,222 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"technopark-forum/feeds"
+	"technopark-forum/models"
+	"technopark-forum/validation"
+	"time"
+)
+
+// ForumFeed serves GET /forum/{slug}/feed.rss and feed.atom with the latest
+// threads of the forum, and GET /forum/{slug}/feed in the format the client
+// prefers.
+func (api *Api) ForumFeed(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
+	limit, err := validation.FeedQuery(ctx.QueryArgs().Peek("limit"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	format, contentType, ok := negotiateFeed(ctx)
+	if !ok {
+		writeNotAcceptable(ctx)
+		return
+	}
+
+	feed, err := api.usecase.GetForumFeed(requestContext(ctx), slug, limit)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	api.writeFeed(ctx, feed, format, contentType)
+}
+
+// ThreadFeed serves GET /thread/{slug_or_id}/feed.rss and feed.atom with the
+// latest posts of the thread, and GET /thread/{slug_or_id}/feed in the format
+// the client prefers.
+func (api *Api) ThreadFeed(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+	limit, err := validation.FeedQuery(ctx.QueryArgs().Peek("limit"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	format, contentType, ok := negotiateFeed(ctx)
+	if !ok {
+		writeNotAcceptable(ctx)
+		return
+	}
+
+	feed, err := api.usecase.GetThreadFeed(requestContext(ctx), slugOrID, limit)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	api.writeFeed(ctx, feed, format, contentType)
+}
+
+// writeFeed answers with feed, or with 304 Not Modified if the client has it
+// already. The ETag covers the body and its content type, so it changes with
+// anything shown, deletions included. Forum feeds also send Last-Modified,
+// checked only without an If-None-Match, as it only moves with new and edited
+// threads. Thread feeds rely on the ETag alone: posts are stored without a
+// time of their own, so nothing but edits would ever move theirs.
+func (api *Api) writeFeed(ctx *fasthttp.RequestCtx, feed *models.Feed, format string, contentType string) {
+	body, err := feeds.Render(feed, format, api.feedSite(ctx), string(ctx.Path()))
+	if err != nil {
+		api.writeError(ctx, models.Internal(models.EntityForum, string(ctx.Path()), err))
+		return
+	}
+	sum := sha256.Sum256(append([]byte(contentType+"\n"), body...))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	var updated time.Time
+	if feed.Thread == nil {
+		updated = feeds.Updated(feed)
+	}
+
+	var modified bool
+	if match := ctx.Request.Header.Peek("If-None-Match"); len(match) > 0 {
+		modified = !etagMatches(string(match), etag)
+	} else {
+		modified = updated.IsZero() || ctx.IfModifiedSince(updated)
+	}
+	ctx.SetContentType(contentType + "; charset=utf-8")
+	ctx.Response.Header.Set("ETag", etag)
+	if !updated.IsZero() {
+		ctx.Response.Header.SetLastModified(updated)
+	}
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("Vary", "Accept")
+	if !modified {
+		ctx.SetStatusCode(http.StatusNotModified)
+		return
+	}
+	ctx.SetStatusCode(http.StatusOK)
+	_, _ = ctx.Write(body)
+}
+
+// feedSite is the public site of the server, or the one the request reached,
+// as the client addressed it, if none is set.
+func (api *Api) feedSite(ctx *fasthttp.RequestCtx) feeds.Site {
+	if api.site.Host != "" {
+		return api.site
+	}
+	site := feeds.Site{Scheme: "http", Host: string(ctx.Host())}
+	if ctx.IsTLS() || strings.EqualFold(string(ctx.Request.Header.Peek("X-Forwarded-Proto")), "https") {
+		site.Scheme = "https"
+	}
+	return site
+}
+
+// etagMatches reports whether the If-None-Match header matches etag. Weak
+// tags match too, as they do for GET.
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotAcceptable(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, http.StatusNotAcceptable, models.ErrorMsg{
+		Message: "Feeds are served as " + feeds.AtomType + ", " + feeds.RSSType + ", application/xml or text/xml",
+	})
+}
+
+// feedType is a media type a feed may be served as.
+type feedType struct {
+	format      string
+	contentType string
+}
+
+// negotiateFeed picks the format and media type of the feed from the path,
+// feed.rss or feed.atom, and the Accept header. Feed types are preferred to
+// the generic XML ones, which are only there for clients that take no others;
+// a plain feed is Atom unless RSS is preferred.
+func negotiateFeed(ctx *fasthttp.RequestCtx) (string, string, bool) {
+	var offers []feedType
+	switch strings.TrimPrefix(path.Ext(string(ctx.Path())), ".") {
+	case feeds.RSS:
+		offers = []feedType{{feeds.RSS, feeds.RSSType}, {feeds.RSS, "application/xml"}, {feeds.RSS, "text/xml"}}
+	case feeds.Atom:
+		offers = []feedType{{feeds.Atom, feeds.AtomType}, {feeds.Atom, "application/xml"}, {feeds.Atom, "text/xml"}}
+	default:
+		offers = []feedType{{feeds.Atom, feeds.AtomType}, {feeds.RSS, feeds.RSSType}, {feeds.Atom, "application/xml"}, {feeds.Atom, "text/xml"}}
+	}
+
+	accept := parseAccept(string(ctx.Request.Header.Peek("Accept")))
+	if len(accept) == 0 {
+		return offers[0].format, offers[0].contentType, true
+	}
+	best, bestQuality := feedType{}, 0.0
+	for _, offer := range offers {
+		if quality := accept.quality(offer.contentType); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best.format, best.contentType, bestQuality > 0
+}
+
+// mediaRanges are the media ranges of an Accept header with their quality.
+type mediaRanges []mediaRange
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+func parseAccept(header string) mediaRanges {
+	var ranges mediaRanges
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		accepted := mediaRange{mediaType: mediaType, quality: 1}
+		for _, param := range params[1:] {
+			pair := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(pair) == 2 && strings.EqualFold(pair[0], "q") {
+				if quality, err := strconv.ParseFloat(pair[1], 64); err == nil {
+					accepted.quality = quality
+				}
+			}
+		}
+		ranges = append(ranges, accepted)
+	}
+	return ranges
+}
+
+// quality is how much mediaType is wanted: the quality of the most specific
+// range matching it, 0 if none does.
+func (ranges mediaRanges) quality(mediaType string) float64 {
+	major := mediaType[:strings.IndexByte(mediaType, '/')]
+	quality, specificity := 0.0, -1
+	for _, accepted := range ranges {
+		matched := -1
+		switch accepted.mediaType {
+		case mediaType:
+			matched = 2
+		case major + "/*":
+			matched = 1
+		case "*/*":
+			matched = 0
+		}
+		if matched > specificity {
+			quality, specificity = accepted.quality, matched
+		}
+	}
+	return quality
+}
@@ -0,0 +1,186 @@
+package delivery
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"technopark-forum/auth"
+	"technopark-forum/events"
+	"technopark-forum/models"
+	"technopark-forum/repository"
+	"technopark-forum/usecase"
+	"testing"
+	"time"
+)
+
+func TestForumFeed(t *testing.T) {
+	service := usecase.NewForumService(repository.NewMemoryStorage(), zerolog.Nop())
+	ctx := auth.WithUser(context.Background(), "jack")
+	if _, err := service.CreateUser(ctx, &models.User{Nickname: "jack", Email: "jack@sea.org", Fullname: "Jack", Password: "black pearl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateForum(ctx, &models.Forum{Slug: "pirates", Title: "Pirates", Author: "jack"}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := service.CreateThread(ctx, "pirates", &models.Thread{Title: "Treasure", Author: "jack", Message: "x marks <the> spot", Created: created}); err != nil {
+		t.Fatal(err)
+	}
+	api := NewApi(service, NewAdmin("", true, ioutil.Discard), events.NewHub(events.DefaultBuffer), zerolog.Nop())
+
+	get := func(path string, headers ...string) *fasthttp.Response {
+		t.Helper()
+		var request fasthttp.Request
+		request.SetRequestURI("http://forum.example.com:5000" + path)
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Init(&request, nil, nil)
+		ctx.SetUserValue("slug", "PIRATES")
+		api.ForumFeed(ctx)
+		return &ctx.Response
+	}
+
+	atom := get("/api/forum/PIRATES/feed.atom")
+	body := string(atom.Body())
+	if atom.StatusCode() != http.StatusOK || string(atom.Header.ContentType()) != "application/atom+xml; charset=utf-8" {
+		t.Fatalf("feed.atom answered %d %s", atom.StatusCode(), atom.Header.ContentType())
+	}
+	for _, want := range []string{
+		"<id>tag:forum.example.com,2018:forum/pirates</id>",
+		"<id>tag:forum.example.com,2018:thread/1</id>",
+		"<updated>2026-03-01T12:00:00Z</updated>",
+		`href="http://forum.example.com:5000/api/thread/1/details"`,
+		"x marks &lt;the&gt; spot",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed.atom lacks %s:\n%s", want, body)
+		}
+	}
+	etag := string(atom.Header.Peek("ETag"))
+	if etag == "" || string(atom.Header.Peek("Last-Modified")) != "Sun, 01 Mar 2026 12:00:00 GMT" {
+		t.Errorf("feed.atom has ETag %q and Last-Modified %q", etag, atom.Header.Peek("Last-Modified"))
+	}
+
+	rss := get("/api/forum/PIRATES/feed.rss")
+	if body := string(rss.Body()); string(rss.Header.ContentType()) != "application/rss+xml; charset=utf-8" ||
+		!strings.Contains(body, `<guid isPermaLink="false">tag:forum.example.com,2018:thread/1</guid>`) ||
+		!strings.Contains(body, "<pubDate>Sun, 01 Mar 2026 12:00:00 +0000</pubDate>") {
+		t.Errorf("feed.rss answered %s:\n%s", rss.Header.ContentType(), body)
+	}
+	if string(rss.Header.Peek("ETag")) == etag {
+		t.Errorf("feed.rss has the ETag of feed.atom")
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers []string
+		status  int
+		content string
+	}{
+		{name: "matching etag", path: "/api/forum/PIRATES/feed.atom", headers: []string{"If-None-Match", `"other", W/` + etag}, status: http.StatusNotModified},
+		{name: "stale etag", path: "/api/forum/PIRATES/feed.atom", headers: []string{"If-None-Match", `"other"`, "If-Modified-Since", "Sun, 01 Mar 2026 12:00:00 GMT"}, status: http.StatusOK},
+		{name: "not modified since", path: "/api/forum/PIRATES/feed.atom", headers: []string{"If-Modified-Since", "Sun, 01 Mar 2026 12:00:00 GMT"}, status: http.StatusNotModified},
+		{name: "modified since", path: "/api/forum/PIRATES/feed.atom", headers: []string{"If-Modified-Since", "Sun, 01 Mar 2026 11:59:59 GMT"}, status: http.StatusOK},
+		{name: "generic xml", path: "/api/forum/PIRATES/feed.rss", headers: []string{"Accept", "text/html,application/xml;q=0.9,*/*;q=0.8"}, status: http.StatusOK, content: "application/xml"},
+		{name: "negotiated rss", path: "/api/forum/PIRATES/feed", headers: []string{"Accept", "application/atom+xml;q=0.5, application/rss+xml"}, status: http.StatusOK, content: "application/rss+xml"},
+		{name: "negotiated atom", path: "/api/forum/PIRATES/feed", status: http.StatusOK, content: "application/atom+xml"},
+		{name: "not acceptable", path: "/api/forum/PIRATES/feed.atom", headers: []string{"Accept", "application/json, application/atom+xml;q=0"}, status: http.StatusNotAcceptable},
+		{name: "invalid limit", path: "/api/forum/PIRATES/feed.atom?limit=0", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		response := get(tt.path, tt.headers...)
+		if response.StatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, response.StatusCode(), tt.status)
+		}
+		if tt.content != "" && !strings.HasPrefix(string(response.Header.ContentType()), tt.content+";") {
+			t.Errorf("%s: content type = %s, want %s", tt.name, response.Header.ContentType(), tt.content)
+		}
+		if tt.status == http.StatusNotModified && (len(response.Body()) != 0 || len(response.Header.Peek("ETag")) == 0) {
+			t.Errorf("%s: answered %q without the ETag %s", tt.name, response.Body(), response.Header.Peek("ETag"))
+		}
+	}
+}
+
+func TestThreadFeed(t *testing.T) {
+	service := usecase.NewForumService(repository.NewMemoryStorage(), zerolog.Nop())
+	ctx := auth.WithUser(context.Background(), "jack")
+	if _, err := service.CreateUser(ctx, &models.User{Nickname: "jack", Email: "jack@sea.org", Fullname: "Jack", Password: "black pearl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateForum(ctx, &models.Forum{Slug: "pirates", Title: "Pirates", Author: "jack"}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := service.CreateThread(ctx, "pirates", &models.Thread{Slug: "treasure", Title: "Treasure", Author: "jack", Message: "x marks the spot", Created: created}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreatePosts(ctx, "treasure", &models.Posts{{Author: "jack", Message: "dig <here>"}}); err != nil {
+		t.Fatal(err)
+	}
+	api := NewApi(service, NewAdmin("", true, ioutil.Discard), events.NewHub(events.DefaultBuffer), zerolog.Nop())
+
+	get := func(headers ...string) *fasthttp.Response {
+		t.Helper()
+		var request fasthttp.Request
+		request.SetRequestURI("http://forum.example.com:5000/api/thread/treasure/feed.atom")
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Init(&request, nil, nil)
+		ctx.SetUserValue("slug_or_id", "treasure")
+		api.ThreadFeed(ctx)
+		return &ctx.Response
+	}
+
+	atom := get()
+	body := string(atom.Body())
+	if atom.StatusCode() != http.StatusOK {
+		t.Fatalf("feed.atom answered %d:\n%s", atom.StatusCode(), body)
+	}
+	for _, want := range []string{
+		"<id>tag:forum.example.com,2018:thread/1/posts</id>",
+		"<id>tag:forum.example.com,2018:post/1</id>",
+		"dig &lt;here&gt;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed.atom lacks %s:\n%s", want, body)
+		}
+	}
+	etag := string(atom.Header.Peek("ETag"))
+	if etag == "" || len(atom.Header.Peek("Last-Modified")) != 0 {
+		t.Errorf("feed.atom has ETag %q and Last-Modified %q", etag, atom.Header.Peek("Last-Modified"))
+	}
+
+	if response := get("If-None-Match", etag); response.StatusCode() != http.StatusNotModified {
+		t.Errorf("matching etag: status = %d, want %d", response.StatusCode(), http.StatusNotModified)
+	}
+	if response := get("If-Modified-Since", "Sun, 01 Mar 2026 12:00:00 GMT"); response.StatusCode() != http.StatusOK {
+		t.Errorf("if modified since: status = %d, want %d", response.StatusCode(), http.StatusOK)
+	}
+
+	if _, err := service.CreatePosts(ctx, "treasure", &models.Posts{{Author: "jack", Message: "found it"}}); err != nil {
+		t.Fatal(err)
+	}
+	if response := get("If-None-Match", etag); response.StatusCode() != http.StatusOK || !strings.Contains(string(response.Body()), "found it") {
+		t.Errorf("new post: answered %d:\n%s", response.StatusCode(), response.Body())
+	}
+
+	api.SetPublicURL(&url.URL{Scheme: "https", Host: "forum.example.org"})
+	body = string(get("X-Forwarded-Proto", "http").Body())
+	for _, want := range []string{
+		"<id>tag:forum.example.org,2018:post/1</id>",
+		`href="https://forum.example.org/api/post/1/details"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed.atom at the public url lacks %s:\n%s", want, body)
+		}
+	}
+}
@@ -1,194 +1,215 @@
 package delivery
 
 import (
-	"github.com/mailru/easyjson"
-	"github.com/pkg/errors"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
 	"github.com/valyala/fasthttp"
 	"net/http"
+	"net/url"
+	"technopark-forum/events"
+	"technopark-forum/feeds"
 	"technopark-forum/models"
 	"technopark-forum/usecase"
+	"technopark-forum/validation"
 )
 
 type Api struct {
 	usecase *usecase.Service
+	admin   *Admin
+	hub     *events.Hub
+	log     zerolog.Logger
+	// site is where feeds are served from, the Host of each request if empty.
+	site feeds.Site
 }
 
-func NewApi(usecase *usecase.Service) *Api {
-	return &Api{usecase: usecase}
+func NewApi(usecase *usecase.Service, admin *Admin, hub *events.Hub, log zerolog.Logger) *Api {
+	return &Api{usecase: usecase, admin: admin, hub: hub, log: log}
+}
+
+// SetPublicURL makes feeds link to publicURL and identify their entries by its
+// host, whatever Host a request names.
+func (api *Api) SetPublicURL(publicURL *url.URL) {
+	api.site = feeds.Site{Scheme: publicURL.Scheme, Host: publicURL.Host}
 }
 
 // service
 
 func (api *Api) GetStatus(ctx *fasthttp.RequestCtx) {
-	status, err := api.usecase.GetStatus()
-
-	var response []byte
+	status, err := api.usecase.GetStatus(requestContext(ctx))
 	if err != nil {
-		ctx.SetStatusCode(http.StatusInternalServerError)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
-	} else {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(status)
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, status)
 }
 
 func (api *Api) Clear(ctx *fasthttp.RequestCtx) {
-	err := api.usecase.Clear()
+	if !api.admin.testMode {
+		api.admin.record(ctx, "denied: clear is disabled outside test mode")
+		writeJSON(ctx, http.StatusForbidden, models.ErrorMsg{Message: "clear is disabled outside test mode"})
+		return
+	}
 
-	var response []byte
+	status, err := api.usecase.GetStatus(requestContext(ctx))
 	if err != nil {
-		ctx.SetStatusCode(http.StatusInternalServerError)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
-	} else {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(models.ErrorMessage(errors.New("cleared")))
+		api.writeError(ctx, err)
+		return
+	}
+	err = api.usecase.Clear(requestContext(ctx))
+	if err != nil {
+		api.admin.record(ctx, "clear failed: "+err.Error())
+		api.writeError(ctx, err)
+		return
 	}
+	api.admin.record(ctx, fmt.Sprintf("cleared %d users, %d forums, %d threads, %d posts",
+		status.User, status.Forum, status.Thread, status.Post))
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, models.ErrorMsg{Message: "cleared"})
 }
 
 // user
 
 func (api *Api) CreateUser(ctx *fasthttp.RequestCtx) {
 	user := new(models.User)
-	_ = easyjson.Unmarshal(ctx.PostBody(), user)
+	if err := readJSON(ctx, user); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 	user.Nickname = ctx.UserValue("nickname").(string)
+	if err := validation.User(user); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	users, err := api.usecase.CreateUser(user)
+	users, err := api.usecase.CreateUser(requestContext(ctx), user)
 	if err != nil {
-		ctx.Error(err.Error(), http.StatusInternalServerError)
+		api.writeError(ctx, err)
+		return
 	}
 
-	var response []byte
 	if users != nil {
-		ctx.SetStatusCode(http.StatusConflict)
-		response, _ = easyjson.Marshal(users)
+		writeJSON(ctx, http.StatusConflict, users)
 	} else {
-		ctx.SetStatusCode(http.StatusCreated)
-		response, _ = easyjson.Marshal(user)
+		writeJSON(ctx, http.StatusCreated, user)
 	}
-
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
 }
 
 func (api *Api) GetUserProfile(ctx *fasthttp.RequestCtx) {
 	nickname := ctx.UserValue("nickname").(string)
 
-	user, err := api.usecase.GetUserProfile(nickname)
-
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(user)
-	} else if err.Error() == models.UserNotFound(nickname).Error() {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	user, err := api.usecase.GetUserProfile(requestContext(ctx), nickname)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, user)
 }
 
 func (api *Api) UpdateUserProfile(ctx *fasthttp.RequestCtx) {
 	user := new(models.User)
-	_ = easyjson.Unmarshal(ctx.PostBody(), user)
+	if err := readJSON(ctx, user); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 	user.Nickname = ctx.UserValue("nickname").(string)
+	if err := validation.UserUpdate(user); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	newUser, err := api.usecase.UpdateUserProfile(user)
-	var response []byte
+	newUser, err := api.usecase.UpdateUserProfile(requestContext(ctx), user)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	if err == nil {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(newUser)
-	} else {
-		switch {
-		case models.UserNotFound(user.Nickname).Error() == err.Error():
-			ctx.SetStatusCode(http.StatusNotFound)
-			response, _ = easyjson.Marshal(models.ErrorMessage(err))
-		case models.UsersProfileConflict(user.Nickname).Error() == err.Error():
-			ctx.SetStatusCode(http.StatusConflict)
-			response, _ = easyjson.Marshal(models.ErrorMessage(err))
-		}
+	writeJSON(ctx, http.StatusOK, newUser)
+}
+
+func (api *Api) SetUserRole(ctx *fasthttp.RequestCtx) {
+	nickname := ctx.UserValue("nickname").(string)
+	update := new(models.UserRole)
+	if err := readJSON(ctx, update); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.UserRole(update); err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	role, err := api.usecase.SetUserRole(requestContext(ctx), nickname, update)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, fmt.Sprintf("set role of %s to %s", role.Nickname, role.Role))
+
+	writeJSON(ctx, http.StatusOK, role)
 }
 
 // forum
 
 func (api *Api) CreateForum(ctx *fasthttp.RequestCtx) {
 	forum := new(models.Forum)
-	_ = easyjson.Unmarshal(ctx.PostBody(), forum)
-	author := forum.Author
-
-	forum, err := api.usecase.CreateForum(forum)
-
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusCreated)
-		response, _ = easyjson.Marshal(forum)
-	} else if forum != nil && err != nil {
-		ctx.SetStatusCode(http.StatusConflict)
-		response, _ = easyjson.Marshal(forum)
-	} else if err.Error() == models.UserNotFound(author).Error() {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	if err := readJSON(ctx, forum); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Forum(forum); err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	forum, err := api.usecase.CreateForum(requestContext(ctx), forum)
+	switch {
+	case err == nil:
+		writeJSON(ctx, http.StatusCreated, forum)
+	case forum != nil && errors.Is(err, models.ErrConflict):
+		writeJSON(ctx, http.StatusConflict, forum)
+	default:
+		api.writeError(ctx, err)
+	}
 }
 
 func (api *Api) GetForum(ctx *fasthttp.RequestCtx) {
 	slug := ctx.UserValue("slug").(string)
 
-	forum, err := api.usecase.GetForum(slug)
-
-	var response []byte
-	if err == nil {
-		response, _ = easyjson.Marshal(forum)
-	} else {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	forum, err := api.usecase.GetForum(requestContext(ctx), slug)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, forum)
 }
 
 func (api *Api) CreateThread(ctx *fasthttp.RequestCtx) {
 	thread := new(models.Thread)
-	_ = easyjson.Unmarshal(ctx.PostBody(), thread)
+	if err := readJSON(ctx, thread); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Thread(thread); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
 	slug := ctx.UserValue("slug").(string)
 	thread.Forum = slug
 
-	gotThread, err := api.usecase.CreateThread(slug, thread)
-
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusCreated)
-		response, _ = easyjson.Marshal(gotThread)
-	} else if models.UserNotFound(thread.Author).Error() == err.Error() ||
-		models.ForumNotFound(thread.Slug).Error() == err.Error() {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
-	} else if err == models.Conflict {
-		ctx.SetStatusCode(http.StatusConflict)
-		response, _ = easyjson.Marshal(gotThread)
-	} else {
-		ctx.SetStatusCode(http.StatusConflict)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	gotThread, err := api.usecase.CreateThread(requestContext(ctx), slug, thread)
+	switch {
+	case err == nil:
+		writeJSON(ctx, http.StatusCreated, gotThread)
+	case gotThread != nil && errors.Is(err, models.ErrConflict):
+		writeJSON(ctx, http.StatusConflict, gotThread)
+	default:
+		api.writeError(ctx, err)
 	}
-
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
 }
 
 func (api *Api) GetUsers(ctx *fasthttp.RequestCtx) {
@@ -197,24 +218,38 @@ func (api *Api) GetUsers(ctx *fasthttp.RequestCtx) {
 	limit := ctx.QueryArgs().Peek("limit")
 	desc := ctx.QueryArgs().Peek("desc")
 	since := ctx.QueryArgs().Peek("since")
+	if err := validation.ForumUsersQuery(limit, since, desc); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	users, err := api.usecase.GetForumUsers(slug, limit, since, desc)
+	users, err := api.usecase.GetForumUsers(requestContext(ctx), slug, limit, since, desc)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusOK)
-		if len(*users) != 0 {
-			response, _ = easyjson.Marshal(users)
-		} else {
-			response = []byte("[]")
-		}
-	} else if models.ForumNotFound(slug).Error() == err.Error() {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	if len(*users) == 0 {
+		users = &models.Users{}
 	}
+	writeJSON(ctx, http.StatusOK, users)
+}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+func (api *Api) GetLeaderboard(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
+	limit, err := validation.LeaderboardQuery(ctx.QueryArgs().Peek("limit"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	users, err := api.usecase.GetForumLeaderboard(requestContext(ctx), slug, limit)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, users)
 }
 
 func (api *Api) GetThreads(ctx *fasthttp.RequestCtx) {
@@ -223,185 +258,476 @@ func (api *Api) GetThreads(ctx *fasthttp.RequestCtx) {
 	limit := ctx.QueryArgs().Peek("limit")
 	desc := ctx.QueryArgs().Peek("desc")
 	since := ctx.QueryArgs().Peek("since")
+	archived := ctx.QueryArgs().Peek("archived")
+	if err := validation.ForumThreadsQuery(limit, since, desc, archived); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	threads, err := api.usecase.GetForumThreads(slug, limit, since, desc)
-
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusOK)
-		if len(*threads) != 0 {
-			response, _ = easyjson.Marshal(threads)
-		} else {
-			response = []byte("[]")
-		}
-	} else if models.ForumNotFound(slug).Error() == err.Error() {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
-	} else {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	threads, err := api.usecase.GetForumThreads(requestContext(ctx), slug, limit, since, desc, string(archived) == "true")
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	if len(*threads) == 0 {
+		threads = &models.Threads{}
 	}
+	writeJSON(ctx, http.StatusOK, threads)
+}
+
+func (api *Api) GetModerators(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	moderators, err := api.usecase.GetForumModerators(requestContext(ctx), slug)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, moderators)
+}
+
+func (api *Api) AddModerator(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
+	nickname := ctx.UserValue("nickname").(string)
+
+	moderators, err := api.usecase.AddModerator(requestContext(ctx), slug, nickname)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, moderators)
+}
+
+func (api *Api) RemoveModerator(ctx *fasthttp.RequestCtx) {
+	slug := ctx.UserValue("slug").(string)
+	nickname := ctx.UserValue("nickname").(string)
+
+	moderators, err := api.usecase.RemoveModerator(requestContext(ctx), slug, nickname)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, moderators)
 }
 
 func (api *Api) CreatePosts(ctx *fasthttp.RequestCtx) {
 	slugOrID := ctx.UserValue("slug_or_id")
 
 	posts := models.Posts{}
-	_ = easyjson.Unmarshal(ctx.PostBody(), &posts)
-
-	newPosts, err := api.usecase.CreatePosts(slugOrID, &posts)
+	if err := readJSON(ctx, &posts); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Posts(posts); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusCreated)
-		if newPosts != nil {
-			response, _ = easyjson.Marshal(newPosts)
-		} else {
-			response = []byte("[]")
-		}
-	} else if err == models.ThreadNotFound || err == models.UserNotFoundSimple {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
-	} else if err == models.Conflict {
-		ctx.SetStatusCode(http.StatusConflict)
-		response, _ = easyjson.Marshal(models.ErrorMessage(err))
+	newPosts, err := api.usecase.CreatePosts(requestContext(ctx), slugOrID, &posts)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	if newPosts == nil {
+		newPosts = &models.Posts{}
+	}
+	writeJSON(ctx, http.StatusCreated, newPosts)
 }
 
 func (api *Api) GetThread(ctx *fasthttp.RequestCtx) {
 	slugOrID := ctx.UserValue("slug_or_id")
 
-	thread, err := api.usecase.GetThread(slugOrID)
-
-	var response []byte
+	thread, err := api.usecase.GetThread(requestContext(ctx), slugOrID)
 	if err != nil {
-		ctx.SetStatusCode(http.StatusNotFound)
-		if thread == nil {
-			response, _ = easyjson.Marshal(models.ErrorMessage(models.ThreadNotFound))
-		} else {
-			response, _ = easyjson.Marshal(models.ErrorMessage(err))
-		}
-	} else {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(thread)
-
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, thread)
 }
 
 func (api *Api) UpdateThread(ctx *fasthttp.RequestCtx) {
 	slugOrID := ctx.UserValue("slug_or_id").(string)
 
 	threadUpd := new(models.ThreadUpdate)
-	_ = easyjson.Unmarshal(ctx.PostBody(), threadUpd)
+	if err := readJSON(ctx, threadUpd); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.ThreadUpdate(threadUpd); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	thread, err := api.usecase.UpdateThread(slugOrID, threadUpd)
+	thread, err := api.usecase.UpdateThread(requestContext(ctx), slugOrID, threadUpd)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	var response []byte
-	if err == nil {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(thread)
-	} else {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(models.ThreadNotFound))
+	writeJSON(ctx, http.StatusOK, thread)
+}
+
+func (api *Api) SetThreadState(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+
+	update := new(models.ThreadStateUpdate)
+	if err := readJSON(ctx, update); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.ThreadState(update); err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	thread, err := api.usecase.SetThreadState(requestContext(ctx), slugOrID, update)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, thread)
 }
 
-func (api *Api) GetPosts(ctx *fasthttp.RequestCtx) {
-	ctx.SetContentType("application/json")
+func (api *Api) RestoreThread(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+
+	thread, err := api.usecase.RestoreThread(requestContext(ctx), slugOrID)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, "restored thread "+slugOrID)
+
+	writeJSON(ctx, http.StatusOK, thread)
+}
+
+func (api *Api) GetThreadHistory(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+
+	revisions, err := api.usecase.GetThreadHistory(requestContext(ctx), slugOrID)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, revisions)
+}
+
+func (api *Api) RevertThread(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+	revert := new(models.Revert)
+	if err := readJSON(ctx, revert); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Revert(revert); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	thread, err := api.usecase.RevertThread(requestContext(ctx), slugOrID, revert)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, thread)
+}
 
+func (api *Api) GetPosts(ctx *fasthttp.RequestCtx) {
 	slugOrID := ctx.UserValue("slug_or_id").(string)
 	limit := ctx.QueryArgs().Peek("limit")
 	since := ctx.QueryArgs().Peek("since")
 	sort := ctx.QueryArgs().Peek("sort")
 	desc := ctx.QueryArgs().Peek("desc")
+	if err := validation.ThreadPostsQuery(limit, since, sort, desc); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	posts, statusCode := api.usecase.GetThreadPosts(&slugOrID, limit, since, sort, desc)
-
-	ctx.SetStatusCode(statusCode)
+	posts, err := api.usecase.GetThreadPosts(requestContext(ctx), &slugOrID, limit, since, sort, desc)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	switch statusCode {
-	case http.StatusOK:
-		if len(*posts) != 0 {
-			response, _ := easyjson.Marshal(posts)
-			_, _ = ctx.Write(response)
-		} else {
-			_, _ = ctx.Write([]byte("[]"))
-		}
-	case http.StatusNotFound:
-		response, _ := easyjson.Marshal(models.ErrorMessage(models.ThreadNotFound))
-		_, _ = ctx.Write(response)
-	case http.StatusInternalServerError:
-		response, _ := easyjson.Marshal(models.ErrorMessage(models.Conflict))
-		_, _ = ctx.Write(response)
+	if len(*posts) == 0 {
+		posts = &models.Posts{}
 	}
+	writeJSON(ctx, http.StatusOK, posts)
 }
 
 func (api *Api) Vote(ctx *fasthttp.RequestCtx) {
 	vote := new(models.Vote)
-	_ = easyjson.Unmarshal(ctx.PostBody(), vote)
+	if err := readJSON(ctx, vote); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Vote(vote); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
 	slugOrID := ctx.UserValue("slug_or_id")
 
-	var response []byte
-	thread, err := api.usecase.PutVote(slugOrID, vote)
+	thread, err := api.usecase.PutVote(requestContext(ctx), slugOrID, vote)
 	if err != nil {
-		ctx.SetStatusCode(http.StatusNotFound)
-		response, _ = easyjson.Marshal(models.ErrorMessage(models.ThreadNotFound))
-	} else {
-		ctx.SetStatusCode(http.StatusOK)
-		response, _ = easyjson.Marshal(thread)
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, thread)
+}
+
+func (api *Api) RetractVote(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+	nickname := ctx.QueryArgs().Peek("nickname")
+	if err := validation.RetractVote(nickname); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	thread, err := api.usecase.DeleteVote(requestContext(ctx), slugOrID, string(nickname))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
 	}
 
-	ctx.SetContentType("application/json")
-	_, _ = ctx.Write(response)
+	writeJSON(ctx, http.StatusOK, thread)
+}
+
+func (api *Api) GetVotes(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+	args := ctx.QueryArgs()
+	query, err := validation.VotersQuery(args.Peek("limit"), args.Peek("since"), args.Peek("desc"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	votes, err := api.usecase.GetThreadVotes(requestContext(ctx), slugOrID, query)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, votes)
 }
 
 func (api *Api) GetPostDetails(ctx *fasthttp.RequestCtx) {
-	ctx.SetContentType("application/json")
 	id := ctx.UserValue("id").(string)
 	related := ctx.QueryArgs().Peek("related")
+	if err := validation.Related(related); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	postDetails, statusCode := api.usecase.GetPostDetails(&id, related)
-	ctx.SetStatusCode(http.StatusOK)
-
-	switch statusCode {
-	case http.StatusOK:
-		response, _ := easyjson.Marshal(*postDetails)
-		_, _ = ctx.Write(response)
-	case http.StatusNotFound:
-		response, _ := easyjson.Marshal(models.ErrorMessage(models.PostNotFound))
-		_, _ = ctx.Write(response)
+	postDetails, err := api.usecase.GetPostDetails(requestContext(ctx), &id, related)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
 	}
+
+	writeJSON(ctx, http.StatusOK, postDetails)
 }
 
 func (api *Api) UpdatePost(ctx *fasthttp.RequestCtx) {
-	ctx.SetContentType("application/json")
 	id := ctx.UserValue("id").(string)
 	postUpd := new(models.PostUpdate)
+	if err := readJSON(ctx, postUpd); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.PostUpdate(postUpd); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	post, err := api.usecase.UpdatePostDetails(requestContext(ctx), &id, postUpd)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
 
-	_ = easyjson.Unmarshal(ctx.PostBody(), postUpd)
-	post, statusCode := api.usecase.UpdatePostDetails(&id, postUpd)
-	ctx.SetStatusCode(statusCode)
+	writeJSON(ctx, http.StatusOK, post)
+}
 
-	switch statusCode {
-	case http.StatusOK:
-		response, _ := easyjson.Marshal(post)
-		_, _ = ctx.Write(response)
-	case http.StatusNotFound:
-		response, _ := easyjson.Marshal(models.ErrorMessage(models.PostNotFound))
-		ctx.SetContentType("application/json")
-		_, _ = ctx.Write(response)
+func (api *Api) VotePost(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+	vote := new(models.Vote)
+	if err := readJSON(ctx, vote); err != nil {
+		api.writeError(ctx, err)
+		return
 	}
+	if err := validation.Vote(vote); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	post, err := api.usecase.PutPostVote(requestContext(ctx), &id, vote)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, post)
+}
+
+func (api *Api) GetPostHistory(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	revisions, err := api.usecase.GetPostHistory(requestContext(ctx), &id)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, revisions)
+}
+
+func (api *Api) RevertPost(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+	revert := new(models.Revert)
+	if err := readJSON(ctx, revert); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Revert(revert); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	post, err := api.usecase.RevertPost(requestContext(ctx), &id, revert)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, post)
+}
+
+func (api *Api) DeletePost(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	post, err := api.usecase.DeletePost(requestContext(ctx), &id)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, post)
+}
+
+func (api *Api) RestorePost(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	post, err := api.usecase.RestorePost(requestContext(ctx), &id)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, "restored post "+id)
+
+	writeJSON(ctx, http.StatusOK, post)
+}
+
+func (api *Api) PurgePost(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	purged, err := api.usecase.PurgePost(requestContext(ctx), &id)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, fmt.Sprintf("purged post %s and its replies, %d posts", id, purged))
+
+	writeJSON(ctx, http.StatusOK, models.Purge{Posts: purged})
+}
+
+// search
+
+func (api *Api) Search(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+	query, err := validation.SearchQuery(args.Peek("q"), args.Peek("forum"), args.Peek("author"), args.Peek("since"), args.Peek("limit"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	results, err := api.usecase.Search(requestContext(ctx), query)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, results)
+}
+
+// webhooks
+
+func (api *Api) CreateWebhook(ctx *fasthttp.RequestCtx) {
+	hook := new(models.Webhook)
+	if err := readJSON(ctx, hook); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	if err := validation.Webhook(hook); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	hook, err := api.usecase.CreateWebhook(requestContext(ctx), hook)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, fmt.Sprintf("created webhook %d to %s", hook.ID, hook.URL))
+
+	writeJSON(ctx, http.StatusCreated, hook)
+}
+
+func (api *Api) GetWebhooks(ctx *fasthttp.RequestCtx) {
+	hooks, err := api.usecase.GetWebhooks(requestContext(ctx))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, hooks)
+}
+
+func (api *Api) DeleteWebhook(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+
+	if err := api.usecase.DeleteWebhook(requestContext(ctx), id); err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+	api.admin.record(ctx, "deleted webhook "+id)
+
+	writeJSON(ctx, http.StatusOK, models.ErrorMsg{Message: "deleted"})
+}
+
+func (api *Api) GetWebhookDeliveries(ctx *fasthttp.RequestCtx) {
+	id := ctx.UserValue("id").(string)
+	args := ctx.QueryArgs()
+	query, err := validation.DeliveriesQuery(args.Peek("limit"), args.Peek("since"), args.Peek("state"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	deliveries, err := api.usecase.GetWebhookDeliveries(requestContext(ctx), id, query)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	writeJSON(ctx, http.StatusOK, deliveries)
 }
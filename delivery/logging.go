@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/logging"
+	"time"
+)
+
+const (
+	routeKey     = "forum.route"
+	requestIDKey = "forum.request_id"
+	userKey      = "forum.user"
+)
+
+// AccessLog writes one JSON line per request and assigns request IDs.
+type AccessLog struct {
+	log zerolog.Logger
+}
+
+func NewAccessLog(log zerolog.Logger) *AccessLog {
+	return &AccessLog{log: log}
+}
+
+// Handler wraps the router. It propagates a valid X-Request-ID from the
+// client or generates one, echoes it in the response and logs the request
+// once it has been served.
+func (access *AccessLog) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+
+		requestID := logging.NewRequestID()
+		if header := ctx.Request.Header.Peek(logging.RequestIDHeader); logging.ValidRequestID(header) {
+			requestID = string(header)
+		}
+		ctx.SetUserValue(requestIDKey, requestID)
+		ctx.Response.Header.Set(logging.RequestIDHeader, requestID)
+
+		next(ctx)
+
+		statusCode := ctx.Response.StatusCode()
+		event := access.log.Info()
+		if statusCode >= http.StatusInternalServerError {
+			event = access.log.Warn()
+		}
+		route, _ := ctx.UserValue(routeKey).(string)
+		if user, ok := ctx.UserValue(userKey).(string); ok {
+			event = event.Str("user", user)
+		}
+		// Reading the body of a stream would wait for all of it to be written.
+		if !ctx.Response.IsBodyStream() {
+			event = event.Int("bytes", len(ctx.Response.Body()))
+		}
+		event.
+			Str("request_id", requestID).
+			Bytes("method", ctx.Method()).
+			Str("route", route).
+			Bytes("path", ctx.Path()).
+			Int("status", statusCode).
+			Dur("latency_ms", time.Since(start)).
+			Msg("request")
+	}
+}
+
+// Route records the pattern handler was registered under, so requests are
+// logged by route rather than by raw path alone.
+func (access *AccessLog) Route(route string, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(routeKey, route)
+		handler(ctx)
+	}
+}
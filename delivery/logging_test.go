@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/logging"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		wantSame  bool
+	}{
+		{name: "propagates client id", requestID: "trace-42", wantSame: true},
+		{name: "generates missing id"},
+		{name: "replaces unprintable id", requestID: "bad id\x01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			access := NewAccessLog(zerolog.New(&out))
+			deadlines := Deadlines{Default: time.Second}
+
+			var seenByHandler string
+			route := "GET /api/forum/:slug/details"
+			handler := access.Handler(access.Route(route, deadlines.Wrap(route, func(ctx *fasthttp.RequestCtx) {
+				seenByHandler = logging.RequestID(requestContext(ctx))
+				ctx.SetStatusCode(http.StatusTeapot)
+			})))
+
+			var request fasthttp.Request
+			request.Header.SetMethod("GET")
+			request.SetRequestURI("/api/forum/pirates/details")
+			if tt.requestID != "" {
+				request.Header.Set(logging.RequestIDHeader, tt.requestID)
+			}
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Init(&request, nil, nil)
+			handler(ctx)
+
+			responseID := string(ctx.Response.Header.Peek(logging.RequestIDHeader))
+			if responseID == "" || responseID != seenByHandler {
+				t.Fatalf("response id %q, handler saw %q", responseID, seenByHandler)
+			}
+			if (responseID == tt.requestID) != tt.wantSame {
+				t.Errorf("response id %q for request id %q", responseID, tt.requestID)
+			}
+
+			var line struct {
+				RequestID string `json:"request_id"`
+				Route     string `json:"route"`
+				Status    int    `json:"status"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+				t.Fatalf("access log is not JSON: %s: %q", err, out.String())
+			}
+			if line.RequestID != responseID || line.Route != route || line.Status != http.StatusTeapot {
+				t.Errorf("unexpected access log line %+v", line)
+			}
+		})
+	}
+}
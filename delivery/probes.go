@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"context"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/models"
+	"time"
+)
+
+// Check is a dependency /readyz waits for; Run returns nil once it is usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Probes serves the liveness, readiness and build-info endpoints.
+type Probes struct {
+	timeout time.Duration
+	checks  []Check
+	build   models.BuildInfo
+}
+
+// NewProbes returns probes that give every readiness check together at most
+// timeout, or no limit beyond the request deadline when timeout is zero.
+func NewProbes(timeout time.Duration, build models.BuildInfo, checks ...Check) *Probes {
+	return &Probes{timeout: timeout, checks: checks, build: build}
+}
+
+// Health reports that the process is up and serving requests.
+func (probes *Probes) Health(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, http.StatusOK, models.Health{Status: "ok"})
+}
+
+// Ready runs every check and answers 503 if any of them fails.
+func (probes *Probes) Ready(ctx *fasthttp.RequestCtx) {
+	checkCtx := requestContext(ctx)
+	if probes.timeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(checkCtx, probes.timeout)
+		defer cancel()
+	}
+
+	readiness := models.Readiness{Status: "ok", Checks: make(map[string]string, len(probes.checks))}
+	for _, check := range probes.checks {
+		if err := check.Run(checkCtx); err != nil {
+			readiness.Status = "unavailable"
+			readiness.Checks[check.Name] = err.Error()
+			continue
+		}
+		readiness.Checks[check.Name] = "ok"
+	}
+
+	statusCode := http.StatusOK
+	if readiness.Status != "ok" {
+		statusCode = http.StatusServiceUnavailable
+	}
+	writeJSON(ctx, statusCode, readiness)
+}
+
+// Version reports what is running: commit, build time, Go and schema version.
+func (probes *Probes) Version(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, http.StatusOK, probes.build)
+}
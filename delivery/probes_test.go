@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"technopark-forum/models"
+	"testing"
+	"time"
+)
+
+func TestProbesReady(t *testing.T) {
+	ok := Check{Name: "database", Run: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "schema", Run: func(ctx context.Context) error { return errors.New("2 migrations pending") }}
+	slow := Check{Name: "database", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		want   int
+	}{
+		{name: "all checks pass", checks: []Check{ok}, want: http.StatusOK},
+		{name: "failing check", checks: []Check{ok, failing}, want: http.StatusServiceUnavailable},
+		{name: "check past the deadline", checks: []Check{slow}, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := NewProbes(10*time.Millisecond, models.BuildInfo{}, tt.checks...)
+			deadlines := Deadlines{Default: time.Second}
+			handler := deadlines.Wrap("GET /readyz", probes.Ready)
+
+			var request fasthttp.Request
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Init(&request, nil, nil)
+			handler(ctx)
+
+			if ctx.Response.StatusCode() != tt.want {
+				t.Errorf("status = %d, want %d", ctx.Response.StatusCode(), tt.want)
+			}
+			var readiness models.Readiness
+			if err := json.Unmarshal(ctx.Response.Body(), &readiness); err != nil {
+				t.Fatalf("body is not JSON: %s", err)
+			}
+			if len(readiness.Checks) != len(tt.checks) {
+				t.Errorf("checks = %v, want one entry per check", readiness.Checks)
+			}
+		})
+	}
+}
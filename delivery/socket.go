@@ -0,0 +1,248 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"github.com/fasthttp/websocket"
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strconv"
+	"technopark-forum/events"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+	"technopark-forum/validation"
+	"time"
+)
+
+const (
+	// socketWriteWait bounds every write to a socket; a client that does not
+	// take a message in that long is disconnected.
+	socketWriteWait = 10 * time.Second
+	// socketIdle is how long a socket may go without a message or a pong
+	// from its client. Sockets are pinged every heartbeat.
+	socketIdle = 3 * heartbeat
+	// socketLookupTimeout bounds looking up the topic of a subscription.
+	socketLookupTimeout = 5 * time.Second
+	// socketMaxRequest is the size of the largest request a client may send.
+	socketMaxRequest = 4096
+	// socketMaxTopics is how many topics one socket may follow.
+	socketMaxTopics = 100
+)
+
+var upgrader = websocket.FastHTTPUpgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// Clients are signed in by bearer tokens rather than cookies, so a page
+	// from another origin gains nothing by opening a socket.
+	CheckOrigin: func(ctx *fasthttp.RequestCtx) bool { return true },
+	Error: func(ctx *fasthttp.RequestCtx, status int, reason error) {
+		writeJSON(ctx, status, models.ErrorMessage(reason))
+	},
+}
+
+// Socket serves GET /ws as a WebSocket. The client subscribes to forums,
+// threads and users with SocketRequest messages and receives every Event
+// published on them; each request is answered with a SocketReply. A client
+// that falls more than events.DefaultBuffer batches of events behind is
+// disconnected with status 1013 (try again later).
+func (api *Api) Socket(ctx *fasthttp.RequestCtx) {
+	// The socket outlives the handler and so its request context; lookups get
+	// a context of their own, logged under the id of the upgrade request.
+	base := context.Background()
+	if requestID, ok := ctx.UserValue(requestIDKey).(string); ok {
+		base = logging.WithRequestID(base, requestID)
+	}
+	shutdown := ctx.Done()
+
+	_ = upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+		socket := &socket{
+			api:     api,
+			conn:    conn,
+			ctx:     base,
+			sub:     api.hub.Subscribe(),
+			replies: make(chan models.SocketReply, 1),
+			stopped: make(chan struct{}),
+		}
+		socket.serve(shutdown)
+	})
+}
+
+// socket is one client connection. Its reader handles requests and its writer
+// sends events, replies and pings, so a slow client never holds up the hub.
+type socket struct {
+	api     *Api
+	conn    *websocket.Conn
+	ctx     context.Context
+	sub     *events.Subscription
+	replies chan models.SocketReply
+	// stopped is closed once the writer is done.
+	stopped chan struct{}
+}
+
+func (socket *socket) serve(shutdown <-chan struct{}) {
+	defer socket.sub.Close()
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		socket.read()
+	}()
+	socket.write(readerDone, shutdown)
+	close(socket.stopped)
+	_ = socket.conn.Close()
+	<-readerDone
+}
+
+// read handles requests until the client goes away. A client sending requests
+// faster than it reads the replies is held up rather than buffered for.
+func (socket *socket) read() {
+	socket.conn.SetReadLimit(socketMaxRequest)
+	_ = socket.conn.SetReadDeadline(time.Now().Add(socketIdle))
+	socket.conn.SetPongHandler(func(string) error {
+		return socket.conn.SetReadDeadline(time.Now().Add(socketIdle))
+	})
+
+	for {
+		_, data, err := socket.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = socket.conn.SetReadDeadline(time.Now().Add(socketIdle))
+
+		select {
+		case socket.replies <- socket.handle(data):
+		case <-socket.stopped:
+			return
+		}
+	}
+}
+
+// write sends events and replies until the client goes away, falls behind or
+// the server shuts down.
+func (socket *socket) write(readerDone <-chan struct{}, shutdown <-chan struct{}) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case batch, ok := <-socket.sub.Events():
+			if !ok {
+				socket.close(websocket.CloseTryAgainLater, "too far behind")
+				return
+			}
+			for i := 0; i < len(batch) && err == nil; i++ {
+				err = socket.send(batch[i])
+			}
+		case reply := <-socket.replies:
+			err = socket.send(reply)
+		case <-ticker.C:
+			err = socket.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+		case <-readerDone:
+			return
+		case <-shutdown:
+			socket.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (socket *socket) send(message easyjson.Marshaler) error {
+	data, err := easyjson.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_ = socket.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return socket.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (socket *socket) close(code int, text string) {
+	_ = socket.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteWait))
+}
+
+// handle carries out a request and returns its reply.
+func (socket *socket) handle(data []byte) models.SocketReply {
+	request := new(models.SocketRequest)
+	if err := easyjson.Unmarshal(data, request); err != nil {
+		return socket.failed(request, models.FieldErrors{{Field: "body", Message: "is not valid JSON"}})
+	}
+	if err := validation.SocketRequest(request); err != nil {
+		return socket.failed(request, err)
+	}
+
+	topic, err := socket.topic(request)
+	if err != nil {
+		return socket.failed(request, err)
+	}
+	if request.Action == models.SocketUnsubscribe {
+		socket.sub.Unfollow(topic)
+		return models.SocketReply{Type: models.SocketUnsubscribed, Topic: request.Topic, Key: topic.Key}
+	}
+	if !socket.sub.Follows(topic) && socket.sub.Topics() >= socketMaxTopics {
+		return socket.failed(request, models.FieldErrors{{Field: "key", Message: "exceeds the " + strconv.Itoa(socketMaxTopics) + " topics a socket may follow"}})
+	}
+	socket.sub.Follow(topic)
+	return models.SocketReply{Type: models.SocketSubscribed, Topic: request.Topic, Key: topic.Key}
+}
+
+// topic returns the topic of request. Subscribing looks it up, so a client
+// learns about a typo; unsubscribing only needs to look up the id of a thread
+// given by slug.
+func (socket *socket) topic(request *models.SocketRequest) (events.Topic, error) {
+	if request.Action == models.SocketUnsubscribe {
+		switch request.Topic {
+		case models.TopicForum:
+			return events.ForumTopic(request.Key), nil
+		case models.TopicUser:
+			return events.UserTopic(request.Key), nil
+		}
+		if id, err := strconv.Atoi(request.Key); err == nil {
+			return events.ThreadTopic(id), nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(socket.ctx, socketLookupTimeout)
+	defer cancel()
+	switch request.Topic {
+	case models.TopicForum:
+		forum, err := socket.api.usecase.GetForum(ctx, request.Key)
+		if err != nil {
+			return events.Topic{}, err
+		}
+		return events.ForumTopic(forum.Slug), nil
+	case models.TopicThread:
+		thread, err := socket.api.usecase.GetThread(ctx, request.Key)
+		if err != nil {
+			return events.Topic{}, err
+		}
+		return events.ThreadTopic(thread.ID), nil
+	default:
+		user, err := socket.api.usecase.GetUserProfile(ctx, request.Key)
+		if err != nil {
+			return events.Topic{}, err
+		}
+		return events.UserTopic(user.Nickname), nil
+	}
+}
+
+// failed is the reply to a request that failed with err, reported as the
+// matching HTTP error would be.
+func (socket *socket) failed(request *models.SocketRequest, err error) models.SocketReply {
+	reply := models.SocketReply{Type: models.SocketError, Topic: request.Topic, Key: request.Key}
+	var fieldErrors models.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		reply.Status, reply.Message, reply.Errors = http.StatusBadRequest, "Invalid input", fieldErrors
+		return reply
+	}
+
+	reply.Status = errorStatus(err)
+	if reply.Status >= http.StatusInternalServerError {
+		logging.For(socket.ctx, socket.api.log).Error().Err(err).Int("status", reply.Status).Msg("socket request failed")
+	}
+	reply.Message = err.Error()
+	return reply
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"context"
+	"github.com/fasthttp/websocket"
+	"github.com/mailru/easyjson"
+	"github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"technopark-forum/auth"
+	"technopark-forum/events"
+	"technopark-forum/models"
+	"technopark-forum/repository"
+	"technopark-forum/usecase"
+	"testing"
+	"time"
+)
+
+func TestSocket(t *testing.T) {
+	service := usecase.NewForumService(repository.NewMemoryStorage(), zerolog.Nop())
+	hub := events.NewHub(events.DefaultBuffer)
+	service.SetPublisher(hub)
+	ctx := auth.WithUser(context.Background(), "jack")
+	if _, err := service.CreateUser(ctx, &models.User{Nickname: "jack", Email: "jack@sea.org", Fullname: "Jack", Password: "black pearl"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateForum(ctx, &models.Forum{Slug: "pirates", Title: "Pirates", Author: "jack"}); err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewApi(service, NewAdmin("", true, ioutil.Discard), hub, zerolog.Nop())
+	listener := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: api.Socket}
+	go func() { _ = server.Serve(listener) }()
+	defer func() { _ = server.Shutdown() }()
+
+	dialer := websocket.Dialer{NetDial: func(string, string) (net.Conn, error) { return listener.Dial() }}
+	conn, _, err := dialer.Dial("ws://forum/api/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	request := func(action, topic, key string) models.SocketReply {
+		t.Helper()
+		data, _ := easyjson.Marshal(models.SocketRequest{Action: action, Topic: topic, Key: key})
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			t.Fatal(err)
+		}
+		var reply models.SocketReply
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = easyjson.Unmarshal(data, &reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if reply := request(models.SocketSubscribe, models.TopicForum, "PIRATES"); reply.Type != models.SocketSubscribed || reply.Key != "pirates" {
+		t.Fatalf("subscribing to the forum replied %+v", reply)
+	}
+	if reply := request(models.SocketSubscribe, models.TopicUser, "bill"); reply.Type != models.SocketError || reply.Status != http.StatusNotFound {
+		t.Errorf("subscribing to a missing user replied %+v", reply)
+	}
+	if reply := request("follow", models.TopicThread, ""); reply.Status != http.StatusBadRequest || len(reply.Errors) != 2 {
+		t.Errorf("an invalid request replied %+v", reply)
+	}
+
+	thread, err := service.CreateThread(ctx, "pirates", &models.Thread{Title: "Treasure", Author: "jack", Message: "ahoy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event models.Event
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = easyjson.Unmarshal(data, &event); err != nil || event.Type != models.EventThread || event.Details == nil || event.Details.ID != thread.ID {
+		t.Errorf("received %s, %v, want the new thread", data, err)
+	}
+}
@@ -0,0 +1,141 @@
+package delivery
+
+import (
+	"bufio"
+	"github.com/mailru/easyjson"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"technopark-forum/events"
+	"technopark-forum/models"
+	"technopark-forum/validation"
+	"time"
+)
+
+// heartbeat is how often an idle stream sends a comment, so proxies keep the
+// connection open and a client that went away is noticed.
+const heartbeat = 15 * time.Second
+
+// streamWriteWait bounds every write to a stream; a client that does not take
+// an event in that long is disconnected.
+const streamWriteWait = 10 * time.Second
+
+// streamBacklog is the most missed posts a resuming stream is sent. A client
+// further behind gets a "resync" event instead and reloads the thread.
+const streamBacklog = 1000
+
+// StreamThread serves GET /thread/{slug_or_id}/stream as Server-Sent Events:
+// "post" for every new post, with the post id as the event id, and "edit" for
+// every edited one, without an id. A client reconnecting with Last-Event-ID
+// first gets the posts it missed, or "resync" if it missed more than
+// streamBacklog.
+func (api *Api) StreamThread(ctx *fasthttp.RequestCtx) {
+	slugOrID := ctx.UserValue("slug_or_id").(string)
+	lastID, err := validation.LastEventID(ctx.Request.Header.Peek("Last-Event-ID"))
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	thread, err := api.usecase.GetThread(requestContext(ctx), slugOrID)
+	if err != nil {
+		api.writeError(ctx, err)
+		return
+	}
+
+	// Subscribing before reading the backlog means no post falls between the
+	// two; the ones in both are skipped by their id.
+	sub := api.hub.Subscribe(events.ThreadTopic(thread.ID))
+	backlog := &models.Posts{}
+	if lastID > 0 {
+		if backlog, err = api.usecase.GetPostsAfter(requestContext(ctx), thread, lastID, streamBacklog+1); err != nil {
+			sub.Close()
+			api.writeError(ctx, err)
+			return
+		}
+	}
+	resync := len(*backlog) > streamBacklog
+	if resync {
+		backlog = &models.Posts{}
+	}
+
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	// The stream outlives the handler and so its deadline; it ends with the
+	// client, the subscription or the server.
+	shutdown := ctx.Done()
+	deadline := &writeDeadline{conn: ctx.Conn(), wait: streamWriteWait}
+	ctx.SetConnectionClose()
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		deadline.extend()
+		stream := eventStream{w: w, backlog: make(map[int]struct{}, len(*backlog))}
+		for i := range *backlog {
+			post := (*backlog)[i]
+			post.Parents = nil
+			stream.write(models.Event{Type: models.EventPost, Thread: thread.ID, Post: &post})
+			stream.backlog[post.ID] = struct{}{}
+		}
+		if resync {
+			_, _ = w.WriteString("event: resync\ndata: {}\n\n")
+		}
+		_, _ = w.WriteString(": connected\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case batch, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				deadline.extend()
+				for _, event := range batch {
+					stream.write(event)
+				}
+			case <-ticker.C:
+				deadline.extend()
+				_, _ = w.WriteString(": ping\n\n")
+			case <-shutdown:
+				return
+			}
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+// eventStream writes events in the text/event-stream format.
+type eventStream struct {
+	w *bufio.Writer
+	// backlog holds the ids of the posts sent from the backlog, which may be
+	// announced again by the subscription.
+	backlog map[int]struct{}
+}
+
+// write sends the post of a post or edit event unless it was sent from the
+// backlog. Only the backlog is checked: ids are taken before a batch commits,
+// so concurrent batches are announced out of id order and a newer id says
+// nothing about which posts were already sent. The rest of the events of the
+// thread are only pushed over sockets.
+func (stream *eventStream) write(event models.Event) {
+	if event.Post == nil || (event.Type != models.EventPost && event.Type != models.EventEdit) {
+		return
+	}
+	if event.Type == models.EventPost {
+		if _, sent := stream.backlog[event.Post.ID]; sent {
+			delete(stream.backlog, event.Post.ID)
+			return
+		}
+		_, _ = stream.w.WriteString("id: " + strconv.Itoa(event.Post.ID) + "\n")
+	}
+	data, _ := easyjson.Marshal(event.Post)
+	_, _ = stream.w.WriteString("event: " + event.Type + "\ndata: ")
+	_, _ = stream.w.Write(data)
+	_, _ = stream.w.WriteString("\n\n")
+}
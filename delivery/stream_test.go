@@ -0,0 +1,35 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"technopark-forum/models"
+	"testing"
+)
+
+func TestEventStream(t *testing.T) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	stream := eventStream{w: w, backlog: map[int]struct{}{5: {}, 6: {}}}
+
+	// Concurrent batches announce their posts out of id order, and the posts
+	// sent from the backlog may be announced again.
+	for _, id := range []int{6, 12, 10, 11, 7} {
+		stream.write(models.Event{Type: models.EventPost, Post: &models.Post{ID: id}})
+	}
+	stream.write(models.Event{Type: models.EventEdit, Post: &models.Post{ID: 5}})
+	stream.write(models.Event{Type: models.EventVote, Post: &models.Post{ID: 13}})
+	_ = w.Flush()
+
+	var sent []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "id: ") || strings.HasPrefix(line, "event: ") {
+			sent = append(sent, line)
+		}
+	}
+	want := []string{"id: 12", "event: post", "id: 10", "event: post", "id: 11", "event: post", "id: 7", "event: post", "event: edit"}
+	if strings.Join(sent, "|") != strings.Join(want, "|") {
+		t.Errorf("stream sent %v, want %v", sent, want)
+	}
+}
@@ -0,0 +1,166 @@
+// Package diff renders line-based unified diffs between two versions of a text.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround every change, as in diff -u.
+const contextLines = 3
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns the unified diff turning from into to, with name in the
+// file headers, or "" when they are equal.
+func Unified(name string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := script(lines(from), lines(to))
+
+	// fromLine[i] and toLine[i] count the lines of either side before ops[i].
+	fromLine := make([]int, len(ops)+1)
+	toLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if op.kind != '+' {
+			fromLine[i+1]++
+		}
+		if op.kind != '-' {
+			toLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	for start := 0; start < len(ops); {
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		// A hunk runs on while the unchanged stretches between changes are
+		// short enough for their context to overlap.
+		last := first
+		for i := first; i < len(ops) && i-last <= 2*contextLines; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		begin := maxInt(first-contextLines, start)
+		end := minInt(last+1+contextLines, len(ops))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[begin], fromLine[end]-fromLine[begin]),
+			hunkRange(toLine[begin], toLine[end]-toLine[begin]))
+		for _, op := range ops[begin:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of count lines after line before.
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+func lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// script returns the edit script turning from into to. The common prefix and
+// suffix are matched up front, so the quadratic part only sees what changed.
+func script(from []string, to []string) []op {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(from)+len(to))
+	for _, line := range from[:prefix] {
+		ops = append(ops, op{' ', line})
+	}
+	ops = append(ops, lcs(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix])...)
+	for _, line := range from[len(from)-suffix:] {
+		ops = append(ops, op{' ', line})
+	}
+	return ops
+}
+
+// lcs returns the edit script that keeps a longest common subsequence.
+func lcs(from []string, to []string) []op {
+	// common[i][j] is the length of the longest common subsequence of
+	// from[i:] and to[j:].
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = maxInt(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			ops = append(ops, op{' ', from[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			ops = append(ops, op{'-', from[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		ops = append(ops, op{'-', from[i]})
+	}
+	for ; j < len(to); j++ {
+		ops = append(ops, op{'+', to[j]})
+	}
+	return ops
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
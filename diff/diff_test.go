@@ -0,0 +1,46 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{name: "equal", from: "same", to: "same", want: ""},
+		{
+			name: "one line changed",
+			from: "ahoy",
+			to:   "avast",
+			want: "--- a/message\n+++ b/message\n@@ -1,1 +1,1 @@\n-ahoy\n+avast\n",
+		},
+		{
+			name: "from nothing",
+			from: "",
+			to:   "ahoy\nmatey",
+			want: "--- a/message\n+++ b/message\n@@ -0,0 +1,2 @@\n+ahoy\n+matey\n",
+		},
+		{
+			name: "context is limited to three lines",
+			from: "1\n2\n3\n4\n5\n6\n7\n8",
+			to:   "1\n2\n3\n4\n5\nsix\n7\n8",
+			want: "--- a/message\n+++ b/message\n@@ -3,6 +3,6 @@\n 3\n 4\n 5\n-6\n+six\n 7\n 8\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			from: "a\n1\n2\n3\n4\n5\n6\n7\nb",
+			to:   "A\n1\n2\n3\n4\n5\n6\n7\nB",
+			want: "--- a/message\n+++ b/message\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("message", tt.from, tt.to); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package events fans the events of forums, threads and users out to the
+// clients streaming them from this server instance.
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"technopark-forum/models"
+)
+
+// DefaultBuffer is how many batches of events a subscriber may fall behind by
+// before the hub gives up on it. A batch is what one Publish call delivers to
+// a subscriber, such as all the posts of a request, however many they are.
+const DefaultBuffer = 64
+
+// Topic is what a subscription follows: a forum by slug, a thread by id or a
+// user by nickname. Slugs and nicknames are case-insensitive, so build topics
+// with ForumTopic, ThreadTopic and UserTopic.
+type Topic struct {
+	Kind string
+	Key  string
+}
+
+func ForumTopic(slug string) Topic {
+	return Topic{Kind: models.TopicForum, Key: strings.ToLower(slug)}
+}
+
+func ThreadTopic(id int) Topic {
+	return Topic{Kind: models.TopicThread, Key: strconv.Itoa(id)}
+}
+
+func UserTopic(nickname string) Topic {
+	return Topic{Kind: models.TopicUser, Key: strings.ToLower(nickname)}
+}
+
+// Topics returns the topics event is published on.
+func Topics(event models.Event) []Topic {
+	topics := make([]Topic, 0, 3)
+	if event.Forum != "" {
+		topics = append(topics, ForumTopic(event.Forum))
+	}
+	if event.Thread != 0 {
+		topics = append(topics, ThreadTopic(event.Thread))
+	}
+	if event.User != "" {
+		topics = append(topics, UserTopic(event.User))
+	}
+	return topics
+}
+
+// Hub delivers every published event to the subscribers of its topics, once
+// per subscriber however many of them it follows. Publishing never waits for
+// a subscriber: one whose buffer is full is dropped, and its client is
+// expected to reconnect and catch up.
+type Hub struct {
+	mu     sync.Mutex
+	buffer int
+	subs   map[Topic]map[*Subscription]struct{}
+	open   map[*Subscription]struct{}
+}
+
+func NewHub(buffer int) *Hub {
+	return &Hub{
+		buffer: buffer,
+		subs:   make(map[Topic]map[*Subscription]struct{}),
+		open:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of the topics it follows until it is
+// closed.
+type Subscription struct {
+	hub    *Hub
+	topics map[Topic]struct{}
+	events chan []models.Event
+}
+
+// Subscribe starts receiving the events of topics; more can be followed later.
+func (hub *Hub) Subscribe(topics ...Topic) *Subscription {
+	sub := &Subscription{hub: hub, topics: make(map[Topic]struct{}), events: make(chan []models.Event, hub.buffer)}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.open[sub] = struct{}{}
+	for _, topic := range topics {
+		hub.follow(sub, topic)
+	}
+	return sub
+}
+
+// Publish hands each of events to the subscribers of its topics, in order and
+// as one batch per subscriber.
+func (hub *Hub) Publish(ctx context.Context, events ...models.Event) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	var order []*Subscription
+	batches := make(map[*Subscription][]models.Event)
+	for _, event := range events {
+		delivered := make(map[*Subscription]struct{})
+		for _, topic := range Topics(event) {
+			for sub := range hub.subs[topic] {
+				if _, ok := delivered[sub]; ok {
+					continue
+				}
+				delivered[sub] = struct{}{}
+				if _, ok := batches[sub]; !ok {
+					order = append(order, sub)
+				}
+				batches[sub] = append(batches[sub], event)
+			}
+		}
+	}
+	for _, sub := range order {
+		select {
+		case sub.events <- batches[sub]:
+		default:
+			hub.remove(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribers returns how many subscriptions are open.
+func (hub *Hub) Subscribers() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	return len(hub.open)
+}
+
+// follow adds topic to sub; callers hold the lock.
+func (hub *Hub) follow(sub *Subscription, topic Topic) {
+	if hub.subs[topic] == nil {
+		hub.subs[topic] = make(map[*Subscription]struct{})
+	}
+	hub.subs[topic][sub] = struct{}{}
+	sub.topics[topic] = struct{}{}
+}
+
+// unfollow removes topic from sub; callers hold the lock.
+func (hub *Hub) unfollow(sub *Subscription, topic Topic) {
+	delete(sub.topics, topic)
+	subs := hub.subs[topic]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(hub.subs, topic)
+	}
+}
+
+// remove closes sub unless it is closed already; callers hold the lock.
+func (hub *Hub) remove(sub *Subscription) {
+	if _, ok := hub.open[sub]; !ok {
+		return
+	}
+	delete(hub.open, sub)
+	for topic := range sub.topics {
+		hub.unfollow(sub, topic)
+	}
+	close(sub.events)
+}
+
+// Events receives the events of the subscription in batches, in the order
+// they were published. It is closed once the subscription is, including when
+// the hub drops it for falling behind.
+func (sub *Subscription) Events() <-chan []models.Event {
+	return sub.events
+}
+
+// Follow starts receiving the events of topic too. Following a topic again,
+// or after the subscription is closed, changes nothing.
+func (sub *Subscription) Follow(topic Topic) {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	if _, ok := sub.hub.open[sub]; ok {
+		sub.hub.follow(sub, topic)
+	}
+}
+
+// Unfollow stops receiving the events of topic, unless they come through
+// another topic the subscription follows.
+func (sub *Subscription) Unfollow(topic Topic) {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	sub.hub.unfollow(sub, topic)
+}
+
+// Follows reports whether the subscription follows topic.
+func (sub *Subscription) Follows(topic Topic) bool {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	_, ok := sub.topics[topic]
+	return ok
+}
+
+// Topics returns how many topics the subscription follows.
+func (sub *Subscription) Topics() int {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	return len(sub.topics)
+}
+
+// Close stops the subscription; closing it again does nothing.
+func (sub *Subscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	sub.hub.remove(sub)
+}
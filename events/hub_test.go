@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"technopark-forum/models"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub(2)
+	first := hub.Subscribe(ThreadTopic(1))
+	second := hub.Subscribe(ThreadTopic(1))
+	other := hub.Subscribe(ThreadTopic(2))
+
+	_ = hub.Publish(context.Background(), models.Event{Type: models.EventPost, Thread: 1, Post: &models.Post{ID: 10}})
+	for _, sub := range []*Subscription{first, second} {
+		if batch := <-sub.Events(); len(batch) != 1 || batch[0].Post.ID != 10 {
+			t.Errorf("received %+v, want post 10", batch)
+		}
+	}
+	select {
+	case batch := <-other.Events():
+		t.Errorf("subscriber of thread 2 received %+v", batch)
+	default:
+	}
+
+	// second stops reading and is dropped once its buffer is full.
+	for id := 11; id <= 13; id++ {
+		_ = hub.Publish(context.Background(), models.Event{Type: models.EventPost, Thread: 1, Post: &models.Post{ID: id}})
+		<-first.Events()
+	}
+	received := 0
+	for range second.Events() {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("dropped subscriber received %d batches before its channel closed, want 2", received)
+	}
+	if got := hub.Subscribers(); got != 2 {
+		t.Errorf("Subscribers() = %d after dropping one, want 2", got)
+	}
+
+	first.Close()
+	first.Close()
+	second.Close()
+	other.Close()
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d after closing all, want 0", got)
+	}
+}
+
+func TestHubTopics(t *testing.T) {
+	hub := NewHub(DefaultBuffer)
+	sub := hub.Subscribe(ForumTopic("Pirates"), ThreadTopic(1))
+	defer sub.Close()
+
+	// An event of a followed forum and thread arrives once.
+	_ = hub.Publish(context.Background(), models.Event{Type: models.EventPost, Forum: "pirates", Thread: 1, Post: &models.Post{ID: 10}})
+	_ = hub.Publish(context.Background(), models.Event{Type: models.EventVote, User: "Jack"})
+	sub.Follow(UserTopic("jack"))
+	sub.Unfollow(ForumTopic("PIRATES"))
+	_ = hub.Publish(context.Background(), models.Event{Type: models.EventThread, Forum: "pirates", Thread: 2, User: "JACK"})
+	_ = hub.Publish(context.Background(), models.Event{Type: models.EventThread, Forum: "pirates", Thread: 3, User: "bill"})
+
+	var received []string
+	for len(sub.Events()) > 0 {
+		for _, event := range <-sub.Events() {
+			received = append(received, event.Type)
+		}
+	}
+	if len(received) != 2 || received[0] != models.EventPost || received[1] != models.EventThread {
+		t.Errorf("received %v, want [post thread]", received)
+	}
+	if sub.Topics() != 2 || !sub.Follows(UserTopic("Jack")) || sub.Follows(ForumTopic("pirates")) {
+		t.Errorf("subscription follows %d topics, want thread 1 and user jack", sub.Topics())
+	}
+}
+
+func TestHubBatches(t *testing.T) {
+	hub := NewHub(2)
+	sub := hub.Subscribe(ThreadTopic(1))
+	defer sub.Close()
+
+	// A batch far larger than the buffer is one delivery, not a reason to
+	// drop the subscriber.
+	var posts []models.Event
+	for id := 1; id <= 100; id++ {
+		posts = append(posts, models.Event{Type: models.EventPost, Thread: 1, Post: &models.Post{ID: id}})
+	}
+	_ = hub.Publish(context.Background(), append(posts, models.Event{Type: models.EventPost, Thread: 2, Post: &models.Post{ID: 101}})...)
+
+	batch, ok := <-sub.Events()
+	if !ok || len(batch) != 100 || batch[0].Post.ID != 1 || batch[99].Post.ID != 100 {
+		t.Fatalf("received %d events, want posts 1 to 100 in order", len(batch))
+	}
+	if got := hub.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d after a large batch, want 1", got)
+	}
+}
@@ -0,0 +1,291 @@
+// Package feeds renders the feeds of forums and threads as RSS 2.0 and Atom
+// 1.0 documents.
+package feeds
+
+import (
+	"encoding/xml"
+	"html"
+	"net"
+	"strconv"
+	"strings"
+	"technopark-forum/models"
+	"time"
+)
+
+// Formats and their media types.
+const (
+	RSS  = "rss"
+	Atom = "atom"
+
+	RSSType  = "application/rss+xml"
+	AtomType = "application/atom+xml"
+)
+
+// tagDate dates the tag URIs identifying threads and posts. It must never
+// change, or every feed reader would see every entry as new.
+const tagDate = "2018"
+
+// Site is where a feed is served from. Links point at the API of the site,
+// and ids are tag URIs of its host name, so they stay the same whether a
+// feed is fetched over http or https.
+type Site struct {
+	Scheme string
+	Host   string
+}
+
+func (site Site) url(path string) string {
+	return site.Scheme + "://" + site.Host + path
+}
+
+func (site Site) tag(specific string) string {
+	host := site.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return "tag:" + strings.ToLower(host) + "," + tagDate + ":" + specific
+}
+
+// Updated is when feed last changed: the newest creation or edit among its
+// entries, or the creation of the thread of a feed without posts. It is the
+// zero time for a forum without threads.
+func Updated(feed *models.Feed) time.Time {
+	var updated time.Time
+	if feed.Thread != nil {
+		updated = feed.Thread.Created
+	}
+	for _, thread := range feed.Threads {
+		updated = latest(updated, entryUpdated(feed, thread.ID, thread.Created))
+	}
+	for _, post := range feed.Posts {
+		updated = latest(updated, entryUpdated(feed, post.ID, post.Created))
+	}
+	return updated
+}
+
+// Render returns feed in format, served at self.
+func Render(feed *models.Feed, format string, site Site, self string) ([]byte, error) {
+	doc := document(feed, site)
+	doc.self = site.url(self)
+
+	var root interface{}
+	if format == RSS {
+		root = doc.rss()
+	} else {
+		root = doc.atom()
+	}
+	body, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// feedDocument is a feed in the terms both formats share.
+type feedDocument struct {
+	id, title, subtitle, link, self string
+	updated                         time.Time
+	entries                         []feedEntry
+}
+
+type feedEntry struct {
+	id, title, author, content, link string
+	published, updated               time.Time
+}
+
+func document(feed *models.Feed, site Site) *feedDocument {
+	doc := &feedDocument{updated: Updated(feed)}
+	if doc.updated.IsZero() {
+		doc.updated = time.Unix(0, 0)
+	}
+
+	if feed.Thread == nil {
+		slug := feed.Forum.Slug
+		doc.id = site.tag("forum/" + strings.ToLower(slug))
+		doc.title = feed.Forum.Title
+		doc.subtitle = "Latest threads in " + feed.Forum.Title
+		doc.link = site.url("/api/forum/" + slug + "/details")
+		for _, thread := range feed.Threads {
+			id := strconv.Itoa(thread.ID)
+			doc.entries = append(doc.entries, feedEntry{
+				id:        site.tag("thread/" + id),
+				title:     thread.Title,
+				author:    thread.Author,
+				content:   thread.Message,
+				link:      site.url("/api/thread/" + id + "/details"),
+				published: thread.Created,
+				updated:   entryUpdated(feed, thread.ID, thread.Created),
+			})
+		}
+		return doc
+	}
+
+	thread := strconv.Itoa(feed.Thread.ID)
+	doc.id = site.tag("thread/" + thread + "/posts")
+	doc.title = feed.Thread.Title
+	doc.subtitle = "Latest posts in " + feed.Thread.Title
+	doc.link = site.url("/api/thread/" + thread + "/details")
+	for _, post := range feed.Posts {
+		id := strconv.Itoa(post.ID)
+		doc.entries = append(doc.entries, feedEntry{
+			id:        site.tag("post/" + id),
+			title:     "Re: " + feed.Thread.Title,
+			author:    post.Author,
+			content:   post.Message,
+			link:      site.url("/api/post/" + id + "/details"),
+			published: post.Created,
+			updated:   entryUpdated(feed, post.ID, post.Created),
+		})
+	}
+	return doc
+}
+
+// entryUpdated is when the thread or post with id was last edited, or
+// created if it never was.
+func entryUpdated(feed *models.Feed, id int, created time.Time) time.Time {
+	return latest(created, feed.Edited[id])
+}
+
+func latest(lhs, rhs time.Time) time.Time {
+	if rhs.After(lhs) {
+		return rhs
+	}
+	return lhs
+}
+
+// Atom 1.0, RFC 4287
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Author    atomPerson `xml:"author"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Link      atomLink   `xml:"link"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+func (doc *feedDocument) atom() *atomFeed {
+	feed := &atomFeed{
+		ID:       doc.id,
+		Title:    doc.title,
+		Subtitle: doc.subtitle,
+		Updated:  atomTime(doc.updated),
+		Links: []atomLink{
+			{Rel: "self", Type: AtomType, Href: doc.self},
+			{Rel: "alternate", Type: "application/json", Href: doc.link},
+		},
+	}
+	for _, entry := range doc.entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        entry.id,
+			Title:     entry.title,
+			Author:    atomPerson{Name: entry.author},
+			Published: atomTime(entry.published),
+			Updated:   atomTime(entry.updated),
+			Link:      atomLink{Rel: "alternate", Type: "application/json", Href: entry.link},
+			Content:   atomText{Type: "text", Text: entry.content},
+		})
+	}
+	return feed
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// RSS 2.0, with the Atom self link and entry update times and the Dublin Core
+// creator, as RSS has no place for either and wants authors as e-mails.
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Creator     string  `xml:"dc:creator"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Updated     string  `xml:"atom:updated"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+func (doc *feedDocument) rss() *rssFeed {
+	feed := &rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         doc.title,
+			Link:          doc.link,
+			Description:   doc.subtitle,
+			LastBuildDate: rssTime(doc.updated),
+			Self:          atomLink{Rel: "self", Type: RSSType, Href: doc.self},
+		},
+	}
+	for _, entry := range doc.entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       entry.title,
+			Link:        entry.link,
+			Description: rssHTML(entry.content),
+			Creator:     entry.author,
+			GUID:        rssGUID{IsPermaLink: "false", ID: entry.id},
+			PubDate:     rssTime(entry.published),
+			Updated:     atomTime(entry.updated),
+		})
+	}
+	return feed
+}
+
+func rssTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+// rssHTML turns a message into the HTML RSS readers take descriptions for,
+// so a message mentioning tags shows them rather than being marked up.
+func rssHTML(message string) string {
+	return strings.ReplaceAll(html.EscapeString(message), "\n", "<br>\n")
+}
@@ -0,0 +1,97 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"technopark-forum/models"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	edited := created.Add(2 * time.Hour)
+	feed := &models.Feed{
+		Thread: &models.Thread{ID: 3, Title: "Treasure", Created: created},
+		Posts: models.Posts{
+			{ID: 8, Author: "will", Message: "<b>aye</b>\nmatey", Created: created.Add(time.Hour)},
+			{ID: 7, Author: "jack", Message: "ahoy", Created: created},
+		},
+		Edited: map[int]time.Time{7: edited},
+	}
+	if got := Updated(feed); !got.Equal(edited) {
+		t.Errorf("Updated = %s, want the edit at %s", got, edited)
+	}
+	site := Site{Scheme: "https", Host: "Forum.Example.com:443"}
+
+	var atom struct {
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Title     string `xml:"title"`
+			Author    string `xml:"author>name"`
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Content   string `xml:"content"`
+		} `xml:"entry"`
+	}
+	body, err := Render(feed, Atom, site, "/api/thread/3/feed.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = xml.Unmarshal(body, &atom); err != nil {
+		t.Fatalf("Atom feed does not parse: %s\n%s", err, body)
+	}
+	if atom.ID != "tag:forum.example.com,2018:thread/3/posts" || atom.Updated != "2026-03-01T14:00:00Z" || len(atom.Entries) != 2 {
+		t.Fatalf("Atom feed = %+v", atom)
+	}
+	if entry := atom.Entries[1]; entry.ID != "tag:forum.example.com,2018:post/7" || entry.Title != "Re: Treasure" || entry.Author != "jack" ||
+		entry.Published != "2026-03-01T12:00:00Z" || entry.Updated != "2026-03-01T14:00:00Z" {
+		t.Errorf("edited Atom entry = %+v", entry)
+	}
+	if content := atom.Entries[0].Content; content != "<b>aye</b>\nmatey" {
+		t.Errorf("Atom content = %q, want the message as text", content)
+	}
+
+	var rss struct {
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				GUID        string `xml:"guid"`
+				Description string `xml:"description"`
+				PubDate     string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if body, err = Render(feed, RSS, site, "/api/thread/3/feed.rss"); err != nil {
+		t.Fatal(err)
+	}
+	if err = xml.Unmarshal(body, &rss); err != nil {
+		t.Fatalf("RSS feed does not parse: %s\n%s", err, body)
+	}
+	if rss.Channel.LastBuildDate != "Sun, 01 Mar 2026 14:00:00 +0000" || len(rss.Channel.Items) != 2 {
+		t.Fatalf("RSS feed = %+v", rss)
+	}
+	if item := rss.Channel.Items[0]; item.GUID != "tag:forum.example.com,2018:post/8" || item.PubDate != "Sun, 01 Mar 2026 13:00:00 +0000" ||
+		item.Description != "&lt;b&gt;aye&lt;/b&gt;<br>\nmatey" {
+		t.Errorf("RSS item = %+v", item)
+	}
+}
+
+func TestRenderEmptyForum(t *testing.T) {
+	feed := &models.Feed{Forum: &models.Forum{Slug: "Pirates", Title: "Pirates"}}
+	if got := Updated(feed); !got.IsZero() {
+		t.Errorf("Updated of an empty forum = %s, want zero", got)
+	}
+	var atom struct {
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+	}
+	body, err := Render(feed, Atom, Site{Scheme: "http", Host: "localhost"}, "/api/forum/Pirates/feed.atom")
+	if err != nil || xml.Unmarshal(body, &atom) != nil {
+		t.Fatalf("Render = %s, %v", body, err)
+	}
+	if atom.ID != "tag:localhost,2018:forum/pirates" || atom.Updated != "1970-01-01T00:00:00Z" {
+		t.Errorf("empty forum feed = %+v", atom)
+	}
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"io"
+	"time"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied IDs so they cannot bloat logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// New returns a JSON logger writing to out at the named level.
+func New(out io.Writer, level string) (zerolog.Logger, error) {
+	parsed, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return zerolog.Nop(), errors.Wrapf(err, "log level %q", level)
+	}
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	return zerolog.New(out).Level(parsed).With().Timestamp().Logger(), nil
+}
+
+// WithRequestID attaches id to ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID attached to ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// For returns logger annotated with the request ID of ctx, so every line
+// written while serving a request can be correlated.
+func For(ctx context.Context, logger zerolog.Logger) *zerolog.Logger {
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With().Str("request_id", id).Logger()
+	}
+	return &logger
+}
+
+// NewRequestID returns a random 128-bit hex ID.
+func NewRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// ValidRequestID reports whether a client-supplied ID may be propagated:
+// non-empty, bounded and made of printable ASCII without spaces.
+func ValidRequestID(id []byte) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
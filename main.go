@@ -1,28 +1,55 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/buaazp/fasthttprouter"
 	"github.com/jackc/pgx"
+	"github.com/rs/zerolog"
 	"github.com/valyala/fasthttp"
-	"log"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"technopark-forum/config"
 	"technopark-forum/delivery"
+	"technopark-forum/events"
+	"technopark-forum/logging"
+	"technopark-forum/metrics"
+	"technopark-forum/migrate"
+	"technopark-forum/models"
 	"technopark-forum/repository"
 	"technopark-forum/usecase"
+	"technopark-forum/webhooks"
+	"text/tabwriter"
+	"time"
 )
 
-var defaultDBConfig = pgx.ConnPoolConfig{
-	ConnConfig: pgx.ConnConfig{
-		Host:     "127.0.0.1",
-		Port:     5432,
-		Database: "docker",
-		User:     "docker",
-		Password: "docker",
-	},
-	MaxConnections: 100,
-}
+// commit and buildTime are set at link time:
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+func initDB(cfg *config.Config, logger pgx.Logger) (*pgx.ConnPool, error) {
+	poolConfig, err := cfg.PoolConfig()
+	if err != nil {
+		return nil, err
+	}
+	if logger != nil {
+		poolConfig.Logger = logger
+		poolConfig.LogLevel = pgx.LogLevelInfo
+	}
 
-func initDB() (*pgx.ConnPool, error) {
-	db, err := pgx.NewConnPool(defaultDBConfig)
+	db, err := pgx.NewConnPool(poolConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -30,55 +57,523 @@ func initDB() (*pgx.ConnPool, error) {
 	return db, nil
 }
 
-func initRouter(api *delivery.Api) *fasthttprouter.Router {
+func initRouter(api *delivery.Api, admin *delivery.Admin, probes *delivery.Probes, deadlines delivery.Deadlines, monitoring *metrics.Metrics, access *delivery.AccessLog) *fasthttprouter.Router {
 	router := fasthttprouter.New()
+	register := func(method, path string, handler fasthttp.RequestHandler) {
+		route := method + " " + path
+		handler = access.Route(route, deadlines.Wrap(route, handler))
+		router.Handle(method, path, monitoring.Instrument(route, handler))
+	}
+	// handle serves user requests, signed in by their bearer token if any;
+	// handleAdmin serves the endpoints whose bearer token is the admin one.
+	handle := func(method, path string, handler fasthttp.RequestHandler) {
+		register(method, path, api.Authenticate(handler))
+	}
+	handleAdmin := func(method, path string, handler fasthttp.RequestHandler) {
+		register(method, path, admin.Protect(handler))
+	}
+
+	router.GET("/metrics", monitoring.Handler())
+
+	// probes
+	register("GET", "/healthz", probes.Health)
+	register("GET", "/readyz", probes.Ready)
+	register("GET", "/version", probes.Version)
 
 	// service
-	router.GET("/api/service/status", api.GetStatus)
-	router.POST("/api/service/clear", api.Clear)
+	handleAdmin("GET", "/api/service/status", api.GetStatus)
+	handleAdmin("POST", "/api/service/clear", api.Clear)
+
+	// auth
+	register("POST", "/api/auth/login", api.Login)
+	register("POST", "/api/auth/logout", api.Logout)
 
 	// user
-	router.POST("/api/user/:nickname/create", api.CreateUser)
-	router.GET("/api/user/:nickname/profile", api.GetUserProfile)
-	router.POST("/api/user/:nickname/profile", api.UpdateUserProfile)
+	handle("POST", "/api/user/:nickname/create", api.CreateUser)
+	handle("GET", "/api/user/:nickname/profile", api.GetUserProfile)
+	handle("POST", "/api/user/:nickname/profile", api.UpdateUserProfile)
+	handleAdmin("POST", "/api/user/:nickname/role", api.SetUserRole)
 
 	// forum
-	router.POST("/api/forum/:slug", api.CreateForum)
-	router.GET("/api/forum/:slug/details", api.GetForum)
-	router.POST("/api/forum/:slug/create", api.CreateThread)
-	router.GET("/api/forum/:slug/users", api.GetUsers)
-	router.GET("/api/forum/:slug/threads", api.GetThreads)
+	handle("POST", "/api/forum/:slug", api.CreateForum)
+	handle("GET", "/api/forum/:slug/details", api.GetForum)
+	handle("POST", "/api/forum/:slug/create", api.CreateThread)
+	handle("GET", "/api/forum/:slug/users", api.GetUsers)
+	handle("GET", "/api/forum/:slug/threads", api.GetThreads)
+	handle("GET", "/api/forum/:slug/leaderboard", api.GetLeaderboard)
+	handle("GET", "/api/forum/:slug/moderators", api.GetModerators)
+	handleAdmin("GET", "/api/forum/:slug/export", api.ExportForum)
+	handle("GET", "/api/forum/:slug/feed", api.ForumFeed)
+	handle("GET", "/api/forum/:slug/feed.rss", api.ForumFeed)
+	handle("GET", "/api/forum/:slug/feed.atom", api.ForumFeed)
+	handle("POST", "/api/forum/:slug/moderators/:nickname", api.AddModerator)
+	handle("DELETE", "/api/forum/:slug/moderators/:nickname", api.RemoveModerator)
 
 	// thread
-	router.POST("/api/thread/:slug_or_id/create", api.CreatePosts)
-	router.GET("/api/thread/:slug_or_id/details", api.GetThread)
-	router.POST("/api/thread/:slug_or_id/details", api.UpdateThread)
-	router.GET("/api/thread/:slug_or_id/posts", api.GetPosts)
-	router.POST("/api/thread/:slug_or_id/vote", api.Vote)
+	handle("POST", "/api/thread/:slug_or_id/create", api.CreatePosts)
+	handle("GET", "/api/thread/:slug_or_id/details", api.GetThread)
+	handle("POST", "/api/thread/:slug_or_id/details", api.UpdateThread)
+	handle("GET", "/api/thread/:slug_or_id/history", api.GetThreadHistory)
+	handle("POST", "/api/thread/:slug_or_id/revert", api.RevertThread)
+	handle("GET", "/api/thread/:slug_or_id/posts", api.GetPosts)
+	handle("GET", "/api/thread/:slug_or_id/stream", api.StreamThread)
+	handle("GET", "/api/thread/:slug_or_id/feed", api.ThreadFeed)
+	handle("GET", "/api/thread/:slug_or_id/feed.rss", api.ThreadFeed)
+	handle("GET", "/api/thread/:slug_or_id/feed.atom", api.ThreadFeed)
+	handle("POST", "/api/thread/:slug_or_id/vote", api.Vote)
+	handle("DELETE", "/api/thread/:slug_or_id/vote", api.RetractVote)
+	handle("GET", "/api/thread/:slug_or_id/votes", api.GetVotes)
+	handle("POST", "/api/thread/:slug_or_id/state", api.SetThreadState)
+	handleAdmin("POST", "/api/thread/:slug_or_id/restore", api.RestoreThread)
 
 	// post
-	router.GET("/api/post/:id/details", api.GetPostDetails)
-	router.POST("/api/post/:id/details", api.UpdatePost)
+	handle("GET", "/api/post/:id/details", api.GetPostDetails)
+	handle("POST", "/api/post/:id/details", api.UpdatePost)
+	handle("GET", "/api/post/:id/history", api.GetPostHistory)
+	handle("POST", "/api/post/:id/revert", api.RevertPost)
+	handle("POST", "/api/post/:id/vote", api.VotePost)
+	handle("DELETE", "/api/post/:id", api.DeletePost)
+	handleAdmin("POST", "/api/post/:id/restore", api.RestorePost)
+	handleAdmin("POST", "/api/post/:id/purge", api.PurgePost)
+
+	// search
+	handle("GET", "/api/search", api.Search)
+
+	// live events
+	handle("GET", "/api/ws", api.Socket)
+
+	// webhooks
+	handleAdmin("POST", "/api/webhooks", api.CreateWebhook)
+	handleAdmin("GET", "/api/webhooks", api.GetWebhooks)
+	handleAdmin("DELETE", "/api/webhooks/:id", api.DeleteWebhook)
+	handleAdmin("GET", "/api/webhooks/:id/deliveries", api.GetWebhookDeliveries)
 
 	return router
 }
 
-func main() {
-	db, err := initDB()
+// initRepository opens the configured storage and returns the checks /readyz
+// runs against it.
+func initRepository(cfg *config.Config, monitoring *metrics.Metrics, logger zerolog.Logger) (repository.ForumRepository, []delivery.Check, func(), error) {
+	switch cfg.Storage {
+	case "postgres":
+		db, err := initDB(cfg, monitoring.QueryLogger(repository.StatementName))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err = checkMigrations(db); err != nil {
+			db.Close()
+			return nil, nil, nil, err
+		}
+		checks, err := databaseChecks(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, nil, err
+		}
+		monitoring.WatchPool(db)
+		return repository.NewForumStorage(db, logger), checks, db.Close, nil
+	case "memory":
+		return repository.NewMemoryStorage(), nil, func() {}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown storage %q, expected postgres or memory", cfg.Storage)
+	}
+}
+
+// databaseChecks reports ready once a pool connection answers a ping and the
+// schema is at the version this binary was built for.
+func databaseChecks(db *pgx.ConnPool) ([]delivery.Check, error) {
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return []delivery.Check{
+		{Name: "database", Run: func(ctx context.Context) error {
+			conn, err := db.AcquireEx(ctx)
+			if err != nil {
+				return err
+			}
+			defer db.Release(conn)
+			return conn.Ping(ctx)
+		}},
+		{Name: "migrations", Run: func(ctx context.Context) error {
+			current, err := migrator.Current(ctx)
+			if err != nil {
+				return err
+			}
+			if current != migrator.Latest() {
+				return fmt.Errorf("schema is at version %d, expected %d", current, migrator.Latest())
+			}
+			return nil
+		}},
+	}, nil
+}
+
+func initAuditLog(cfg *config.Config) (io.Writer, func(), error) {
+	if cfg.Admin.AuditLog == "" {
+		return os.Stderr, func() {}, nil
+	}
+	file, err := os.OpenFile(cfg.Admin.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, func() { _ = file.Close() }, nil
+}
+
+// checkMigrations refuses to serve a database whose schema is behind the binary.
+func checkMigrations(db *pgx.ConnPool) error {
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(context.Background())
 	if err != nil {
-		log.Fatalf("initDB failed: %s", err.Error())
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d schema migrations are pending, run \"migrate up\" first", len(pending))
+	}
+	return nil
+}
+
+// runExport implements "export <slug> <file>", writing the forum export
+// GET /api/forum/{slug}/export serves to file. The file is removed if the
+// export fails or is interrupted.
+func runExport(cfg *config.Config, args []string, logger zerolog.Logger) error {
+	if cfg.Storage != "postgres" {
+		return fmt.Errorf("exports only read postgres storage, got %q", cfg.Storage)
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export <slug> <file>")
+	}
+	slug, path := args[0], args[1]
+
+	db, err := initDB(cfg, nil)
+	if err != nil {
+		return err
 	}
 	defer db.Close()
+	if err = checkMigrations(db); err != nil {
+		return err
+	}
+	service := usecase.NewForumService(repository.NewForumStorage(db, logger), logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if _, err = service.GetForum(ctx, slug); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := &countingWriter{w: file}
+	buffered := bufio.NewWriterSize(writer, 64<<10)
+	err = service.ExportForum(ctx, slug, buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	logger.Info().Str("forum", slug).Str("file", path).Int64("bytes", writer.n).Msg("exported forum")
+	return nil
+}
 
-	repo := repository.NewForumStorage(db)
-	service := usecase.NewForumService(repo)
-	api := delivery.NewApi(service)
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	router := initRouter(api)
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.w.Write(p)
+	writer.n += int64(n)
+	return n, err
+}
+
+// runMigrate implements "migrate up", "migrate down [steps]" and "migrate status".
+func runMigrate(cfg *config.Config, args []string, logger zerolog.Logger) error {
+	if cfg.Storage != "postgres" {
+		return fmt.Errorf("migrations only apply to postgres storage, got %q", cfg.Storage)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status")
+	}
 
-	log.Println("server start on 5000 port")
-	err = fasthttp.ListenAndServe(":5000", router.Handler)
+	db, err := initDB(cfg, nil)
 	if err != nil {
-		log.Fatalf("server failed: %s", err.Error())
+		return err
 	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			logger.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("applied migration")
+		}
+		if err == nil && len(applied) == 0 {
+			logger.Info().Msg("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			logger.Info().Int("version", migration.Version).Str("name", migration.Name).Msg("rolled back migration")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, status.State(), appliedAt)
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// relayEvents feeds hub with the events every instance announces through
+// Postgres until ctx is done, listening again a second after a failure.
+func relayEvents(ctx context.Context, storage *repository.Storage, service *usecase.Service, hub *events.Hub, logger zerolog.Logger) {
+	for {
+		err := storage.Listen(ctx, func(batch []models.Event) {
+			loaded, err := service.LoadEvents(ctx, batch)
+			if err != nil {
+				logger.Warn().Err(err).Str("event", batch[0].Type).Int("thread", batch[0].Thread).Int("events", len(batch)).Msg("dropping events")
+				return
+			}
+			_ = hub.Publish(ctx, loaded...)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error().Err(err).Msg("event listener failed")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// buildInfo describes this binary for /version.
+func buildInfo() models.BuildInfo {
+	info := models.BuildInfo{Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if migrator, err := migrate.New(nil); err == nil {
+		info.SchemaVersion = migrator.Latest()
+	}
+	return info
+}
+
+// serverLogger routes fasthttp's own messages into the structured log.
+type serverLogger struct {
+	logger zerolog.Logger
+}
+
+func (logger serverLogger) Printf(format string, args ...interface{}) {
+	logger.logger.Warn().Msgf(format, args...)
+}
+
+func initServer(cfg *config.Config, handler fasthttp.RequestHandler, logger zerolog.Logger) *fasthttp.Server {
+	return &fasthttp.Server{
+		Handler:            handler,
+		Logger:             serverLogger{logger},
+		ReadTimeout:        cfg.Server.ReadTimeout.Duration,
+		WriteTimeout:       cfg.Server.WriteTimeout.Duration,
+		Concurrency:        cfg.Server.Concurrency,
+		MaxConnsPerIP:      cfg.Server.MaxConnsPerIP,
+		MaxRequestsPerConn: cfg.Server.MaxRequestsPerConn,
+		CloseOnShutdown:    true,
+	}
+}
+
+// errDrainTimeout is returned by serve when requests are still running after
+// the shutdown timeout.
+var errDrainTimeout = errors.New("shutdown deadline exceeded, abandoning in-flight requests")
+
+// serve runs the server until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to timeout for in-flight requests to finish.
+func serve(server *fasthttp.Server, addr string, timeout time.Duration, logger zerolog.Logger) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe(addr)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-signals:
+		logger.Info().Str("signal", sig.String()).Dur("timeout_ms", timeout).Msg("draining requests")
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- server.Shutdown()
+	}()
+
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			return err
+		}
+		logger.Info().Msg("all requests drained")
+	case <-time.After(timeout):
+		return errDrainTimeout
+	}
+	return nil
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("FORUM_CONFIG"), "path to a YAML or JSON config file")
+	storage := flag.String("storage", "", "storage backend: postgres or memory, overrides the config")
+	flag.Parse()
+
+	logger, _ := logging.New(os.Stderr, "info")
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("config failed")
+	}
+	if *storage != "" {
+		cfg.Storage = *storage
+		if err = cfg.Validate(); err != nil {
+			logger.Fatal().Err(err).Msg("config failed")
+		}
+	}
+	configured, err := logging.New(os.Stderr, cfg.Log.Level)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("config failed")
+	}
+	logger = configured
+
+	if flag.Arg(0) == "migrate" {
+		if err = runMigrate(cfg, flag.Args()[1:], logger); err != nil {
+			logger.Fatal().Err(err).Msg("migrate failed")
+		}
+		return
+	}
+	if flag.Arg(0) == "export" {
+		if err = runExport(cfg, flag.Args()[1:], logger); err != nil {
+			logger.Fatal().Err(err).Msg("export failed")
+		}
+		return
+	}
+	logger.Info().Str("config", cfg.Masked()).Msg("effective config")
+
+	if err = run(cfg, logger); err != nil {
+		logger.Error().Err(err).Msg("server failed")
+		os.Exit(1)
+	}
+	logger.Info().Msg("server stopped")
+}
+
+// run serves until the server is stopped. Storage is closed only once the
+// server has drained; after a timed-out drain the abandoned requests keep it
+// until the process exits.
+func run(cfg *config.Config, logger zerolog.Logger) (err error) {
+	monitoring := metrics.New()
+
+	repo, checks, closeRepo, err := initRepository(cfg, monitoring, logger)
+	if err != nil {
+		return fmt.Errorf("initRepository: %w", err)
+	}
+	defer func() {
+		if !errors.Is(err, errDrainTimeout) {
+			closeRepo()
+		}
+	}()
+
+	audit, closeAudit, err := initAuditLog(cfg)
+	if err != nil {
+		return fmt.Errorf("initAuditLog: %w", err)
+	}
+	defer func() {
+		if !errors.Is(err, errDrainTimeout) {
+			closeAudit()
+		}
+	}()
+	admin := delivery.NewAdmin(cfg.Admin.Token, cfg.Mode == "test", audit)
+
+	service := usecase.NewForumService(repo, logger)
+	service.SetStatusMode(usecase.StatusMode(cfg.Status.Mode), cfg.Status.CacheTTL.Duration)
+	service.SetAuth(cfg.Auth.Anonymous, cfg.Auth.TokenTTL.Duration)
+	monitoring.WatchStatus(service.GetStatus, cfg.Server.RequestTimeout.Duration)
+
+	// Thread streams and sockets are fed by the hub. With Postgres, events
+	// reach it through LISTEN/NOTIFY so every instance sees those of the others.
+	hub := events.NewHub(events.DefaultBuffer)
+	monitoring.WatchStreams(hub.Subscribers)
+	if storage, ok := repo.(*repository.Storage); ok {
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		defer stopRelay()
+		service.SetPublisher(storage)
+		go relayEvents(relayCtx, storage, service, hub, logger)
+	} else {
+		service.SetPublisher(hub)
+	}
+
+	// Webhook events are queued in the outbox by the requests that cause them
+	// and delivered from it in the background, by every instance.
+	service.SetWebhooks(cfg.Webhooks.Enabled)
+	if cfg.Webhooks.Enabled {
+		dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+		defer stopDispatch()
+		dispatcher := webhooks.NewDispatcher(repo, cfg.Webhooks.Timeout.Duration, cfg.Webhooks.MaxAttempts, logger)
+		go dispatcher.Run(dispatchCtx, cfg.Webhooks.Interval.Duration)
+	}
+	api := delivery.NewApi(service, admin, hub, logger)
+	if cfg.Server.PublicURL != "" {
+		publicURL, err := url.Parse(cfg.Server.PublicURL)
+		if err != nil {
+			return fmt.Errorf("server.public_url: %w", err)
+		}
+		api.SetPublicURL(publicURL)
+	}
+
+	deadlines := delivery.Deadlines{
+		Default: cfg.Server.RequestTimeout.Duration,
+		Routes:  make(map[string]time.Duration, len(cfg.Server.RouteTimeouts)),
+	}
+	for route, timeout := range cfg.Server.RouteTimeouts {
+		deadlines.Routes[route] = timeout.Duration
+	}
+	access := delivery.NewAccessLog(logger)
+	probes := delivery.NewProbes(cfg.Server.ProbeTimeout.Duration, buildInfo(), checks...)
+	router := initRouter(api, admin, probes, deadlines, monitoring, access)
+
+	server := initServer(cfg, access.Handler(router.Handler), logger)
+
+	logger.Info().Str("addr", cfg.Server.ListenAddr).Msg("server start")
+	return serve(server, cfg.Server.ListenAddr, cfg.Server.ShutdownTimeout.Duration, logger)
 }
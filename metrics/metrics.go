@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"strconv"
+	"technopark-forum/models"
+	"time"
+)
+
+const namespace = "forum"
+
+// Metrics owns the Prometheus registry served at /metrics.
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	queries   *prometheus.HistogramVec
+	poolWaits prometheus.Counter
+}
+
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern and response status class.",
+		}, []string{"route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database query latency by repository statement.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"statement"}),
+		poolWaits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_pool_waits_total",
+			Help:      "Connection acquisitions that had to wait because every pool connection was busy.",
+		}),
+	}
+
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.requests, metrics.latency, metrics.queries, metrics.poolWaits,
+	)
+	return metrics
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (metrics *Metrics) Handler() fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
+}
+
+// Instrument counts and times requests to handler under route, the
+// "METHOD /pattern" it was registered with, so slugs never become labels.
+func (metrics *Metrics) Instrument(route string, handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	latency := metrics.latency.WithLabelValues(route)
+
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		handler(ctx)
+		latency.Observe(time.Since(start).Seconds())
+		metrics.requests.WithLabelValues(route, statusClass(ctx.Response.StatusCode())).Inc()
+	}
+}
+
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// QueryLogger returns a pgx logger that records query durations under the
+// name statementName gives their SQL, and counts pool waits. It needs the
+// connection LogLevel to be at least pgx.LogLevelInfo. Statements sent in a
+// pgx.Batch are not logged by pgx and so are not timed.
+func (metrics *Metrics) QueryLogger(statementName func(sql string) string) pgx.Logger {
+	return &queryLogger{metrics: metrics, statementName: statementName}
+}
+
+type queryLogger struct {
+	metrics       *Metrics
+	statementName func(sql string) string
+}
+
+func (logger *queryLogger) Log(level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if msg == "waiting for available connection" {
+		logger.metrics.poolWaits.Inc()
+		return
+	}
+
+	elapsed, ok := data["time"].(time.Duration)
+	if !ok {
+		return
+	}
+	sql, _ := data["sql"].(string)
+	logger.metrics.queries.WithLabelValues(logger.statementName(sql)).Observe(elapsed.Seconds())
+}
+
+// WatchPool exports the connection counts of pool.
+func (metrics *Metrics) WatchPool(pool *pgx.ConnPool) {
+	gauge := func(name, help string, value func(stat pgx.ConnPoolStat) int) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(value(pool.Stat())) })
+	}
+
+	metrics.registry.MustRegister(
+		gauge("db_pool_max_connections", "Maximum size of the connection pool.",
+			func(stat pgx.ConnPoolStat) int { return stat.MaxConnections }),
+		gauge("db_pool_acquired_connections", "Connections currently checked out of the pool.",
+			func(stat pgx.ConnPoolStat) int { return stat.CheckedOutConnections() }),
+		gauge("db_pool_idle_connections", "Open connections waiting in the pool.",
+			func(stat pgx.ConnPoolStat) int { return stat.AvailableConnections }),
+	)
+}
+
+// WatchStreams exports how many clients are streaming threads or holding a
+// socket, read through open on every scrape.
+func (metrics *Metrics) WatchStreams(open func() int) {
+	metrics.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_subscribers",
+		Help:      "Open thread streams and sockets served by this instance.",
+	}, func() float64 { return float64(open()) }))
+}
+
+// WatchStatus exports the counts of models.Status, read through status on
+// every scrape and bounded by timeout unless it is zero.
+func (metrics *Metrics) WatchStatus(status func(ctx context.Context) (*models.Status, error), timeout time.Duration) {
+	metrics.registry.MustRegister(&statusCollector{status: status, timeout: timeout})
+}
+
+var statusDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "entities"),
+	"Number of stored entities by kind, as reported by /api/service/status.",
+	[]string{"kind"}, nil,
+)
+
+type statusCollector struct {
+	status  func(ctx context.Context) (*models.Status, error)
+	timeout time.Duration
+}
+
+func (collector *statusCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- statusDesc
+}
+
+func (collector *statusCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx := context.Background()
+	if collector.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, collector.timeout)
+		defer cancel()
+	}
+
+	status, err := collector.status(ctx)
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(statusDesc, err)
+		return
+	}
+	for kind, count := range map[string]int{
+		"forum":  status.Forum,
+		"thread": status.Thread,
+		"post":   status.Post,
+		"user":   status.User,
+	} {
+		metrics <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, float64(count), kind)
+	}
+}
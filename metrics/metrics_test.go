@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstrument(t *testing.T) {
+	metrics := New()
+	route := "GET /api/forum/:slug/details"
+	handler := metrics.Instrument(route, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(http.StatusNotFound)
+	})
+
+	for i := 0; i < 3; i++ {
+		handler(new(fasthttp.RequestCtx))
+	}
+
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues(route, "4xx")); got != 3 {
+		t.Errorf("requests{4xx} = %v, want 3", got)
+	}
+	if got := testutil.CollectAndCount(metrics.latency); got != 1 {
+		t.Errorf("latency series = %d, want one per route", got)
+	}
+}
+
+func TestQueryLogger(t *testing.T) {
+	metrics := New()
+	logger := metrics.QueryLogger(func(sql string) string {
+		if strings.HasPrefix(sql, "SELECT") {
+			return "GetForum.query"
+		}
+		return "other"
+	})
+
+	logger.Log(pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1", "time": 3 * time.Millisecond})
+	logger.Log(pgx.LogLevelInfo, "Dialing PostgreSQL server", map[string]interface{}{"host": "db"})
+	logger.Log(pgx.LogLevelWarn, "waiting for available connection", nil)
+
+	if got := testutil.CollectAndCount(metrics.queries); got != 1 {
+		t.Errorf("query series = %d, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.poolWaits); got != 1 {
+		t.Errorf("pool waits = %v, want 1", got)
+	}
+}
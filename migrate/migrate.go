@@ -0,0 +1,315 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// lockID keys the advisory lock that keeps concurrent migrators apart.
+const lockID = 7346021
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema change: a pair of <version>_<name>.up.sql and
+// <version>_<name>.down.sql files.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status describes a migration as seen by the database. Applied migrations
+// that the binary does not know about are reported with empty Up and Down.
+type Status struct {
+	Migration
+	AppliedAt time.Time
+	Applied   bool
+	// Modified is set when the up file changed after it was applied.
+	Modified bool
+}
+
+func (status Status) State() string {
+	switch {
+	case !status.Applied:
+		return "pending"
+	case status.Up == "":
+		return "unknown"
+	case status.Modified:
+		return "modified"
+	default:
+		return "applied"
+	}
+}
+
+// Load reads the migrations in dir of fsys ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read migrations")
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read migration %s", entry.Name())
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, errors.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+			sum := sha256.Sum256(data)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, errors.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database and records them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *pgx.ConnPool
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations compiled into the binary.
+func New(db *pgx.ConnPool) (*Migrator, error) {
+	migrations, err := Load(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied.
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := migrator.locked(ctx, func(conn *pgx.Conn) error {
+		statuses, err := migrator.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Modified {
+				return errors.Errorf("migration %d_%s was modified after it was applied", status.Version, status.Name)
+			}
+		}
+
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+			err = migrator.run(ctx, conn, status.Up,
+				"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+				status.Version, status.Name, status.Checksum)
+			if err != nil {
+				return errors.Wrapf(err, "apply migration %d_%s", status.Version, status.Name)
+			}
+			applied = append(applied, status.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns the ones it rolled back.
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := migrator.locked(ctx, func(conn *pgx.Conn) error {
+		statuses, err := migrator.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && len(reverted) < steps; i-- {
+			status := statuses[i]
+			if !status.Applied {
+				continue
+			}
+			if status.Down == "" {
+				return errors.Errorf("migration %d_%s is not known to this binary", status.Version, status.Name)
+			}
+			err = migrator.run(ctx, conn, status.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", status.Version)
+			if err != nil {
+				return errors.Wrapf(err, "roll back migration %d_%s", status.Version, status.Name)
+			}
+			reverted = append(reverted, status.Migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports every known and every applied migration ordered by version.
+func (migrator *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := migrator.locked(ctx, func(conn *pgx.Conn) (err error) {
+		statuses, err = migrator.status(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// Pending returns the migrations Up would apply.
+func (migrator *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Latest returns the version of the newest migration compiled into the
+// binary, or 0 when there are none.
+func (migrator *Migrator) Latest() int {
+	if len(migrator.migrations) == 0 {
+		return 0
+	}
+	return migrator.migrations[len(migrator.migrations)-1].Version
+}
+
+// Current returns the highest applied version, or 0 on a fresh database.
+// Unlike Status it takes no lock, so it is cheap enough for health checks.
+func (migrator *Migrator) Current(ctx context.Context) (int, error) {
+	var version int
+	err := migrator.db.QueryRowEx(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations", nil).Scan(&version)
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "42P01" {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "read schema_migrations")
+	}
+	return version, nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock,
+// creating the schema_migrations table first if needed.
+func (migrator *Migrator) locked(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := migrator.db.AcquireEx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "acquire connection")
+	}
+	defer migrator.db.Release(conn)
+
+	if _, err = conn.ExecEx(ctx, "SELECT pg_advisory_lock($1)", nil, lockID); err != nil {
+		return errors.Wrap(err, "lock migrations")
+	}
+	defer conn.ExecEx(context.Background(), "SELECT pg_advisory_unlock($1)", nil, lockID)
+
+	_, err = conn.ExecEx(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       TEXT                     NOT NULL,
+    checksum   TEXT                     NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+)`, nil)
+	if err != nil {
+		return errors.Wrap(err, "create schema_migrations")
+	}
+
+	return fn(conn)
+}
+
+func (migrator *Migrator) status(ctx context.Context, conn *pgx.Conn) ([]Status, error) {
+	rows, err := conn.QueryEx(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "read schema_migrations")
+	}
+	defer rows.Close()
+
+	applied := make(map[int]Status)
+	for rows.Next() {
+		status := Status{Applied: true}
+		if err = rows.Scan(&status.Version, &status.Name, &status.Checksum, &status.AppliedAt); err != nil {
+			return nil, errors.Wrap(err, "read schema_migrations")
+		}
+		applied[status.Version] = status
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "read schema_migrations")
+	}
+
+	statuses := make([]Status, 0, len(migrator.migrations)+len(applied))
+	for _, migration := range migrator.migrations {
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			status.Modified = record.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, record)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// run executes script and the bookkeeping statement in one transaction.
+func (migrator *Migrator) run(ctx context.Context, conn *pgx.Conn, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginEx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments pgx sends the script over the simple protocol, which
+	// allows several statements in one call.
+	if _, err = tx.ExecEx(ctx, script, nil); err != nil {
+		return err
+	}
+	if _, err = tx.ExecEx(ctx, record, nil, args...); err != nil {
+		return err
+	}
+	return tx.CommitEx(ctx)
+}
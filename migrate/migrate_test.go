@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(embedded, "sql")
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "baseline" {
+		t.Fatalf("unexpected embedded migrations: %+v", migrations)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("migrations out of order: %d after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"m/0010_later.up.sql":   {Data: []byte("SELECT 10")},
+				"m/0010_later.down.sql": {Data: []byte("SELECT -10")},
+				"m/0002_early.up.sql":   {Data: []byte("SELECT 2")},
+				"m/0002_early.down.sql": {Data: []byte("SELECT -2")},
+				"m/README.md":           {Data: []byte("ignored")},
+			},
+			want: []int{2, 10},
+		},
+		{
+			name: "missing down",
+			files: fstest.MapFS{
+				"m/0001_only.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mismatched names",
+			files: fstest.MapFS{
+				"m/0001_one.up.sql":   {Data: []byte("SELECT 1")},
+				"m/0001_two.down.sql": {Data: []byte("SELECT -1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files, "m")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load: err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(migrations) != len(tt.want) {
+				t.Fatalf("got %d migrations, want %d", len(migrations), len(tt.want))
+			}
+			for i, migration := range migrations {
+				if migration.Version != tt.want[i] || migration.Checksum == "" {
+					t.Errorf("migration %d: got %+v", i, migration)
+				}
+			}
+		})
+	}
+}
+
+// TestUpDown runs the embedded migrations against FORUM_TEST_DATABASE_URL,
+// which must point at a scratch database: it is rolled back to empty.
+func TestUpDown(t *testing.T) {
+	uri := os.Getenv("FORUM_TEST_DATABASE_URL")
+	if uri == "" {
+		t.Skip("FORUM_TEST_DATABASE_URL is not set")
+	}
+	connConfig, err := pgx.ParseURI(uri)
+	if err != nil {
+		t.Fatalf("parse FORUM_TEST_DATABASE_URL: %s", err)
+	}
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: connConfig, MaxConnections: 2})
+	if err != nil {
+		t.Fatalf("connect to test database: %s", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %s", err)
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("Pending after Up: %v, %v", pending, err)
+	}
+	if current, err := migrator.Current(ctx); err != nil || current != migrator.Latest() {
+		t.Fatalf("Current after Up = %d, %v, want %d", current, err, migrator.Latest())
+	}
+
+	reverted, err := migrator.Down(ctx, len(migrator.migrations))
+	if err != nil || len(reverted) != len(migrator.migrations) {
+		t.Fatalf("Down: reverted %d, err %v", len(reverted), err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("Up after Down: %s", err)
+	}
+}
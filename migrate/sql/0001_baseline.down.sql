@@ -0,0 +1 @@
+DROP TABLE IF EXISTS votes, posts, forum_users, threads, forums, users CASCADE;
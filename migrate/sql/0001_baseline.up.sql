@@ -0,0 +1,91 @@
+-- Baseline: the schema formerly created by db/db.sql. Every statement is
+-- guarded with IF NOT EXISTS so databases set up by that script adopt it.
+CREATE EXTENSION IF NOT EXISTS CITEXT;
+
+CREATE TABLE IF NOT EXISTS users
+(
+    email    CITEXT UNIQUE             NOT NULL,
+    nickname CITEXT COLLATE "C" UNIQUE NOT NULL,
+    fullname TEXT                      NOT NULL,
+    about    TEXT DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS forums
+(
+    title   VARCHAR            NOT NULL,
+    author  CITEXT COLLATE "C" NOT NULL,
+    slug    CITEXT PRIMARY KEY,
+    posts   BIGINT             NOT NULL DEFAULT 0,
+    threads INTEGER            NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS threads
+(
+    id         SERIAL PRIMARY KEY,
+    title      TEXT   NOT NULL,
+    author     CITEXT NOT NULL,
+    forum      CITEXT NOT NULL,
+    message    TEXT   NOT NULL,
+    votes      INTEGER DEFAULT 0,
+    slug       CITEXT,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS forum_users
+(
+    forum    CITEXT COLLATE "C",
+    nickname CITEXT COLLATE "C",
+    CONSTRAINT fk UNIQUE (forum, nickname)
+);
+
+CREATE TABLE IF NOT EXISTS posts
+(
+    id          SERIAL PRIMARY KEY,
+    author      TEXT    NOT NULL,
+    message     TEXT    NOT NULL,
+    is_edited   BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMP WITH TIME ZONE,
+    forum       TEXT    NOT NULL,
+    thread      INTEGER NOT NULL,
+
+    parent      INTEGER          DEFAULT 0,
+    parents     INT[]   NOT NULL,
+    main_parent INT     NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS votes
+(
+    id            SERIAL,
+    user_nickname CITEXT  NOT NULL REFERENCES users (nickname),
+    thread_id     INTEGER NOT NULL REFERENCES threads (id),
+    voice         INTEGER,
+    prev_voice    INTEGER DEFAULT 0,
+    CONSTRAINT unique_user_and_thread UNIQUE (user_nickname, thread_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS threads_slug_idx ON threads (slug);
+CREATE INDEX IF NOT EXISTS threads_slug_id_idx ON threads (slug, id);
+CREATE INDEX IF NOT EXISTS threads_forum_created_at_idx ON threads (forum, created_at);
+CREATE INDEX IF NOT EXISTS threads_forum_created_at_desc_idx ON threads (forum, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS threads_id_forum_idx ON threads (id, forum);
+CREATE UNIQUE INDEX IF NOT EXISTS threads_slug_forum_idx ON threads (slug, forum);
+CREATE UNIQUE INDEX IF NOT EXISTS threads_cover_idx
+    ON threads (created_at, id, slug, title, message, forum, author, votes);
+
+CREATE INDEX IF NOT EXISTS users_cover_idx ON users (nickname, email, about, fullname);
+CREATE UNIQUE INDEX IF NOT EXISTS users_nickname_idx ON users (nickname);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email);
+CREATE INDEX IF NOT EXISTS users_nickname_email_idx ON users (nickname, email);
+
+CREATE UNIQUE INDEX IF NOT EXISTS forum_slug_idx ON forums (slug);
+CREATE INDEX IF NOT EXISTS forums_slug_title_author_threads_posts_idx ON forums (slug, title, author, threads, posts);
+
+CREATE INDEX IF NOT EXISTS posts_thread_id_id_idx ON posts (thread, id);
+CREATE INDEX IF NOT EXISTS posts_thread_id_idx ON posts (thread);
+CREATE INDEX IF NOT EXISTS posts_thread_id_parents_idx ON posts (thread, parents);
+CREATE INDEX IF NOT EXISTS posts_thread_id_parent_main_parent_idx ON posts (thread, id, parent, main_parent) WHERE parent = 0;
+CREATE INDEX IF NOT EXISTS parent_tree_3_1_idx ON posts (main_parent, parents DESC, id);
+CREATE INDEX IF NOT EXISTS parent_tree_4_idx ON posts (id, main_parent);
+
+CREATE UNIQUE INDEX IF NOT EXISTS forum_users_forum_id_nickname_idx2 ON forum_users (forum, lower(nickname));
+CREATE INDEX IF NOT EXISTS forum_users_cover_idx2 ON forum_users (forum, lower(nickname));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS posts_search_idx;
+DROP INDEX IF EXISTS threads_search_idx;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS search;
+ALTER TABLE threads DROP COLUMN IF EXISTS search;
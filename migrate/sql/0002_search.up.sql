@@ -0,0 +1,13 @@
+-- Full-text search over thread titles and messages and post messages. The
+-- 'simple' configuration does no stemming, so Russian and English text are
+-- matched alike. The repository writes the vectors together with the text.
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS search TSVECTOR;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search TSVECTOR;
+
+UPDATE threads SET search = setweight(to_tsvector('simple', title), 'A') || to_tsvector('simple', message)
+WHERE search IS NULL;
+UPDATE posts SET search = to_tsvector('simple', message)
+WHERE search IS NULL;
+
+CREATE INDEX IF NOT EXISTS threads_search_idx ON threads USING GIN (search);
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN (search);
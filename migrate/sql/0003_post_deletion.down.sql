@@ -0,0 +1,3 @@
+-- Tombstones become ordinary posts again; forums.posts does not count them, so
+-- purge them first if the counters must stay exact.
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+-- Deleted posts stay in place as tombstones so the parents/main_parent paths
+-- of their replies remain valid.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
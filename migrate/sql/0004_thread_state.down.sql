@@ -0,0 +1,4 @@
+-- Deleted threads come back as ordinary ones; forums.threads/posts do not
+-- count them until the counters are rebuilt.
+ALTER TABLE threads DROP CONSTRAINT IF EXISTS threads_state_check;
+ALTER TABLE threads DROP COLUMN IF EXISTS state;
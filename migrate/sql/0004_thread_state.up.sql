@@ -0,0 +1,6 @@
+-- Thread lifecycle: open, closed (no new posts), archived (read-only) and
+-- deleted. Deleted threads no longer count towards forums.threads/posts.
+ALTER TABLE threads ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'open';
+
+ALTER TABLE threads DROP CONSTRAINT IF EXISTS threads_state_check;
+ALTER TABLE threads ADD CONSTRAINT threads_state_check CHECK (state IN ('open', 'closed', 'archived', 'deleted'));
@@ -0,0 +1,14 @@
+-- Accounts gain a bcrypt password hash; users created before it, or through
+-- the anonymous API, have none and cannot sign in until they set one.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash TEXT;
+
+-- Bearer tokens handed out by login, stored as SHA-256 hashes.
+CREATE TABLE IF NOT EXISTS auth_tokens
+(
+    token_hash TEXT PRIMARY KEY,
+    nickname   CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS auth_tokens_nickname_idx ON auth_tokens (nickname, expires_at);
@@ -0,0 +1,14 @@
+-- Site roles: every user is a plain user until an operator makes them admin.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+-- Moderators are appointed per forum by its author.
+CREATE TABLE IF NOT EXISTS forum_moderators
+(
+    forum    CITEXT                   NOT NULL REFERENCES forums (slug) ON DELETE CASCADE,
+    nickname CITEXT COLLATE "C"       NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (forum, nickname)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS thread_revisions;
+DROP TABLE IF EXISTS post_revisions;
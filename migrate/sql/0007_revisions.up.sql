@@ -0,0 +1,24 @@
+-- Every edit of a post message or a thread title/message keeps the text it
+-- replaced. editor is NULL for edits made through the anonymous API.
+CREATE TABLE IF NOT EXISTS post_revisions
+(
+    id        BIGSERIAL PRIMARY KEY,
+    post      INTEGER                  NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    editor    CITEXT COLLATE "C",
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    message   TEXT                     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS post_revisions_post_idx ON post_revisions (post, id);
+
+CREATE TABLE IF NOT EXISTS thread_revisions
+(
+    id        BIGSERIAL PRIMARY KEY,
+    thread    INTEGER                  NOT NULL REFERENCES threads (id) ON DELETE CASCADE,
+    editor    CITEXT COLLATE "C",
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    title     TEXT                     NOT NULL,
+    message   TEXT                     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS thread_revisions_thread_idx ON thread_revisions (thread, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS posts_author_votes_idx;
+DROP INDEX IF EXISTS threads_author_votes_idx;
+DROP TABLE IF EXISTS post_votes;
+ALTER TABLE posts DROP COLUMN IF EXISTS votes;
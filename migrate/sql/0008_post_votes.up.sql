@@ -0,0 +1,15 @@
+-- Votes on posts work like votes on threads: one voice per user and post,
+-- with posts.votes keeping the running total.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS votes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS post_votes
+(
+    user_nickname CITEXT COLLATE "C" NOT NULL REFERENCES users (nickname) ON DELETE CASCADE,
+    post_id       INTEGER            NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    voice         INTEGER            NOT NULL,
+    PRIMARY KEY (user_nickname, post_id)
+);
+
+-- Reputation sums the votes on everything a user wrote.
+CREATE INDEX IF NOT EXISTS threads_author_votes_idx ON threads (author) WHERE votes <> 0;
+CREATE INDEX IF NOT EXISTS posts_author_votes_idx ON posts (author) WHERE votes <> 0;
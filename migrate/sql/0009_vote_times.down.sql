@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS votes_thread_id_user_nickname_idx;
+ALTER TABLE votes DROP COLUMN IF EXISTS voted_at;
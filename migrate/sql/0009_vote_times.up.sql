@@ -0,0 +1,5 @@
+-- When each voice was cast, for the voter list of a thread. Votes from before
+-- this migration get the time it ran.
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS voted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS votes_thread_id_user_nickname_idx ON votes (thread_id, lower(user_nickname));
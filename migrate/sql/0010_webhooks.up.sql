@@ -0,0 +1,31 @@
+-- Webhooks take the events of one forum, or of every forum when forum is NULL.
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         SERIAL PRIMARY KEY,
+    url        TEXT                     NOT NULL,
+    forum      CITEXT REFERENCES forums (slug) ON DELETE CASCADE,
+    events     TEXT[]                   NOT NULL,
+    secret     TEXT                     NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- The outbox: one row per event and webhook, attempted until it is delivered
+-- or runs out of attempts and kept afterwards as the delivery log.
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      INTEGER                  NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           TEXT                     NOT NULL,
+    payload         TEXT                     NOT NULL,
+    state           TEXT                     NOT NULL DEFAULT 'pending'
+        CHECK (state IN ('pending', 'delivered', 'failed')),
+    attempts        INTEGER                  NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_status     INTEGER,
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    finished_at     TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE state = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+//easyjson:json
+type Credentials struct {
+	Nickname string `json:"nickname"`
+	Password string `json:"password"`
+}
+
+// Session is a signed-in user. Token is only filled in when the session is
+// handed out by login; storage keeps nothing but its hash.
+//
+//easyjson:json
+type Session struct {
+	Token    string    `json:"token,omitempty"`
+	Nickname string    `json:"nickname"`
+	Expires  time.Time `json:"expires"`
+}
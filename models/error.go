@@ -1,22 +1,190 @@
 package models
 
-import "github.com/pkg/errors"
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
 
 //easyjson:json
 type ErrorMsg struct {
 	Message string `json:"message,omitempty"`
 }
 
+var ErrorMessage = func(message error) ErrorMsg { return ErrorMsg{Message: message.Error()} }
+
+//easyjson:json
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+//easyjson:json
+type ValidationErrorMsg struct {
+	Message string      `json:"message"`
+	Errors  FieldErrors `json:"errors"`
+}
+
+// Entity names the kind of object a domain error is about.
+type Entity string
+
+const (
+	EntityUser     Entity = "user"
+	EntityForum    Entity = "forum"
+	EntityThread   Entity = "thread"
+	EntityPost     Entity = "post"
+	EntityVote     Entity = "vote"
+	EntitySearch   Entity = "search"
+	EntitySession  Entity = "session"
+	EntityRevision Entity = "revision"
+	EntityWebhook  Entity = "webhook"
+)
+
+// keyName is the attribute an entity is looked up by, used in error messages.
+func (entity Entity) keyName() string {
+	switch entity {
+	case EntityUser:
+		return "nickname"
+	case EntityForum:
+		return "slug"
+	case EntityThread:
+		return "slug or id"
+	case EntitySearch:
+		return "query"
+	default:
+		return "id"
+	}
+}
+
+// Sentinels matched by errors.Is against the typed errors below.
 var (
-	ErrorMessage = func(message error) ErrorMsg { return ErrorMsg{Message: message.Error()} }
-
-	UsersProfileConflict = func(nickname string) error { return errors.Errorf("Conflict on user with nickname %s\n", nickname) }
-	UserNotFound         = func(nickname string) error { return errors.Errorf("Can't find user with nickname %s\n", nickname) }
-	ForumNotFound        = func(slug string) error { return errors.Errorf("Can't find forum with slug %s\n", slug) }
-	Conflict             = errors.New("Entity already exist")
-	ThreadNotFound       = errors.New("Thread not found")
-	PostNotFound         = errors.New("Post not found")
-	UserNotFoundSimple   = errors.New("User not found")
-	ThreadAlreadyExists  = errors.New("Thread already exist")
-	EmptyRequest         = errors.New("Empty request")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrInternal     = errors.New("internal error")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
 )
+
+// NotFoundError reports that no Entity is identified by Key.
+type NotFoundError struct {
+	Entity Entity
+	Key    string
+}
+
+func NotFound(entity Entity, key string) error {
+	return &NotFoundError{Entity: entity, Key: key}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Can't find %s with %s %s", e.Entity, e.Entity.keyName(), e.Key)
+}
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// ConflictError reports that Entity identified by Key clashes with existing data.
+type ConflictError struct {
+	Entity Entity
+	Key    string
+	Reason string
+}
+
+func Conflict(entity Entity, key string, reason string) error {
+	return &ConflictError{Entity: entity, Key: key, Reason: reason}
+}
+
+func (e *ConflictError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("Conflict on %s with %s %s: %s", e.Entity, e.Entity.keyName(), e.Key, e.Reason)
+	}
+	return fmt.Sprintf("Conflict on %s with %s %s", e.Entity, e.Entity.keyName(), e.Key)
+}
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// ValidationError reports that field Key of Entity holds an unacceptable value.
+type ValidationError struct {
+	Entity Entity
+	Key    string
+	Reason string
+}
+
+func Validation(entity Entity, key string, reason string) error {
+	return &ValidationError{Entity: entity, Key: key, Reason: reason}
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid %s %s: %s", e.Entity, e.Key, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// UnauthorizedError reports that the request is not signed in, or not with
+// valid credentials.
+type UnauthorizedError struct {
+	Reason string
+}
+
+func Unauthorized(reason string) error {
+	return &UnauthorizedError{Reason: reason}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("Unauthorized: %s", e.Reason)
+}
+
+func (e *UnauthorizedError) Is(target error) bool { return target == ErrUnauthorized }
+
+// ForbiddenError reports that the signed-in user may not act on Entity
+// identified by Key.
+type ForbiddenError struct {
+	Entity Entity
+	Key    string
+	Reason string
+}
+
+func Forbidden(entity Entity, key string, reason string) error {
+	return &ForbiddenError{Entity: entity, Key: key, Reason: reason}
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("Forbidden on %s with %s %s: %s", e.Entity, e.Entity.keyName(), e.Key, e.Reason)
+}
+
+func (e *ForbiddenError) Is(target error) bool { return target == ErrForbidden }
+
+// InternalError wraps an unexpected storage failure while handling Entity.
+// Its message leaves the cause out, as it is sent to clients; log Err instead.
+type InternalError struct {
+	Entity Entity
+	Key    string
+	Err    error
+}
+
+func Internal(entity Entity, key string, err error) error {
+	return &InternalError{Entity: entity, Key: key, Err: err}
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("Internal error on %s %s", e.Entity, e.Key)
+}
+
+func (e *InternalError) Is(target error) bool { return target == ErrInternal }
+
+func (e *InternalError) Unwrap() error { return e.Err }
+
+// FieldErrors reports every rejected field of a request at once.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	message := "Invalid input"
+	for i, field := range e {
+		separator := ", "
+		if i == 0 {
+			separator = ": "
+		}
+		message += separator + field.Field + " " + field.Message
+	}
+	return message
+}
+
+func (e FieldErrors) Is(target error) bool { return target == ErrValidation }
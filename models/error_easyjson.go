@@ -17,7 +17,7 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonE34310f8DecodeTechnoparkForumModels(in *jlexer.Lexer, out *ErrorMsg) {
+func easyjsonE34310f8DecodeTechnoparkForumModels(in *jlexer.Lexer, out *ValidationErrorMsg) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -38,6 +38,29 @@ func easyjsonE34310f8DecodeTechnoparkForumModels(in *jlexer.Lexer, out *ErrorMsg
 		switch key {
 		case "message":
 			out.Message = string(in.String())
+		case "errors":
+			if in.IsNull() {
+				in.Skip()
+				out.Errors = nil
+			} else {
+				in.Delim('[')
+				if out.Errors == nil {
+					if !in.IsDelim(']') {
+						out.Errors = make(FieldErrors, 0, 2)
+					} else {
+						out.Errors = FieldErrors{}
+					}
+				} else {
+					out.Errors = (out.Errors)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 FieldError
+					(v1).UnmarshalEasyJSON(in)
+					out.Errors = append(out.Errors, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -48,7 +71,162 @@ func easyjsonE34310f8DecodeTechnoparkForumModels(in *jlexer.Lexer, out *ErrorMsg
 		in.Consumed()
 	}
 }
-func easyjsonE34310f8EncodeTechnoparkForumModels(out *jwriter.Writer, in ErrorMsg) {
+func easyjsonE34310f8EncodeTechnoparkForumModels(out *jwriter.Writer, in ValidationErrorMsg) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"message\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Message))
+	}
+	{
+		const prefix string = ",\"errors\":"
+		out.RawString(prefix)
+		if in.Errors == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range in.Errors {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ValidationErrorMsg) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE34310f8EncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ValidationErrorMsg) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE34310f8EncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ValidationErrorMsg) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE34310f8DecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ValidationErrorMsg) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE34310f8DecodeTechnoparkForumModels(l, v)
+}
+func easyjsonE34310f8DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *FieldError) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "field":
+			out.Field = string(in.String())
+		case "message":
+			out.Message = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE34310f8EncodeTechnoparkForumModels1(out *jwriter.Writer, in FieldError) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"field\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Field))
+	}
+	{
+		const prefix string = ",\"message\":"
+		out.RawString(prefix)
+		out.String(string(in.Message))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v FieldError) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE34310f8EncodeTechnoparkForumModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v FieldError) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE34310f8EncodeTechnoparkForumModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *FieldError) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE34310f8DecodeTechnoparkForumModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *FieldError) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE34310f8DecodeTechnoparkForumModels1(l, v)
+}
+func easyjsonE34310f8DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *ErrorMsg) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "message":
+			out.Message = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE34310f8EncodeTechnoparkForumModels2(out *jwriter.Writer, in ErrorMsg) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -64,23 +242,23 @@ func easyjsonE34310f8EncodeTechnoparkForumModels(out *jwriter.Writer, in ErrorMs
 // MarshalJSON supports json.Marshaler interface
 func (v ErrorMsg) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE34310f8EncodeTechnoparkForumModels(&w, v)
+	easyjsonE34310f8EncodeTechnoparkForumModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v ErrorMsg) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE34310f8EncodeTechnoparkForumModels(w, v)
+	easyjsonE34310f8EncodeTechnoparkForumModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *ErrorMsg) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE34310f8DecodeTechnoparkForumModels(&r, v)
+	easyjsonE34310f8DecodeTechnoparkForumModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *ErrorMsg) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE34310f8DecodeTechnoparkForumModels(l, v)
+	easyjsonE34310f8DecodeTechnoparkForumModels2(l, v)
 }
@@ -0,0 +1,73 @@
+package models
+
+// Kinds of events announced by the usecase layer. Edits and votes are about a
+// post when Post is set and about a thread otherwise.
+const (
+	EventThread = "thread"
+	EventPost   = "post"
+	EventEdit   = "edit"
+	EventVote   = "vote"
+)
+
+// Event tells the subscribers of a forum, a thread or a user that something
+// happened there. Forum, Thread and User say where: the forum slug, the thread
+// id and the nickname of the author, or of the voter for votes.
+//
+//easyjson:json
+type Event struct {
+	Type   string `json:"type"`
+	Forum  string `json:"forum,omitempty"`
+	Thread int    `json:"thread,omitempty"`
+	User   string `json:"user,omitempty"`
+	Post   *Post  `json:"post,omitempty"`
+	// Details is the thread a thread event is about.
+	Details *Thread `json:"details,omitempty"`
+	// Vote is the voice cast by a vote event, 0 when the vote was withdrawn.
+	Vote *Vote `json:"vote,omitempty"`
+}
+
+// Events are announced together, like the posts of one batch.
+//
+//easyjson:json
+type Events []Event
+
+// Topics a socket subscribes to.
+const (
+	TopicForum  = "forum"
+	TopicThread = "thread"
+	TopicUser   = "user"
+)
+
+// Actions a socket client sends and the replies it gets besides events.
+const (
+	SocketSubscribe    = "subscribe"
+	SocketUnsubscribe  = "unsubscribe"
+	SocketSubscribed   = "subscribed"
+	SocketUnsubscribed = "unsubscribed"
+	SocketError        = "error"
+)
+
+// SocketRequest subscribes a socket to the events of a forum by slug, of a
+// thread by slug or id or of a user by nickname, or unsubscribes it.
+//
+//easyjson:json
+type SocketRequest struct {
+	Action string `json:"action"`
+	Topic  string `json:"topic"`
+	Key    string `json:"key"`
+}
+
+// SocketReply answers a SocketRequest. A subscription is confirmed with the
+// key the topic is known by: the forum slug or the nickname in lower case, or
+// the thread id. A failure carries the status and message of the matching
+// HTTP error.
+//
+//easyjson:json
+type SocketReply struct {
+	Type    string      `json:"type"`
+	Topic   string      `json:"topic,omitempty"`
+	Key     string      `json:"key,omitempty"`
+	Status  int         `json:"status,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Errors  FieldErrors `json:"errors,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Record types of a forum export, in the order they are written, so every
+// record comes after those it refers to. An error record ends an export that
+// failed part way.
+const (
+	ExportUser       = "user"
+	ExportForum      = "forum"
+	ExportThread     = "thread"
+	ExportPost       = "post"
+	ExportThreadVote = "thread_vote"
+	ExportPostVote   = "post_vote"
+	ExportError      = "error"
+)
+
+// ExportRecord is one line of a forum export. Threads and posts are exported
+// as stored, deleted ones included, and posts with their path of parents.
+//
+//easyjson:json
+type ExportRecord struct {
+	Type   string  `json:"type"`
+	User   *User   `json:"user,omitempty"`
+	Forum  *Forum  `json:"forum,omitempty"`
+	Thread *Thread `json:"thread,omitempty"`
+	Post   *Post   `json:"post,omitempty"`
+	Vote   *Vote   `json:"vote,omitempty"`
+	// Target is the id of the thread or post a vote is on, and Voted when a
+	// thread vote was cast; post votes are not timed.
+	Target int        `json:"target,omitempty"`
+	Voted  *time.Time `json:"voted,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
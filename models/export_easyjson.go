@@ -0,0 +1,192 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package models
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson4bb85eceDecodeTechnoparkForumModels(in *jlexer.Lexer, out *ExportRecord) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "type":
+			out.Type = string(in.String())
+		case "user":
+			if in.IsNull() {
+				in.Skip()
+				out.User = nil
+			} else {
+				if out.User == nil {
+					out.User = new(User)
+				}
+				(*out.User).UnmarshalEasyJSON(in)
+			}
+		case "forum":
+			if in.IsNull() {
+				in.Skip()
+				out.Forum = nil
+			} else {
+				if out.Forum == nil {
+					out.Forum = new(Forum)
+				}
+				(*out.Forum).UnmarshalEasyJSON(in)
+			}
+		case "thread":
+			if in.IsNull() {
+				in.Skip()
+				out.Thread = nil
+			} else {
+				if out.Thread == nil {
+					out.Thread = new(Thread)
+				}
+				(*out.Thread).UnmarshalEasyJSON(in)
+			}
+		case "post":
+			if in.IsNull() {
+				in.Skip()
+				out.Post = nil
+			} else {
+				if out.Post == nil {
+					out.Post = new(Post)
+				}
+				(*out.Post).UnmarshalEasyJSON(in)
+			}
+		case "vote":
+			if in.IsNull() {
+				in.Skip()
+				out.Vote = nil
+			} else {
+				if out.Vote == nil {
+					out.Vote = new(Vote)
+				}
+				(*out.Vote).UnmarshalEasyJSON(in)
+			}
+		case "target":
+			out.Target = int(in.Int())
+		case "voted":
+			if in.IsNull() {
+				in.Skip()
+				out.Voted = nil
+			} else {
+				if out.Voted == nil {
+					out.Voted = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.Voted).UnmarshalJSON(data))
+				}
+			}
+		case "error":
+			out.Error = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson4bb85eceEncodeTechnoparkForumModels(out *jwriter.Writer, in ExportRecord) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"type\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Type))
+	}
+	if in.User != nil {
+		const prefix string = ",\"user\":"
+		out.RawString(prefix)
+		(*in.User).MarshalEasyJSON(out)
+	}
+	if in.Forum != nil {
+		const prefix string = ",\"forum\":"
+		out.RawString(prefix)
+		(*in.Forum).MarshalEasyJSON(out)
+	}
+	if in.Thread != nil {
+		const prefix string = ",\"thread\":"
+		out.RawString(prefix)
+		(*in.Thread).MarshalEasyJSON(out)
+	}
+	if in.Post != nil {
+		const prefix string = ",\"post\":"
+		out.RawString(prefix)
+		(*in.Post).MarshalEasyJSON(out)
+	}
+	if in.Vote != nil {
+		const prefix string = ",\"vote\":"
+		out.RawString(prefix)
+		(*in.Vote).MarshalEasyJSON(out)
+	}
+	if in.Target != 0 {
+		const prefix string = ",\"target\":"
+		out.RawString(prefix)
+		out.Int(int(in.Target))
+	}
+	if in.Voted != nil {
+		const prefix string = ",\"voted\":"
+		out.RawString(prefix)
+		out.Raw((*in.Voted).MarshalJSON())
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ExportRecord) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson4bb85eceEncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ExportRecord) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson4bb85eceEncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ExportRecord) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson4bb85eceDecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ExportRecord) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson4bb85eceDecodeTechnoparkForumModels(l, v)
+}
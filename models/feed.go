@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Feed is what the feed of a forum or a thread is made of: the forum and its
+// latest threads, or the thread and its latest posts, newest first.
+type Feed struct {
+	Forum   *Forum
+	Threads Threads
+
+	Thread *Thread
+	Posts  Posts
+
+	// Edited holds when each of the threads or posts that was edited was
+	// edited last, by id.
+	Edited map[int]time.Time
+}
@@ -13,6 +13,17 @@ type Post struct {
 	Created  time.Time `json:"created,omitempty"`
 	Parent   int32     `json:"parent,omitempty"`
 	Parents  []int32   `json:"parents"`
+	Votes    int       `json:"votes"`
+	// IsDeleted marks a tombstone: the post was deleted but keeps its place
+	// in the thread so replies to it still have a parent.
+	IsDeleted bool `json:"isDeleted,omitempty"`
+}
+
+// Tombstone blanks the message of a deleted post.
+func (post *Post) Tombstone() {
+	if post.IsDeleted {
+		post.Message = ""
+	}
 }
 
 //easyjson:json
@@ -29,4 +40,11 @@ type PostDetails struct {
 //easyjson:json
 type PostUpdate struct {
 	Message *string `json:"message"`
+	// Editor is who the revision replaced by the update is recorded under.
+	Editor string `json:"-"`
+}
+
+//easyjson:json
+type Purge struct {
+	Posts int `json:"posts"`
 }
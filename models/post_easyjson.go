@@ -17,7 +17,73 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson5a72dc82DecodeTechnoparkForumModels(in *jlexer.Lexer, out *Posts) {
+func easyjson5a72dc82DecodeTechnoparkForumModels(in *jlexer.Lexer, out *Purge) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "posts":
+			out.Posts = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson5a72dc82EncodeTechnoparkForumModels(out *jwriter.Writer, in Purge) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"posts\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Posts))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Purge) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson5a72dc82EncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Purge) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson5a72dc82EncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Purge) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson5a72dc82DecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Purge) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson5a72dc82DecodeTechnoparkForumModels(l, v)
+}
+func easyjson5a72dc82DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *Posts) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		in.Skip()
@@ -45,7 +111,7 @@ func easyjson5a72dc82DecodeTechnoparkForumModels(in *jlexer.Lexer, out *Posts) {
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeTechnoparkForumModels(out *jwriter.Writer, in Posts) {
+func easyjson5a72dc82EncodeTechnoparkForumModels1(out *jwriter.Writer, in Posts) {
 	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 		out.RawString("null")
 	} else {
@@ -63,27 +129,27 @@ func easyjson5a72dc82EncodeTechnoparkForumModels(out *jwriter.Writer, in Posts)
 // MarshalJSON supports json.Marshaler interface
 func (v Posts) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeTechnoparkForumModels(&w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Posts) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeTechnoparkForumModels(w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Posts) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeTechnoparkForumModels(&r, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Posts) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeTechnoparkForumModels(l, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels1(l, v)
 }
-func easyjson5a72dc82DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *PostUpdate) {
+func easyjson5a72dc82DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *PostUpdate) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -122,7 +188,7 @@ func easyjson5a72dc82DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *PostUpd
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeTechnoparkForumModels1(out *jwriter.Writer, in PostUpdate) {
+func easyjson5a72dc82EncodeTechnoparkForumModels2(out *jwriter.Writer, in PostUpdate) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -141,27 +207,27 @@ func easyjson5a72dc82EncodeTechnoparkForumModels1(out *jwriter.Writer, in PostUp
 // MarshalJSON supports json.Marshaler interface
 func (v PostUpdate) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeTechnoparkForumModels1(&w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v PostUpdate) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeTechnoparkForumModels1(w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *PostUpdate) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeTechnoparkForumModels1(&r, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *PostUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeTechnoparkForumModels1(l, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels2(l, v)
 }
-func easyjson5a72dc82DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *PostDetails) {
+func easyjson5a72dc82DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *PostDetails) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -230,7 +296,7 @@ func easyjson5a72dc82DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *PostDet
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeTechnoparkForumModels2(out *jwriter.Writer, in PostDetails) {
+func easyjson5a72dc82EncodeTechnoparkForumModels3(out *jwriter.Writer, in PostDetails) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -276,27 +342,27 @@ func easyjson5a72dc82EncodeTechnoparkForumModels2(out *jwriter.Writer, in PostDe
 // MarshalJSON supports json.Marshaler interface
 func (v PostDetails) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeTechnoparkForumModels2(&w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels3(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v PostDetails) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeTechnoparkForumModels2(w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels3(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *PostDetails) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeTechnoparkForumModels2(&r, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels3(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *PostDetails) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeTechnoparkForumModels2(l, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels3(l, v)
 }
-func easyjson5a72dc82DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *Post) {
+func easyjson5a72dc82DecodeTechnoparkForumModels4(in *jlexer.Lexer, out *Post) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -356,6 +422,10 @@ func easyjson5a72dc82DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *Post) {
 				}
 				in.Delim(']')
 			}
+		case "votes":
+			out.Votes = int(in.Int())
+		case "isDeleted":
+			out.IsDeleted = bool(in.Bool())
 		default:
 			in.SkipRecursive()
 		}
@@ -366,7 +436,7 @@ func easyjson5a72dc82DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *Post) {
 		in.Consumed()
 	}
 }
-func easyjson5a72dc82EncodeTechnoparkForumModels3(out *jwriter.Writer, in Post) {
+func easyjson5a72dc82EncodeTechnoparkForumModels4(out *jwriter.Writer, in Post) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -426,29 +496,39 @@ func easyjson5a72dc82EncodeTechnoparkForumModels3(out *jwriter.Writer, in Post)
 			out.RawByte(']')
 		}
 	}
+	{
+		const prefix string = ",\"votes\":"
+		out.RawString(prefix)
+		out.Int(int(in.Votes))
+	}
+	if in.IsDeleted {
+		const prefix string = ",\"isDeleted\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.IsDeleted))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Post) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson5a72dc82EncodeTechnoparkForumModels3(&w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Post) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson5a72dc82EncodeTechnoparkForumModels3(w, v)
+	easyjson5a72dc82EncodeTechnoparkForumModels4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Post) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson5a72dc82DecodeTechnoparkForumModels3(&r, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Post) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson5a72dc82DecodeTechnoparkForumModels3(l, v)
+	easyjson5a72dc82DecodeTechnoparkForumModels4(l, v)
 }
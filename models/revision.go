@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Revision is one version of a post or thread. Storage keeps the text each
+// edit replaced, with the editor and time of that edit; the history numbers
+// the versions from the original one and diffs each against the one before.
+//
+//easyjson:json
+type Revision struct {
+	Number int `json:"revision"`
+	// Editor is empty for edits made through the anonymous API.
+	Editor  string    `json:"editor,omitempty"`
+	Created time.Time `json:"created"`
+	Title   string    `json:"title,omitempty"`
+	Message string    `json:"message"`
+	Diff    string    `json:"diff,omitempty"`
+}
+
+//easyjson:json
+type Revisions []Revision
+
+//easyjson:json
+type Revert struct {
+	Revision int `json:"revision"`
+}
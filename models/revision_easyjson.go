@@ -0,0 +1,254 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package models
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson7bc39f0fDecodeTechnoparkForumModels(in *jlexer.Lexer, out *Revisions) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(Revisions, 0, 0)
+			} else {
+				*out = Revisions{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 Revision
+			(v1).UnmarshalEasyJSON(in)
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson7bc39f0fEncodeTechnoparkForumModels(out *jwriter.Writer, in Revisions) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			(v3).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Revisions) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson7bc39f0fEncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Revisions) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson7bc39f0fEncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Revisions) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson7bc39f0fDecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Revisions) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson7bc39f0fDecodeTechnoparkForumModels(l, v)
+}
+func easyjson7bc39f0fDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *Revision) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "revision":
+			out.Number = int(in.Int())
+		case "editor":
+			out.Editor = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
+		case "title":
+			out.Title = string(in.String())
+		case "message":
+			out.Message = string(in.String())
+		case "diff":
+			out.Diff = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson7bc39f0fEncodeTechnoparkForumModels1(out *jwriter.Writer, in Revision) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"revision\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Number))
+	}
+	if in.Editor != "" {
+		const prefix string = ",\"editor\":"
+		out.RawString(prefix)
+		out.String(string(in.Editor))
+	}
+	{
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
+	if in.Title != "" {
+		const prefix string = ",\"title\":"
+		out.RawString(prefix)
+		out.String(string(in.Title))
+	}
+	{
+		const prefix string = ",\"message\":"
+		out.RawString(prefix)
+		out.String(string(in.Message))
+	}
+	if in.Diff != "" {
+		const prefix string = ",\"diff\":"
+		out.RawString(prefix)
+		out.String(string(in.Diff))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Revision) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson7bc39f0fEncodeTechnoparkForumModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Revision) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson7bc39f0fEncodeTechnoparkForumModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Revision) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson7bc39f0fDecodeTechnoparkForumModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Revision) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson7bc39f0fDecodeTechnoparkForumModels1(l, v)
+}
+func easyjson7bc39f0fDecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Revert) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "revision":
+			out.Revision = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson7bc39f0fEncodeTechnoparkForumModels2(out *jwriter.Writer, in Revert) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"revision\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Revision))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Revert) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson7bc39f0fEncodeTechnoparkForumModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Revert) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson7bc39f0fEncodeTechnoparkForumModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Revert) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson7bc39f0fDecodeTechnoparkForumModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Revert) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson7bc39f0fDecodeTechnoparkForumModels2(l, v)
+}
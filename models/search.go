@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// Kinds of documents a search can return.
+const (
+	SearchPost   = "post"
+	SearchThread = "thread"
+)
+
+// SearchQuery is GET /api/search after validation. Forum and Author narrow the
+// results when set; Since continues after the last result of a previous page.
+type SearchQuery struct {
+	Text   string
+	Forum  string
+	Author string
+	Since  *SearchCursor
+	Limit  int
+}
+
+var errMalformedCursor = errors.New("malformed search cursor")
+
+// SearchCursor is the position of a result in rank order. Results are sorted
+// by rank, kind and id, all descending, so the triple is a keyset.
+type SearchCursor struct {
+	Rank float32
+	Kind string
+	ID   int
+}
+
+// String encodes the cursor as the opaque token clients pass back as since.
+func (cursor SearchCursor) String() string {
+	raw := strconv.FormatFloat(float64(cursor.Rank), 'g', -1, 32) + ":" + cursor.Kind + ":" + strconv.Itoa(cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseSearchCursor decodes a token made by SearchCursor.String.
+func ParseSearchCursor(token string) (*SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[1] != SearchPost && parts[1] != SearchThread) {
+		return nil, errMalformedCursor
+	}
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, errMalformedCursor
+	}
+	return &SearchCursor{Rank: float32(rank), Kind: parts[1], ID: id}, nil
+}
+
+// Before reports whether the result at cursor sorts before other, i.e. other
+// belongs to a later page.
+func (cursor SearchCursor) Before(other SearchCursor) bool {
+	if cursor.Rank != other.Rank {
+		return cursor.Rank > other.Rank
+	}
+	if cursor.Kind != other.Kind {
+		return cursor.Kind > other.Kind
+	}
+	return cursor.ID > other.ID
+}
+
+//easyjson:json
+type SearchResult struct {
+	Kind    string  `json:"kind"`
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+	Post    *Post   `json:"post,omitempty"`
+	Thread  *Thread `json:"thread,omitempty"`
+}
+
+// Cursor is the position of result, from which the next page continues.
+func (result SearchResult) Cursor() SearchCursor {
+	cursor := SearchCursor{Rank: result.Rank, Kind: result.Kind}
+	if result.Post != nil {
+		cursor.ID = result.Post.ID
+	} else if result.Thread != nil {
+		cursor.ID = result.Thread.ID
+	}
+	return cursor
+}
+
+//easyjson:json
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+	// Next is the since value of the following page, empty on the last one.
+	Next string `json:"next,omitempty"`
+}
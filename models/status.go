@@ -7,3 +7,22 @@ type Status struct {
 	Thread int `json:"thread"`
 	User   int `json:"user"`
 }
+
+//easyjson:json
+type Health struct {
+	Status string `json:"status"`
+}
+
+//easyjson:json
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+//easyjson:json
+type BuildInfo struct {
+	Commit        string `json:"commit"`
+	BuildTime     string `json:"build_time"`
+	GoVersion     string `json:"go_version"`
+	SchemaVersion int    `json:"schema_version"`
+}
@@ -104,3 +104,259 @@ func (v *Status) UnmarshalJSON(data []byte) error {
 func (v *Status) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson727fe99aDecodeTechnoparkForumModels(l, v)
 }
+func easyjson727fe99aDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *Readiness) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "status":
+			out.Status = string(in.String())
+		case "checks":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				out.Checks = make(map[string]string)
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v1 string
+					v1 = string(in.String())
+					(out.Checks)[key] = v1
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson727fe99aEncodeTechnoparkForumModels1(out *jwriter.Writer, in Readiness) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	{
+		const prefix string = ",\"checks\":"
+		out.RawString(prefix)
+		if in.Checks == nil && (out.Flags&jwriter.NilMapAsEmpty) == 0 {
+			out.RawString(`null`)
+		} else {
+			out.RawByte('{')
+			v2First := true
+			for v2Name, v2Value := range in.Checks {
+				if v2First {
+					v2First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v2Name))
+				out.RawByte(':')
+				out.String(string(v2Value))
+			}
+			out.RawByte('}')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Readiness) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson727fe99aEncodeTechnoparkForumModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Readiness) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson727fe99aEncodeTechnoparkForumModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Readiness) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson727fe99aDecodeTechnoparkForumModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Readiness) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson727fe99aDecodeTechnoparkForumModels1(l, v)
+}
+func easyjson727fe99aDecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Health) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "status":
+			out.Status = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson727fe99aEncodeTechnoparkForumModels2(out *jwriter.Writer, in Health) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"status\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Status))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Health) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson727fe99aEncodeTechnoparkForumModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Health) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson727fe99aEncodeTechnoparkForumModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Health) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson727fe99aDecodeTechnoparkForumModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Health) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson727fe99aDecodeTechnoparkForumModels2(l, v)
+}
+func easyjson727fe99aDecodeTechnoparkForumModels3(in *jlexer.Lexer, out *BuildInfo) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "commit":
+			out.Commit = string(in.String())
+		case "build_time":
+			out.BuildTime = string(in.String())
+		case "go_version":
+			out.GoVersion = string(in.String())
+		case "schema_version":
+			out.SchemaVersion = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson727fe99aEncodeTechnoparkForumModels3(out *jwriter.Writer, in BuildInfo) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"commit\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Commit))
+	}
+	{
+		const prefix string = ",\"build_time\":"
+		out.RawString(prefix)
+		out.String(string(in.BuildTime))
+	}
+	{
+		const prefix string = ",\"go_version\":"
+		out.RawString(prefix)
+		out.String(string(in.GoVersion))
+	}
+	{
+		const prefix string = ",\"schema_version\":"
+		out.RawString(prefix)
+		out.Int(int(in.SchemaVersion))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v BuildInfo) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson727fe99aEncodeTechnoparkForumModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v BuildInfo) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson727fe99aEncodeTechnoparkForumModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *BuildInfo) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson727fe99aDecodeTechnoparkForumModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *BuildInfo) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson727fe99aDecodeTechnoparkForumModels3(l, v)
+}
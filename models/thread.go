@@ -12,8 +12,19 @@ type Thread struct {
 	Votes   int       `json:"votes"`
 	Slug    string    `json:"slug"`
 	Created time.Time `json:"created"`
+	State   string    `json:"state"`
 }
 
+// Thread states. Closed threads take no new posts, archived ones are read-only
+// and left out of forum listings unless asked for, deleted ones are gone for
+// everybody but an admin who restores them.
+const (
+	ThreadOpen     = "open"
+	ThreadClosed   = "closed"
+	ThreadArchived = "archived"
+	ThreadDeleted  = "deleted"
+)
+
 //easyjson:json
 type Threads []Thread
 
@@ -21,4 +32,11 @@ type Threads []Thread
 type ThreadUpdate struct {
 	Message *string `json:"message"`
 	Title   *string `json:"title"`
+	// Editor is who the revision replaced by the update is recorded under.
+	Editor string `json:"-"`
+}
+
+//easyjson:json
+type ThreadStateUpdate struct {
+	State string `json:"state"`
 }
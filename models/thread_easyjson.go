@@ -180,7 +180,73 @@ func (v *ThreadUpdate) UnmarshalJSON(data []byte) error {
 func (v *ThreadUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson2d00218DecodeTechnoparkForumModels1(l, v)
 }
-func easyjson2d00218DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Thread) {
+func easyjson2d00218DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *ThreadStateUpdate) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "state":
+			out.State = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson2d00218EncodeTechnoparkForumModels2(out *jwriter.Writer, in ThreadStateUpdate) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"state\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.State))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ThreadStateUpdate) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson2d00218EncodeTechnoparkForumModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ThreadStateUpdate) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson2d00218EncodeTechnoparkForumModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ThreadStateUpdate) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson2d00218DecodeTechnoparkForumModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ThreadStateUpdate) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson2d00218DecodeTechnoparkForumModels2(l, v)
+}
+func easyjson2d00218DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *Thread) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -217,6 +283,8 @@ func easyjson2d00218DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Thread)
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.Created).UnmarshalJSON(data))
 			}
+		case "state":
+			out.State = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -227,7 +295,7 @@ func easyjson2d00218DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Thread)
 		in.Consumed()
 	}
 }
-func easyjson2d00218EncodeTechnoparkForumModels2(out *jwriter.Writer, in Thread) {
+func easyjson2d00218EncodeTechnoparkForumModels3(out *jwriter.Writer, in Thread) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -271,29 +339,34 @@ func easyjson2d00218EncodeTechnoparkForumModels2(out *jwriter.Writer, in Thread)
 		out.RawString(prefix)
 		out.Raw((in.Created).MarshalJSON())
 	}
+	{
+		const prefix string = ",\"state\":"
+		out.RawString(prefix)
+		out.String(string(in.State))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Thread) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson2d00218EncodeTechnoparkForumModels2(&w, v)
+	easyjson2d00218EncodeTechnoparkForumModels3(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Thread) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson2d00218EncodeTechnoparkForumModels2(w, v)
+	easyjson2d00218EncodeTechnoparkForumModels3(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Thread) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson2d00218DecodeTechnoparkForumModels2(&r, v)
+	easyjson2d00218DecodeTechnoparkForumModels3(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Thread) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson2d00218DecodeTechnoparkForumModels2(l, v)
+	easyjson2d00218DecodeTechnoparkForumModels3(l, v)
 }
@@ -6,7 +6,25 @@ type User struct {
 	Nickname string `json:"nickname,omitempty"`
 	Fullname string `json:"fullname"`
 	About    string `json:"about,omitempty"`
+	// Password is only ever read from requests; the service replaces it with
+	// PasswordHash before the user reaches storage.
+	Password     string `json:"password,omitempty"`
+	PasswordHash string `json:"-"`
+	// Reputation is only filled in for profiles and leaderboards.
+	Reputation *int `json:"reputation,omitempty"`
 }
 
 //easyjson:json
 type Users []User
+
+// Site roles. Moderators are appointed per forum and are not a site role.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+//easyjson:json
+type UserRole struct {
+	Nickname string `json:"nickname"`
+	Role     string `json:"role"`
+}
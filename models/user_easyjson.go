@@ -26,7 +26,7 @@ func easyjson9e1087fdDecodeTechnoparkForumModels(in *jlexer.Lexer, out *Users) {
 		in.Delim('[')
 		if *out == nil {
 			if !in.IsDelim(']') {
-				*out = make(Users, 0, 1)
+				*out = make(Users, 0, 0)
 			} else {
 				*out = Users{}
 			}
@@ -83,7 +83,80 @@ func (v *Users) UnmarshalJSON(data []byte) error {
 func (v *Users) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson9e1087fdDecodeTechnoparkForumModels(l, v)
 }
-func easyjson9e1087fdDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *User) {
+func easyjson9e1087fdDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *UserRole) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "nickname":
+			out.Nickname = string(in.String())
+		case "role":
+			out.Role = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson9e1087fdEncodeTechnoparkForumModels1(out *jwriter.Writer, in UserRole) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"nickname\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Nickname))
+	}
+	{
+		const prefix string = ",\"role\":"
+		out.RawString(prefix)
+		out.String(string(in.Role))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v UserRole) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson9e1087fdEncodeTechnoparkForumModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v UserRole) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson9e1087fdEncodeTechnoparkForumModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *UserRole) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson9e1087fdDecodeTechnoparkForumModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *UserRole) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson9e1087fdDecodeTechnoparkForumModels1(l, v)
+}
+func easyjson9e1087fdDecodeTechnoparkForumModels2(in *jlexer.Lexer, out *User) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -110,6 +183,18 @@ func easyjson9e1087fdDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *User) {
 			out.Fullname = string(in.String())
 		case "about":
 			out.About = string(in.String())
+		case "password":
+			out.Password = string(in.String())
+		case "reputation":
+			if in.IsNull() {
+				in.Skip()
+				out.Reputation = nil
+			} else {
+				if out.Reputation == nil {
+					out.Reputation = new(int)
+				}
+				*out.Reputation = int(in.Int())
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -120,7 +205,7 @@ func easyjson9e1087fdDecodeTechnoparkForumModels1(in *jlexer.Lexer, out *User) {
 		in.Consumed()
 	}
 }
-func easyjson9e1087fdEncodeTechnoparkForumModels1(out *jwriter.Writer, in User) {
+func easyjson9e1087fdEncodeTechnoparkForumModels2(out *jwriter.Writer, in User) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -129,7 +214,7 @@ func easyjson9e1087fdEncodeTechnoparkForumModels1(out *jwriter.Writer, in User)
 		out.RawString(prefix[1:])
 		out.String(string(in.Email))
 	}
-	{
+	if in.Nickname != "" {
 		const prefix string = ",\"nickname\":"
 		out.RawString(prefix)
 		out.String(string(in.Nickname))
@@ -144,29 +229,39 @@ func easyjson9e1087fdEncodeTechnoparkForumModels1(out *jwriter.Writer, in User)
 		out.RawString(prefix)
 		out.String(string(in.About))
 	}
+	if in.Password != "" {
+		const prefix string = ",\"password\":"
+		out.RawString(prefix)
+		out.String(string(in.Password))
+	}
+	if in.Reputation != nil {
+		const prefix string = ",\"reputation\":"
+		out.RawString(prefix)
+		out.Int(int(*in.Reputation))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v User) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson9e1087fdEncodeTechnoparkForumModels1(&w, v)
+	easyjson9e1087fdEncodeTechnoparkForumModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v User) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson9e1087fdEncodeTechnoparkForumModels1(w, v)
+	easyjson9e1087fdEncodeTechnoparkForumModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *User) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson9e1087fdDecodeTechnoparkForumModels1(&r, v)
+	easyjson9e1087fdDecodeTechnoparkForumModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *User) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson9e1087fdDecodeTechnoparkForumModels1(l, v)
+	easyjson9e1087fdDecodeTechnoparkForumModels2(l, v)
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 //easyjson:json
 type Vote struct {
 	Nickname string `json:"nickname"`
@@ -13,3 +15,29 @@ type VoteDB struct {
 	ThreadID int
 	Voice    int
 }
+
+//easyjson:json
+type Voter struct {
+	Nickname string    `json:"nickname"`
+	Voice    int       `json:"voice"`
+	Voted    time.Time `json:"voted"`
+}
+
+// ThreadVotes is one page of the voters of a thread with the totals over all
+// of them: Votes is the net score, Up and Down count the voices either way.
+//
+//easyjson:json
+type ThreadVotes struct {
+	Votes  int     `json:"votes"`
+	Up     int     `json:"up"`
+	Down   int     `json:"down"`
+	Voters []Voter `json:"voters"`
+}
+
+// VotersQuery is GET /thread/{slug_or_id}/votes after validation: Limit
+// voters in nickname order, starting after Since when it is set.
+type VotersQuery struct {
+	Limit int
+	Since string
+	Desc  bool
+}
@@ -17,7 +17,89 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjsonE3ecfa40DecodeTechnoparkForumModels(in *jlexer.Lexer, out *VoteDB) {
+func easyjsonE3ecfa40DecodeTechnoparkForumModels(in *jlexer.Lexer, out *Voter) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "nickname":
+			out.Nickname = string(in.String())
+		case "voice":
+			out.Voice = int(in.Int())
+		case "voted":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Voted).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE3ecfa40EncodeTechnoparkForumModels(out *jwriter.Writer, in Voter) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"nickname\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Nickname))
+	}
+	{
+		const prefix string = ",\"voice\":"
+		out.RawString(prefix)
+		out.Int(int(in.Voice))
+	}
+	{
+		const prefix string = ",\"voted\":"
+		out.RawString(prefix)
+		out.Raw((in.Voted).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Voter) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE3ecfa40EncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Voter) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE3ecfa40EncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Voter) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE3ecfa40DecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Voter) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE3ecfa40DecodeTechnoparkForumModels(l, v)
+}
+func easyjsonE3ecfa40DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *VoteDB) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -54,7 +136,7 @@ func easyjsonE3ecfa40DecodeTechnoparkForumModels(in *jlexer.Lexer, out *VoteDB)
 		in.Consumed()
 	}
 }
-func easyjsonE3ecfa40EncodeTechnoparkForumModels(out *jwriter.Writer, in VoteDB) {
+func easyjsonE3ecfa40EncodeTechnoparkForumModels1(out *jwriter.Writer, in VoteDB) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -84,27 +166,27 @@ func easyjsonE3ecfa40EncodeTechnoparkForumModels(out *jwriter.Writer, in VoteDB)
 // MarshalJSON supports json.Marshaler interface
 func (v VoteDB) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE3ecfa40EncodeTechnoparkForumModels(&w, v)
+	easyjsonE3ecfa40EncodeTechnoparkForumModels1(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v VoteDB) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE3ecfa40EncodeTechnoparkForumModels(w, v)
+	easyjsonE3ecfa40EncodeTechnoparkForumModels1(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *VoteDB) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE3ecfa40DecodeTechnoparkForumModels(&r, v)
+	easyjsonE3ecfa40DecodeTechnoparkForumModels1(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *VoteDB) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE3ecfa40DecodeTechnoparkForumModels(l, v)
+	easyjsonE3ecfa40DecodeTechnoparkForumModels1(l, v)
 }
-func easyjsonE3ecfa40DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *Vote) {
+func easyjsonE3ecfa40DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *Vote) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -137,7 +219,7 @@ func easyjsonE3ecfa40DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *Vote) {
 		in.Consumed()
 	}
 }
-func easyjsonE3ecfa40EncodeTechnoparkForumModels1(out *jwriter.Writer, in Vote) {
+func easyjsonE3ecfa40EncodeTechnoparkForumModels2(out *jwriter.Writer, in Vote) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -157,23 +239,142 @@ func easyjsonE3ecfa40EncodeTechnoparkForumModels1(out *jwriter.Writer, in Vote)
 // MarshalJSON supports json.Marshaler interface
 func (v Vote) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjsonE3ecfa40EncodeTechnoparkForumModels1(&w, v)
+	easyjsonE3ecfa40EncodeTechnoparkForumModels2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Vote) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjsonE3ecfa40EncodeTechnoparkForumModels1(w, v)
+	easyjsonE3ecfa40EncodeTechnoparkForumModels2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Vote) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjsonE3ecfa40DecodeTechnoparkForumModels1(&r, v)
+	easyjsonE3ecfa40DecodeTechnoparkForumModels2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Vote) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjsonE3ecfa40DecodeTechnoparkForumModels1(l, v)
+	easyjsonE3ecfa40DecodeTechnoparkForumModels2(l, v)
+}
+func easyjsonE3ecfa40DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *ThreadVotes) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "votes":
+			out.Votes = int(in.Int())
+		case "up":
+			out.Up = int(in.Int())
+		case "down":
+			out.Down = int(in.Int())
+		case "voters":
+			if in.IsNull() {
+				in.Skip()
+				out.Voters = nil
+			} else {
+				in.Delim('[')
+				if out.Voters == nil {
+					if !in.IsDelim(']') {
+						out.Voters = make([]Voter, 0, 1)
+					} else {
+						out.Voters = []Voter{}
+					}
+				} else {
+					out.Voters = (out.Voters)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 Voter
+					(v1).UnmarshalEasyJSON(in)
+					out.Voters = append(out.Voters, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonE3ecfa40EncodeTechnoparkForumModels3(out *jwriter.Writer, in ThreadVotes) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"votes\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Votes))
+	}
+	{
+		const prefix string = ",\"up\":"
+		out.RawString(prefix)
+		out.Int(int(in.Up))
+	}
+	{
+		const prefix string = ",\"down\":"
+		out.RawString(prefix)
+		out.Int(int(in.Down))
+	}
+	{
+		const prefix string = ",\"voters\":"
+		out.RawString(prefix)
+		if in.Voters == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v2, v3 := range in.Voters {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				(v3).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ThreadVotes) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjsonE3ecfa40EncodeTechnoparkForumModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ThreadVotes) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjsonE3ecfa40EncodeTechnoparkForumModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ThreadVotes) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjsonE3ecfa40DecodeTechnoparkForumModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ThreadVotes) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjsonE3ecfa40DecodeTechnoparkForumModels3(l, v)
 }
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"github.com/mailru/easyjson"
+	"time"
+)
+
+// Events a webhook can take.
+const (
+	HookThreadCreated = "thread.created"
+	HookPostCreated   = "post.created"
+	HookPostUpdated   = "post.updated"
+	HookVoteChanged   = "vote.changed"
+	HookUserCreated   = "user.created"
+)
+
+// HookEvents lists every event a webhook can take.
+var HookEvents = []string{HookThreadCreated, HookPostCreated, HookPostUpdated, HookVoteChanged, HookUserCreated}
+
+// Webhook receives the events of one forum, or of every forum when Forum is
+// empty, as signed POSTs to URL.
+//
+//easyjson:json
+type Webhook struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Forum  string   `json:"forum,omitempty"`
+	Events []string `json:"events"`
+	// Secret signs the deliveries. One is generated when none is given, and
+	// it is only ever shown in the answer to the creation.
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+//easyjson:json
+type Webhooks []Webhook
+
+// WebhookEvent is the body POSTed to a webhook. Only the entities the event
+// is about are set: the user for user.created, the thread or post for the
+// others, and for vote.changed the vote, whose voice is 0 when withdrawn.
+//
+//easyjson:json
+type WebhookEvent struct {
+	Event   string    `json:"event"`
+	Forum   string    `json:"forum,omitempty"`
+	Created time.Time `json:"created"`
+	User    *User     `json:"user,omitempty"`
+	Thread  *Thread   `json:"thread,omitempty"`
+	Post    *Post     `json:"post,omitempty"`
+	Vote    *Vote     `json:"vote,omitempty"`
+}
+
+// Delivery states. Pending deliveries are retried until they succeed or run
+// out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the log entry of one event sent to a webhook. Status and
+// Error describe the last attempt.
+//
+//easyjson:json
+type WebhookDelivery struct {
+	ID          int64               `json:"id"`
+	Webhook     int                 `json:"webhook"`
+	Event       string              `json:"event"`
+	State       string              `json:"state"`
+	Attempts    int                 `json:"attempts"`
+	Status      int                 `json:"status,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	Created     time.Time           `json:"created"`
+	NextAttempt *time.Time          `json:"next_attempt,omitempty"`
+	Finished    *time.Time          `json:"finished,omitempty"`
+	Payload     easyjson.RawMessage `json:"payload"`
+}
+
+//easyjson:json
+type WebhookDeliveries []WebhookDelivery
+
+// DeliveriesQuery pages the log of a webhook newest first: Since is the id
+// of the last delivery seen, State keeps deliveries in that state only.
+type DeliveriesQuery struct {
+	Limit int
+	Since int64
+	State string
+}
+
+// PendingDelivery is a delivery handed to a dispatcher, with what it takes to
+// attempt it. Attempts counts the attempts made before.
+type PendingDelivery struct {
+	ID       int64
+	Webhook  int
+	URL      string
+	Secret   string
+	Event    string
+	Payload  []byte
+	Attempts int
+}
+
+// DeliveryAttempt is the outcome of attempting a delivery: the state it is
+// left in, the HTTP status or error of the attempt and, while pending, when
+// to try again.
+type DeliveryAttempt struct {
+	State  string
+	Status int
+	Error  string
+	Next   time.Time
+}
@@ -0,0 +1,587 @@
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package models
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson3f91c269DecodeTechnoparkForumModels(in *jlexer.Lexer, out *Webhooks) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(Webhooks, 0, 0)
+			} else {
+				*out = Webhooks{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v1 Webhook
+			(v1).UnmarshalEasyJSON(in)
+			*out = append(*out, v1)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson3f91c269EncodeTechnoparkForumModels(out *jwriter.Writer, in Webhooks) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v2, v3 := range in {
+			if v2 > 0 {
+				out.RawByte(',')
+			}
+			(v3).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Webhooks) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson3f91c269EncodeTechnoparkForumModels(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Webhooks) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson3f91c269EncodeTechnoparkForumModels(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Webhooks) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson3f91c269DecodeTechnoparkForumModels(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Webhooks) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson3f91c269DecodeTechnoparkForumModels(l, v)
+}
+func easyjson3f91c269DecodeTechnoparkForumModels1(in *jlexer.Lexer, out *WebhookEvent) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "event":
+			out.Event = string(in.String())
+		case "forum":
+			out.Forum = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
+		case "user":
+			if in.IsNull() {
+				in.Skip()
+				out.User = nil
+			} else {
+				if out.User == nil {
+					out.User = new(User)
+				}
+				(*out.User).UnmarshalEasyJSON(in)
+			}
+		case "thread":
+			if in.IsNull() {
+				in.Skip()
+				out.Thread = nil
+			} else {
+				if out.Thread == nil {
+					out.Thread = new(Thread)
+				}
+				(*out.Thread).UnmarshalEasyJSON(in)
+			}
+		case "post":
+			if in.IsNull() {
+				in.Skip()
+				out.Post = nil
+			} else {
+				if out.Post == nil {
+					out.Post = new(Post)
+				}
+				(*out.Post).UnmarshalEasyJSON(in)
+			}
+		case "vote":
+			if in.IsNull() {
+				in.Skip()
+				out.Vote = nil
+			} else {
+				if out.Vote == nil {
+					out.Vote = new(Vote)
+				}
+				(*out.Vote).UnmarshalEasyJSON(in)
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson3f91c269EncodeTechnoparkForumModels1(out *jwriter.Writer, in WebhookEvent) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"event\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Event))
+	}
+	if in.Forum != "" {
+		const prefix string = ",\"forum\":"
+		out.RawString(prefix)
+		out.String(string(in.Forum))
+	}
+	{
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
+	if in.User != nil {
+		const prefix string = ",\"user\":"
+		out.RawString(prefix)
+		(*in.User).MarshalEasyJSON(out)
+	}
+	if in.Thread != nil {
+		const prefix string = ",\"thread\":"
+		out.RawString(prefix)
+		(*in.Thread).MarshalEasyJSON(out)
+	}
+	if in.Post != nil {
+		const prefix string = ",\"post\":"
+		out.RawString(prefix)
+		(*in.Post).MarshalEasyJSON(out)
+	}
+	if in.Vote != nil {
+		const prefix string = ",\"vote\":"
+		out.RawString(prefix)
+		(*in.Vote).MarshalEasyJSON(out)
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WebhookEvent) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson3f91c269EncodeTechnoparkForumModels1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WebhookEvent) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson3f91c269EncodeTechnoparkForumModels1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WebhookEvent) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson3f91c269DecodeTechnoparkForumModels1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WebhookEvent) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson3f91c269DecodeTechnoparkForumModels1(l, v)
+}
+func easyjson3f91c269DecodeTechnoparkForumModels2(in *jlexer.Lexer, out *WebhookDelivery) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "id":
+			out.ID = int64(in.Int64())
+		case "webhook":
+			out.Webhook = int(in.Int())
+		case "event":
+			out.Event = string(in.String())
+		case "state":
+			out.State = string(in.String())
+		case "attempts":
+			out.Attempts = int(in.Int())
+		case "status":
+			out.Status = int(in.Int())
+		case "error":
+			out.Error = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
+		case "next_attempt":
+			if in.IsNull() {
+				in.Skip()
+				out.NextAttempt = nil
+			} else {
+				if out.NextAttempt == nil {
+					out.NextAttempt = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.NextAttempt).UnmarshalJSON(data))
+				}
+			}
+		case "finished":
+			if in.IsNull() {
+				in.Skip()
+				out.Finished = nil
+			} else {
+				if out.Finished == nil {
+					out.Finished = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.Finished).UnmarshalJSON(data))
+				}
+			}
+		case "payload":
+			(out.Payload).UnmarshalEasyJSON(in)
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson3f91c269EncodeTechnoparkForumModels2(out *jwriter.Writer, in WebhookDelivery) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"id\":"
+		out.RawString(prefix[1:])
+		out.Int64(int64(in.ID))
+	}
+	{
+		const prefix string = ",\"webhook\":"
+		out.RawString(prefix)
+		out.Int(int(in.Webhook))
+	}
+	{
+		const prefix string = ",\"event\":"
+		out.RawString(prefix)
+		out.String(string(in.Event))
+	}
+	{
+		const prefix string = ",\"state\":"
+		out.RawString(prefix)
+		out.String(string(in.State))
+	}
+	{
+		const prefix string = ",\"attempts\":"
+		out.RawString(prefix)
+		out.Int(int(in.Attempts))
+	}
+	if in.Status != 0 {
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.Int(int(in.Status))
+	}
+	if in.Error != "" {
+		const prefix string = ",\"error\":"
+		out.RawString(prefix)
+		out.String(string(in.Error))
+	}
+	{
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
+	if in.NextAttempt != nil {
+		const prefix string = ",\"next_attempt\":"
+		out.RawString(prefix)
+		out.Raw((*in.NextAttempt).MarshalJSON())
+	}
+	if in.Finished != nil {
+		const prefix string = ",\"finished\":"
+		out.RawString(prefix)
+		out.Raw((*in.Finished).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"payload\":"
+		out.RawString(prefix)
+		(in.Payload).MarshalEasyJSON(out)
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WebhookDelivery) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson3f91c269EncodeTechnoparkForumModels2(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WebhookDelivery) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson3f91c269EncodeTechnoparkForumModels2(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WebhookDelivery) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson3f91c269DecodeTechnoparkForumModels2(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WebhookDelivery) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson3f91c269DecodeTechnoparkForumModels2(l, v)
+}
+func easyjson3f91c269DecodeTechnoparkForumModels3(in *jlexer.Lexer, out *WebhookDeliveries) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		in.Skip()
+		*out = nil
+	} else {
+		in.Delim('[')
+		if *out == nil {
+			if !in.IsDelim(']') {
+				*out = make(WebhookDeliveries, 0, 0)
+			} else {
+				*out = WebhookDeliveries{}
+			}
+		} else {
+			*out = (*out)[:0]
+		}
+		for !in.IsDelim(']') {
+			var v4 WebhookDelivery
+			(v4).UnmarshalEasyJSON(in)
+			*out = append(*out, v4)
+			in.WantComma()
+		}
+		in.Delim(']')
+	}
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson3f91c269EncodeTechnoparkForumModels3(out *jwriter.Writer, in WebhookDeliveries) {
+	if in == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+		out.RawString("null")
+	} else {
+		out.RawByte('[')
+		for v5, v6 := range in {
+			if v5 > 0 {
+				out.RawByte(',')
+			}
+			(v6).MarshalEasyJSON(out)
+		}
+		out.RawByte(']')
+	}
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v WebhookDeliveries) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson3f91c269EncodeTechnoparkForumModels3(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v WebhookDeliveries) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson3f91c269EncodeTechnoparkForumModels3(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *WebhookDeliveries) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson3f91c269DecodeTechnoparkForumModels3(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *WebhookDeliveries) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson3f91c269DecodeTechnoparkForumModels3(l, v)
+}
+func easyjson3f91c269DecodeTechnoparkForumModels4(in *jlexer.Lexer, out *Webhook) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "id":
+			out.ID = int(in.Int())
+		case "url":
+			out.URL = string(in.String())
+		case "forum":
+			out.Forum = string(in.String())
+		case "events":
+			if in.IsNull() {
+				in.Skip()
+				out.Events = nil
+			} else {
+				in.Delim('[')
+				if out.Events == nil {
+					if !in.IsDelim(']') {
+						out.Events = make([]string, 0, 4)
+					} else {
+						out.Events = []string{}
+					}
+				} else {
+					out.Events = (out.Events)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v7 string
+					v7 = string(in.String())
+					out.Events = append(out.Events, v7)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "secret":
+			out.Secret = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson3f91c269EncodeTechnoparkForumModels4(out *jwriter.Writer, in Webhook) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"id\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.ID))
+	}
+	{
+		const prefix string = ",\"url\":"
+		out.RawString(prefix)
+		out.String(string(in.URL))
+	}
+	if in.Forum != "" {
+		const prefix string = ",\"forum\":"
+		out.RawString(prefix)
+		out.String(string(in.Forum))
+	}
+	{
+		const prefix string = ",\"events\":"
+		out.RawString(prefix)
+		if in.Events == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v8, v9 := range in.Events {
+				if v8 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v9))
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.Secret != "" {
+		const prefix string = ",\"secret\":"
+		out.RawString(prefix)
+		out.String(string(in.Secret))
+	}
+	{
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Webhook) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson3f91c269EncodeTechnoparkForumModels4(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Webhook) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson3f91c269EncodeTechnoparkForumModels4(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Webhook) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson3f91c269DecodeTechnoparkForumModels4(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Webhook) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson3f91c269DecodeTechnoparkForumModels4(l, v)
+}
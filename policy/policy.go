@@ -0,0 +1,145 @@
+// Package policy decides who may do what. usecase.Service asks it before every
+// operation that is not open to everybody, so the rules live in one place.
+package policy
+
+import (
+	"context"
+	"strings"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+)
+
+// Action is an operation that needs more than being signed in.
+type Action string
+
+const (
+	EditPost Action = "edit post"
+	// EditThread changes the title or message of a thread.
+	EditThread Action = "edit thread"
+	// ModerateThread opens, closes or archives a thread.
+	ModerateThread Action = "moderate thread"
+	// Delete deletes a post or a thread.
+	Delete  Action = "delete"
+	Restore Action = "restore"
+	Purge   Action = "purge"
+	// Revert puts an earlier revision of a post or thread back.
+	Revert Action = "revert"
+	Clear  Action = "clear"
+	// AssignRoles makes users site admins or plain users again.
+	AssignRoles Action = "assign roles"
+	// AppointModerators appoints or dismisses the moderators of a forum.
+	AppointModerators Action = "appoint moderators"
+)
+
+// rule says who besides site admins may take an action: the owner of the
+// subject, the moderators of its forum, and, while the legacy anonymous API is
+// enabled, anonymous requests. Only the edits the legacy API had are legacy;
+// moderation and deletion came with accounts and always need one.
+type rule struct {
+	owner     bool
+	moderator bool
+	legacy    bool
+}
+
+var rules = map[Action]rule{
+	EditPost:          {owner: true, moderator: true, legacy: true},
+	EditThread:        {owner: true, moderator: true, legacy: true},
+	ModerateThread:    {moderator: true},
+	Delete:            {owner: true, moderator: true},
+	Restore:           {},
+	Purge:             {},
+	Revert:            {moderator: true},
+	Clear:             {},
+	AssignRoles:       {},
+	AppointModerators: {owner: true},
+}
+
+// Subject is what an action is taken on.
+type Subject struct {
+	Entity models.Entity
+	Key    string
+	// Owner is the nickname of the author, or of the forum author for
+	// AppointModerators.
+	Owner string
+	// Forum is the slug of the forum whose moderators may act on the subject.
+	Forum string
+}
+
+// Roles looks up what the policy needs to know about users.
+type Roles interface {
+	GetUserRole(ctx context.Context, nickname string) (string, error)
+	IsModerator(ctx context.Context, forum string, nickname string) (bool, error)
+}
+
+type Policy struct {
+	roles     Roles
+	anonymous bool
+}
+
+func New(roles Roles) *Policy {
+	return &Policy{roles: roles}
+}
+
+// SetAnonymous enables the legacy API, where requests without a token may
+// write as any user.
+func (policy *Policy) SetAnonymous(anonymous bool) {
+	policy.anonymous = anonymous
+}
+
+func (policy *Policy) Anonymous() bool {
+	return policy.anonymous
+}
+
+// ActAs checks that the request may act as nickname: it must be signed in as
+// that user, or be anonymous while the legacy API is enabled.
+func (policy *Policy) ActAs(ctx context.Context, nickname string) error {
+	user, ok := auth.User(ctx)
+	if !ok {
+		if policy.anonymous || auth.Operator(ctx) {
+			return nil
+		}
+		return models.Unauthorized("sign in to act as " + nickname)
+	}
+	if !strings.EqualFold(user, nickname) {
+		return models.Forbidden(models.EntityUser, nickname, "signed in as "+user)
+	}
+	return nil
+}
+
+// Authorize checks that the request may take action on subject. Requests
+// carrying the admin token may do anything.
+func (policy *Policy) Authorize(ctx context.Context, action Action, subject Subject) error {
+	if auth.Operator(ctx) {
+		return nil
+	}
+	rule := rules[action]
+
+	user, ok := auth.User(ctx)
+	if !ok {
+		if policy.anonymous && rule.legacy {
+			return nil
+		}
+		return models.Unauthorized("sign in to " + string(action))
+	}
+
+	role, err := policy.roles.GetUserRole(ctx, user)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleAdmin {
+		return nil
+	}
+	if rule.owner && subject.Owner != "" && strings.EqualFold(user, subject.Owner) {
+		return nil
+	}
+	if rule.moderator && subject.Forum != "" {
+		moderator, err := policy.roles.IsModerator(ctx, subject.Forum, user)
+		if err != nil {
+			return err
+		}
+		if moderator {
+			return nil
+		}
+	}
+	return models.Forbidden(subject.Entity, subject.Key, user+" may not "+string(action))
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+	"testing"
+)
+
+type fakeRoles struct {
+	admins     map[string]bool
+	moderators map[string]string
+}
+
+func (roles fakeRoles) GetUserRole(_ context.Context, nickname string) (string, error) {
+	if roles.admins[nickname] {
+		return models.RoleAdmin, nil
+	}
+	return models.RoleUser, nil
+}
+
+func (roles fakeRoles) IsModerator(_ context.Context, forum string, nickname string) (bool, error) {
+	return roles.moderators[nickname] == forum, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	post := Subject{Entity: models.EntityPost, Key: "1", Owner: "jack", Forum: "pirates"}
+	forum := Subject{Entity: models.EntityForum, Key: "pirates", Owner: "jack", Forum: "pirates"}
+
+	tests := []struct {
+		name      string
+		user      string
+		operator  bool
+		anonymous bool
+		action    Action
+		subject   Subject
+		wantErr   error
+	}{
+		{name: "owner edits own post", user: "jack", action: EditPost, subject: post},
+		{name: "stranger edits post", user: "will", action: EditPost, subject: post, wantErr: models.ErrForbidden},
+		{name: "moderator edits post", user: "mod", action: EditPost, subject: post},
+		{name: "moderator of another forum", user: "other", action: Delete, subject: post, wantErr: models.ErrForbidden},
+		{name: "admin deletes post", user: "root", action: Delete, subject: post},
+		{name: "owner closes own thread", user: "jack", action: ModerateThread, subject: post, wantErr: models.ErrForbidden},
+		{name: "moderator closes thread", user: "mod", action: ModerateThread, subject: post},
+		{name: "moderator restores", user: "mod", action: Restore, subject: post, wantErr: models.ErrForbidden},
+		{name: "forum author appoints", user: "jack", action: AppointModerators, subject: forum},
+		{name: "moderator appoints", user: "mod", action: AppointModerators, subject: forum, wantErr: models.ErrForbidden},
+		{name: "anonymous edit", action: EditPost, subject: post, wantErr: models.ErrUnauthorized},
+		{name: "anonymous edit with the legacy api", anonymous: true, action: EditPost, subject: post},
+		{name: "anonymous delete with the legacy api", anonymous: true, action: Delete, subject: post, wantErr: models.ErrUnauthorized},
+		{name: "anonymous close with the legacy api", anonymous: true, action: ModerateThread, subject: post, wantErr: models.ErrUnauthorized},
+		{name: "anonymous appoint with the legacy api", anonymous: true, action: AppointModerators, subject: forum, wantErr: models.ErrUnauthorized},
+		{name: "operator clears", operator: true, action: Clear},
+		{name: "admin clears", user: "root", action: Clear},
+		{name: "owner clears", user: "jack", action: Clear, subject: forum, wantErr: models.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := New(fakeRoles{
+				admins:     map[string]bool{"root": true},
+				moderators: map[string]string{"mod": "pirates", "other": "navy"},
+			})
+			policy.SetAnonymous(tt.anonymous)
+
+			ctx := context.Background()
+			if tt.user != "" {
+				ctx = auth.WithUser(ctx, tt.user)
+			}
+			if tt.operator {
+				ctx = auth.WithOperator(ctx)
+			}
+
+			err := policy.Authorize(ctx, tt.action, tt.subject)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Authorize() unexpected error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+)
+
+const (
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+// notFoundOr maps a missing row to a NotFoundError and anything else to an
+// InternalError, so callers never see raw driver errors.
+func notFoundOr(err error, entity models.Entity, key string) error {
+	if err == pgx.ErrNoRows {
+		return models.NotFound(entity, key)
+	}
+	return models.Internal(entity, key, err)
+}
+
+func pgErrorCode(err error) string {
+	if pgError, ok := err.(pgx.PgError); ok {
+		return pgError.Code
+	}
+	return ""
+}
+
+// internal logs an unexpected storage failure and wraps it in an InternalError.
+// Queries cut short by the request deadline are only worth a warning.
+func (storage *Storage) internal(ctx context.Context, entity models.Entity, key string, err error) error {
+	log := logging.For(ctx, storage.log)
+	event := log.Error()
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		event = log.Warn()
+	}
+	event.Err(err).Str("entity", string(entity)).Str("key", key).Msg("storage failure")
+	return models.Internal(entity, key, err)
+}
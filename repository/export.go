@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"technopark-forum/models"
+	"time"
+)
+
+// export
+
+// ExportForum reads in a read-only repeatable read transaction, so the export
+// is consistent however long the client takes to receive it, and streams every
+// query rather than loading it.
+func (storage *Storage) ExportForum(ctx context.Context, slug string, write func(*models.ExportRecord) error) error {
+	queryForum := statement("ExportForum.queryForum", `SELECT title, slug::TEXT, author::TEXT, posts, threads FROM forums WHERE slug = $1`)
+	queryUsers := statement("ExportForum.queryUsers", `SELECT email::TEXT, nickname::TEXT, fullname, coalesce(about, '') FROM users
+WHERE nickname IN (
+    SELECT author::TEXT FROM forums WHERE slug = $1
+    UNION SELECT nickname::TEXT FROM forum_moderators WHERE forum = $1
+    UNION SELECT author::TEXT FROM threads WHERE forum = $1
+    UNION SELECT p.author::TEXT FROM posts p JOIN threads t ON t.id = p.thread WHERE t.forum = $1
+    UNION SELECT v.user_nickname::TEXT FROM votes v JOIN threads t ON t.id = v.thread_id WHERE t.forum = $1
+    UNION SELECT v.user_nickname::TEXT FROM post_votes v JOIN posts p ON p.id = v.post_id JOIN threads t ON t.id = p.thread WHERE t.forum = $1)
+ORDER BY nickname`)
+	queryThreads := statement("ExportForum.queryThreads", `SELECT id, title, author::TEXT, forum::TEXT, message, votes, slug::TEXT, created_at, state
+FROM threads WHERE forum = $1 ORDER BY id`)
+	queryPosts := statement("ExportForum.queryPosts", `SELECT p.id, p.author::TEXT, p.message, p.created_at, p.forum::TEXT, p.thread, p.is_edited, p.parent, p.parents,
+    p.deleted_at IS NOT NULL, p.votes
+FROM posts p JOIN threads t ON t.id = p.thread WHERE t.forum = $1 ORDER BY p.thread, p.parents`)
+	queryThreadVotes := statement("ExportForum.queryThreadVotes", `SELECT v.thread_id, v.user_nickname::TEXT, v.voice, v.voted_at
+FROM votes v JOIN threads t ON t.id = v.thread_id WHERE t.forum = $1 ORDER BY v.thread_id, v.user_nickname`)
+	queryPostVotes := statement("ExportForum.queryPostVotes", `SELECT v.post_id, v.user_nickname::TEXT, v.voice
+FROM post_votes v JOIN posts p ON p.id = v.post_id JOIN threads t ON t.id = p.thread WHERE t.forum = $1 ORDER BY v.post_id, v.user_nickname`)
+
+	tx, err := storage.db.BeginEx(ctx, &pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return storage.internal(ctx, models.EntityForum, slug, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	forum := new(models.Forum)
+	err = tx.QueryRowEx(ctx, queryForum, nil, slug).Scan(&forum.Title, &forum.Slug, &forum.Author, &forum.Posts, &forum.Threads)
+	if err != nil {
+		return notFoundOr(err, models.EntityForum, slug)
+	}
+	slug = forum.Slug
+
+	export := func(query string, scan func(rows *pgx.Rows) (*models.ExportRecord, error)) error {
+		rows, err := tx.QueryEx(ctx, query, nil, slug)
+		if err != nil {
+			return storage.internal(ctx, models.EntityForum, slug, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			record, err := scan(rows)
+			if err != nil {
+				return storage.internal(ctx, models.EntityForum, slug, err)
+			}
+			if err = write(record); err != nil {
+				return err
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return storage.internal(ctx, models.EntityForum, slug, err)
+		}
+		return nil
+	}
+
+	err = export(queryUsers, func(rows *pgx.Rows) (*models.ExportRecord, error) {
+		user := new(models.User)
+		err := rows.Scan(&user.Email, &user.Nickname, &user.Fullname, &user.About)
+		return &models.ExportRecord{Type: models.ExportUser, User: user}, err
+	})
+	if err != nil {
+		return err
+	}
+	if err = write(&models.ExportRecord{Type: models.ExportForum, Forum: forum}); err != nil {
+		return err
+	}
+	err = export(queryThreads, func(rows *pgx.Rows) (*models.ExportRecord, error) {
+		thread := new(models.Thread)
+		var threadSlug *string
+		err := rows.Scan(&thread.ID, &thread.Title, &thread.Author, &thread.Forum, &thread.Message, &thread.Votes, &threadSlug, &thread.Created, &thread.State)
+		if threadSlug != nil {
+			thread.Slug = *threadSlug
+		}
+		return &models.ExportRecord{Type: models.ExportThread, Thread: thread}, err
+	})
+	if err != nil {
+		return err
+	}
+	err = export(queryPosts, func(rows *pgx.Rows) (*models.ExportRecord, error) {
+		post := new(models.Post)
+		err := rows.Scan(&post.ID, &post.Author, &post.Message, &post.Created, &post.Forum, &post.Thread, &post.IsEdited, &post.Parent, &post.Parents,
+			&post.IsDeleted, &post.Votes)
+		return &models.ExportRecord{Type: models.ExportPost, Post: post}, err
+	})
+	if err != nil {
+		return err
+	}
+	err = export(queryThreadVotes, func(rows *pgx.Rows) (*models.ExportRecord, error) {
+		record := &models.ExportRecord{Type: models.ExportThreadVote, Vote: new(models.Vote), Voted: new(time.Time)}
+		err := rows.Scan(&record.Target, &record.Vote.Nickname, &record.Vote.Voice, record.Voted)
+		return record, err
+	})
+	if err != nil {
+		return err
+	}
+	return export(queryPostVotes, func(rows *pgx.Rows) (*models.ExportRecord, error) {
+		record := &models.ExportRecord{Type: models.ExportPostVote, Vote: new(models.Vote)}
+		err := rows.Scan(&record.Target, &record.Vote.Nickname, &record.Vote.Voice)
+		return record, err
+	})
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"technopark-forum/models"
+	"time"
+)
+
+// ForumRepository is the storage contract usecase.Service relies on. Every
+// method takes the request context so slow queries are abandoned once its
+// deadline passes.
+type ForumRepository interface {
+	// service
+	GetStatus(ctx context.Context) (*models.Status, error)
+	// EstimateStatus is GetStatus from planner statistics: cheap, but only as
+	// fresh as the last ANALYZE or autovacuum.
+	EstimateStatus(ctx context.Context) (*models.Status, error)
+	Clear(ctx context.Context) error
+
+	// user
+	CreateUser(ctx context.Context, user *models.User) (*models.Users, error)
+	GetUserProfile(ctx context.Context, nickname string) (*models.User, error)
+	UpdateUserProfile(ctx context.Context, oldUser *models.User) (*models.User, error)
+	// GetReputation sums the votes on the threads and posts of the user,
+	// leaving out deleted ones.
+	GetReputation(ctx context.Context, nickname string) (int, error)
+
+	// auth
+	// GetPasswordHash returns the canonical nickname and the password hash of
+	// the user, an empty hash for users without a password.
+	GetPasswordHash(ctx context.Context, nickname string) (string, string, error)
+	// CreateSession stores a session under the hash of its token and drops
+	// the expired sessions of the same user.
+	CreateSession(ctx context.Context, tokenHash string, session *models.Session) error
+	// GetSession treats expired sessions as missing.
+	GetSession(ctx context.Context, tokenHash string) (*models.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+
+	// roles
+	GetUserRole(ctx context.Context, nickname string) (string, error)
+	SetUserRole(ctx context.Context, nickname string, role string) (*models.UserRole, error)
+	IsModerator(ctx context.Context, forum string, nickname string) (bool, error)
+	// AddModerator and RemoveModerator are idempotent; AddModerator expects
+	// the forum and the user to exist.
+	AddModerator(ctx context.Context, forum string, nickname string) error
+	RemoveModerator(ctx context.Context, forum string, nickname string) error
+	GetForumModerators(ctx context.Context, forum string) (*models.Users, error)
+
+	// forum
+	CreateForum(ctx context.Context, forum *models.Forum) error
+	GetForum(ctx context.Context, slug string) (*models.Forum, error)
+	CreateThread(ctx context.Context, user *models.User, forum *models.Forum, thread *models.Thread) (*models.Thread, error)
+	GetForumUsers(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte) (*models.Users, error)
+	// GetForumThreads leaves out deleted threads, and archived ones unless
+	// archived is set.
+	GetForumThreads(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte, archived bool) (*models.Threads, error)
+	// GetForumLeaderboard ranks the authors of the forum by the reputation
+	// they earned in it, as GetReputation counts it.
+	GetForumLeaderboard(ctx context.Context, slug string, limit int) (*models.Users, error)
+
+	// thread
+	// GetThread, CreatePosts and GetThreadPosts treat deleted threads as
+	// missing; CreatePosts refuses threads that are not open.
+	GetThread(ctx context.Context, slugOrID interface{}) (*models.Thread, error)
+	CreatePosts(ctx context.Context, slugOrID interface{}, posts *models.Posts) (*models.Posts, error)
+	// UpdateThread and UpdatePostDetails keep the title and message they
+	// replace as a revision made by the update's Editor.
+	UpdateThread(ctx context.Context, threadID int, threadUpdate *models.ThreadUpdate) (*models.Thread, error)
+	GetThreadRevisions(ctx context.Context, threadID int) (*models.Revisions, error)
+	// GetThreadEdits and GetPostEdits return when each of the threads or
+	// posts that was ever edited was edited last, by id.
+	GetThreadEdits(ctx context.Context, threadIDs []int) (map[int]time.Time, error)
+	GetThreadPosts(ctx context.Context, slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, error)
+	PutVote(ctx context.Context, slugOrID interface{}, vote *models.Vote) (*models.Thread, error)
+	// DeleteVote withdraws the vote of the user, if there is one, and takes
+	// its voice off the thread's votes.
+	DeleteVote(ctx context.Context, threadID int, nickname string) (*models.Thread, error)
+	// GetThreadVotes returns a page of the voters of the thread and the totals
+	// over all of them.
+	GetThreadVotes(ctx context.Context, threadID int, query models.VotersQuery) (*models.ThreadVotes, error)
+	// SetThreadState moves the thread to state to if it is in one of from,
+	// deleted threads included, and keeps the forum counters in step.
+	SetThreadState(ctx context.Context, slugOrID string, from []string, to string) (*models.Thread, error)
+
+	// post
+	GetPostDetails(ctx context.Context, id *string, related []byte) (*models.PostDetails, error)
+	// GetPosts returns the posts with ids as GetPostDetails shows them, by
+	// id; missing ones are left out.
+	GetPosts(ctx context.Context, ids []int) (map[int]*models.Post, error)
+	UpdatePostDetails(ctx context.Context, id *string, postUpd *models.PostUpdate) (*models.Post, error)
+	GetPostRevisions(ctx context.Context, id int) (*models.Revisions, error)
+	GetPostEdits(ctx context.Context, ids []int) (map[int]time.Time, error)
+	// PutPostVote records the voice of the user on the post, replacing an
+	// earlier one, and keeps the post's votes in step.
+	PutPostVote(ctx context.Context, id *string, vote *models.Vote) (*models.Post, error)
+	// DeletePost turns the post into a tombstone that keeps its place in the
+	// tree; deleting a tombstone again changes nothing.
+	DeletePost(ctx context.Context, id *string) (*models.Post, error)
+	RestorePost(ctx context.Context, id *string) (*models.Post, error)
+	// PurgePost removes the post and every reply below it for good and
+	// returns how many posts went.
+	PurgePost(ctx context.Context, id *string) (int, error)
+
+	// search
+	// Search returns one page of the posts and threads matching query, best
+	// ranked first.
+	Search(ctx context.Context, query models.SearchQuery) (*models.SearchResults, error)
+
+	// webhooks
+	// CreateWebhook stores the webhook and fills in its id and creation time;
+	// the forum, if any, must exist.
+	CreateWebhook(ctx context.Context, hook *models.Webhook) error
+	// GetWebhooks lists the webhooks oldest first, without their secrets.
+	GetWebhooks(ctx context.Context) (*models.Webhooks, error)
+	// DeleteWebhook drops the webhook along with its deliveries.
+	DeleteWebhook(ctx context.Context, id int) error
+	// SetWebhooks makes the writes creating users, threads and posts, editing
+	// posts and voting queue deliveries of what they did to the webhooks that
+	// take it, as part of the write. Without it nothing is queued.
+	SetWebhooks(enabled bool)
+	// EnqueueWebhookEvents adds a pending delivery of every event to every
+	// webhook that takes it.
+	EnqueueWebhookEvents(ctx context.Context, events []models.WebhookEvent) error
+	// ClaimWebhookDeliveries hands out up to limit pending deliveries that are
+	// due, oldest first, and puts their next attempt off by lease so that no
+	// other dispatcher picks them up while they are attempted.
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error)
+	// FinishWebhookAttempt records the outcome of an attempt at a delivery.
+	FinishWebhookAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt) error
+	GetWebhookDeliveries(ctx context.Context, webhookID int, query models.DeliveriesQuery) (*models.WebhookDeliveries, error)
+
+	// export
+	// ExportForum hands write the records of the forum one at a time, as they
+	// are read, from a single snapshot: the users taking part in it, the
+	// forum, its threads, their posts in tree order and the votes on both. An
+	// error returned by write stops the export and is returned as it is.
+	ExportForum(ctx context.Context, slug string, write func(*models.ExportRecord) error) error
+}
+
+var _ ForumRepository = (*Storage)(nil)
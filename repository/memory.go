@@ -0,0 +1,1642 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"github.com/mailru/easyjson"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"technopark-forum/models"
+	"time"
+	"unicode"
+)
+
+// MemoryStorage keeps the whole forum in process memory. It mirrors the
+// behaviour of the Postgres backed Storage and is meant for local development
+// and demos, so everything is lost on restart.
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	users       []*models.User
+	usersByNick map[string]*models.User
+	usersByMail map[string]*models.User
+
+	forums map[string]*models.Forum
+
+	threads         []*models.Thread
+	threadsBySlug   map[string]*models.Thread
+	threadRevisions map[int]models.Revisions
+
+	posts []*memoryPost
+
+	votes map[memoryVoteKey]memoryVote
+
+	forumUsers map[string]map[string]*models.User
+
+	sessions map[string]models.Session
+
+	roles      map[string]string
+	moderators map[string]map[string]*models.User
+
+	webhooks   []*models.Webhook
+	deliveries []*memoryDelivery
+	// hooked makes writes queue deliveries, see SetWebhooks.
+	hooked bool
+	// webhookID and deliveryID are the last ids handed out, which are never
+	// reused, like those of a sequence.
+	webhookID  int
+	deliveryID int64
+}
+
+// memoryPost is nil in MemoryStorage.posts once purged, so ids keep
+// indexing the slice.
+type memoryPost struct {
+	post       models.Post
+	mainParent int32
+	revisions  models.Revisions
+	// voices holds the vote of every user on the post by lower-cased nickname.
+	voices map[string]int
+}
+
+// view returns the post as the API shows it: without its path and with a
+// deleted post reduced to a tombstone.
+func (post *memoryPost) view() models.Post {
+	found := post.post
+	found.Parents = nil
+	found.Tombstone()
+	return found
+}
+
+// memoryDelivery is a webhook delivery with the time of its next attempt,
+// which the log only shows while it is pending.
+type memoryDelivery struct {
+	delivery models.WebhookDelivery
+	next     time.Time
+}
+
+type memoryVoteKey struct {
+	nickname string
+	threadID int
+}
+
+type memoryVote struct {
+	voice int
+	voted time.Time
+}
+
+var _ ForumRepository = (*MemoryStorage)(nil)
+
+func NewMemoryStorage() *MemoryStorage {
+	storage := new(MemoryStorage)
+	storage.reset()
+	return storage
+}
+
+func (storage *MemoryStorage) reset() {
+	storage.users = nil
+	storage.usersByNick = make(map[string]*models.User)
+	storage.usersByMail = make(map[string]*models.User)
+	storage.forums = make(map[string]*models.Forum)
+	storage.threads = nil
+	storage.threadsBySlug = make(map[string]*models.Thread)
+	storage.threadRevisions = make(map[int]models.Revisions)
+	storage.posts = nil
+	storage.votes = make(map[memoryVoteKey]memoryVote)
+	storage.forumUsers = make(map[string]map[string]*models.User)
+	storage.sessions = make(map[string]models.Session)
+	storage.roles = make(map[string]string)
+	storage.moderators = make(map[string]map[string]*models.User)
+	storage.webhooks = nil
+	storage.deliveries = nil
+	storage.webhookID = 0
+	storage.deliveryID = 0
+}
+
+// service
+
+func (storage *MemoryStorage) GetStatus(ctx context.Context) (*models.Status, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return &models.Status{
+		Forum:  len(storage.forums),
+		Post:   storage.countPosts(),
+		Thread: len(storage.threads),
+		User:   len(storage.users),
+	}, nil
+}
+
+// EstimateStatus is exact: counting in memory is already cheap.
+func (storage *MemoryStorage) EstimateStatus(ctx context.Context) (*models.Status, error) {
+	return storage.GetStatus(ctx)
+}
+
+func (storage *MemoryStorage) Clear(ctx context.Context) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.reset()
+	return nil
+}
+
+// user
+
+func (storage *MemoryStorage) CreateUser(ctx context.Context, user *models.User) (*models.Users, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	byNick, nickTaken := storage.usersByNick[strings.ToLower(user.Nickname)]
+	byMail, mailTaken := storage.usersByMail[strings.ToLower(user.Email)]
+	if nickTaken || mailTaken {
+		users := models.Users{}
+		if nickTaken {
+			users = append(users, *byNick)
+		}
+		if mailTaken && byMail != byNick {
+			users = append(users, *byMail)
+		}
+		return &users, nil
+	}
+
+	created := *user
+	created.Password = ""
+	storage.users = append(storage.users, &created)
+	storage.usersByNick[strings.ToLower(created.Nickname)] = &created
+	storage.usersByMail[strings.ToLower(created.Email)] = &created
+	storage.enqueue(userCreated(&created))
+	return nil, nil
+}
+
+func (storage *MemoryStorage) GetUserProfile(ctx context.Context, nickname string) (*models.User, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	user, ok := storage.usersByNick[strings.ToLower(nickname)]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, nickname)
+	}
+
+	found := *user
+	return &found, nil
+}
+
+func (storage *MemoryStorage) GetReputation(ctx context.Context, nickname string) (int, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	return storage.reputations("")[strings.ToLower(nickname)], nil
+}
+
+func (storage *MemoryStorage) UpdateUserProfile(ctx context.Context, oldUser *models.User) (*models.User, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	user, ok := storage.usersByNick[strings.ToLower(oldUser.Nickname)]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, oldUser.Nickname)
+	}
+
+	if oldUser.Email != "" {
+		email := strings.ToLower(oldUser.Email)
+		if owner, taken := storage.usersByMail[email]; taken && owner != user {
+			return nil, models.Conflict(models.EntityUser, oldUser.Nickname, "email is already taken")
+		}
+		delete(storage.usersByMail, strings.ToLower(user.Email))
+		user.Email = oldUser.Email
+		storage.usersByMail[email] = user
+	}
+	if oldUser.Fullname != "" {
+		user.Fullname = oldUser.Fullname
+	}
+	if oldUser.About != "" {
+		user.About = oldUser.About
+	}
+	if oldUser.PasswordHash != "" {
+		user.PasswordHash = oldUser.PasswordHash
+	}
+
+	newUser := *user
+	return &newUser, nil
+}
+
+// auth
+
+func (storage *MemoryStorage) GetPasswordHash(ctx context.Context, nickname string) (string, string, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	user, ok := storage.usersByNick[strings.ToLower(nickname)]
+	if !ok {
+		return "", "", models.NotFound(models.EntityUser, nickname)
+	}
+	return user.Nickname, user.PasswordHash, nil
+}
+
+func (storage *MemoryStorage) CreateSession(ctx context.Context, tokenHash string, session *models.Session) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if _, ok := storage.usersByNick[strings.ToLower(session.Nickname)]; !ok {
+		return models.NotFound(models.EntityUser, session.Nickname)
+	}
+	now := time.Now()
+	for hash, existing := range storage.sessions {
+		if strings.EqualFold(existing.Nickname, session.Nickname) && !existing.Expires.After(now) {
+			delete(storage.sessions, hash)
+		}
+	}
+	storage.sessions[tokenHash] = models.Session{Nickname: session.Nickname, Expires: session.Expires}
+	return nil
+}
+
+func (storage *MemoryStorage) GetSession(ctx context.Context, tokenHash string) (*models.Session, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	session, ok := storage.sessions[tokenHash]
+	if !ok || !session.Expires.After(time.Now()) {
+		return nil, models.NotFound(models.EntitySession, "token")
+	}
+	return &session, nil
+}
+
+func (storage *MemoryStorage) DeleteSession(ctx context.Context, tokenHash string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	delete(storage.sessions, tokenHash)
+	return nil
+}
+
+// roles
+
+func (storage *MemoryStorage) GetUserRole(ctx context.Context, nickname string) (string, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	if _, ok := storage.usersByNick[strings.ToLower(nickname)]; !ok {
+		return "", models.NotFound(models.EntityUser, nickname)
+	}
+	if role, ok := storage.roles[strings.ToLower(nickname)]; ok {
+		return role, nil
+	}
+	return models.RoleUser, nil
+}
+
+func (storage *MemoryStorage) SetUserRole(ctx context.Context, nickname string, role string) (*models.UserRole, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	user, ok := storage.usersByNick[strings.ToLower(nickname)]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, nickname)
+	}
+	storage.roles[strings.ToLower(nickname)] = role
+	return &models.UserRole{Nickname: user.Nickname, Role: role}, nil
+}
+
+func (storage *MemoryStorage) IsModerator(ctx context.Context, forum string, nickname string) (bool, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	_, ok := storage.moderators[strings.ToLower(forum)][strings.ToLower(nickname)]
+	return ok, nil
+}
+
+func (storage *MemoryStorage) AddModerator(ctx context.Context, forum string, nickname string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	user, ok := storage.usersByNick[strings.ToLower(nickname)]
+	if !ok {
+		return models.NotFound(models.EntityUser, nickname)
+	}
+	if _, ok = storage.forums[strings.ToLower(forum)]; !ok {
+		return models.NotFound(models.EntityForum, forum)
+	}
+	moderators, ok := storage.moderators[strings.ToLower(forum)]
+	if !ok {
+		moderators = make(map[string]*models.User)
+		storage.moderators[strings.ToLower(forum)] = moderators
+	}
+	moderators[strings.ToLower(nickname)] = user
+	return nil
+}
+
+func (storage *MemoryStorage) RemoveModerator(ctx context.Context, forum string, nickname string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	delete(storage.moderators[strings.ToLower(forum)], strings.ToLower(nickname))
+	return nil
+}
+
+func (storage *MemoryStorage) GetForumModerators(ctx context.Context, forum string) (*models.Users, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	users := models.Users{}
+	for _, user := range storage.moderators[strings.ToLower(forum)] {
+		moderator := *user
+		moderator.PasswordHash = ""
+		users = append(users, moderator)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Nickname) < strings.ToLower(users[j].Nickname)
+	})
+	return &users, nil
+}
+
+// forum
+
+func (storage *MemoryStorage) CreateForum(ctx context.Context, forum *models.Forum) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	author, ok := storage.usersByNick[strings.ToLower(forum.Author)]
+	if !ok {
+		return models.NotFound(models.EntityUser, forum.Author)
+	}
+	if _, exists := storage.forums[strings.ToLower(forum.Slug)]; exists {
+		return models.Conflict(models.EntityForum, forum.Slug, "")
+	}
+
+	storage.forums[strings.ToLower(forum.Slug)] = &models.Forum{
+		Title:  forum.Title,
+		Author: author.Nickname,
+		Slug:   forum.Slug,
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) GetForum(ctx context.Context, slug string) (*models.Forum, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	forum, ok := storage.forums[strings.ToLower(slug)]
+	if !ok {
+		return nil, models.NotFound(models.EntityForum, slug)
+	}
+
+	found := *forum
+	return &found, nil
+}
+
+func (storage *MemoryStorage) CreateThread(ctx context.Context, user *models.User, forum *models.Forum, thread *models.Thread) (*models.Thread, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if thread.Slug != "" {
+		if existingThread, exists := storage.threadsBySlug[strings.ToLower(thread.Slug)]; exists {
+			found := *existingThread
+			return &found, models.Conflict(models.EntityThread, thread.Slug, "")
+		}
+	}
+
+	storedForum, ok := storage.forums[strings.ToLower(forum.Slug)]
+	if !ok {
+		return nil, models.NotFound(models.EntityForum, forum.Slug)
+	}
+
+	thread.ID = len(storage.threads) + 1
+	thread.State = models.ThreadOpen
+	created := *thread
+	created.Forum = storedForum.Slug
+	created.Author = user.Nickname
+	storage.threads = append(storage.threads, &created)
+	if created.Slug != "" {
+		storage.threadsBySlug[strings.ToLower(created.Slug)] = &created
+	}
+
+	storage.addForumUser(storedForum.Slug, user)
+	storedForum.Threads++
+	storage.enqueue(threadCreated(&created))
+
+	return thread, nil
+}
+
+func (storage *MemoryStorage) GetThread(ctx context.Context, slugOrID interface{}) (*models.Thread, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	thread, ok := storage.findThread(slugOrID.(string))
+	if !ok || thread.State == models.ThreadDeleted {
+		return nil, models.NotFound(models.EntityThread, slugOrID.(string))
+	}
+
+	found := *thread
+	return &found, nil
+}
+
+func (storage *MemoryStorage) GetForumUsers(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte) (*models.Users, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.Internal(models.EntityForum, slug.(string), err)
+	}
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	rowsLimit, err := parseMemoryLimit(limit)
+	if err != nil {
+		return nil, models.Internal(models.EntityForum, slug.(string), err)
+	}
+	isDesc := bytes.Equal([]byte("true"), desc)
+	sinceNick := strings.ToLower(string(since))
+
+	var users models.Users
+	for nickname, user := range storage.forumUsers[strings.ToLower(slug.(string))] {
+		if since != nil {
+			if isDesc && nickname >= sinceNick || !isDesc && nickname <= sinceNick {
+				continue
+			}
+		}
+		users = append(users, *user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if isDesc {
+			return strings.ToLower(users[i].Nickname) > strings.ToLower(users[j].Nickname)
+		}
+		return strings.ToLower(users[i].Nickname) < strings.ToLower(users[j].Nickname)
+	})
+	if rowsLimit >= 0 && len(users) > rowsLimit {
+		users = users[:rowsLimit]
+	}
+
+	return &users, nil
+}
+
+func (storage *MemoryStorage) GetForumThreads(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte, archived bool) (*models.Threads, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.Internal(models.EntityForum, slug.(string), err)
+	}
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	rowsLimit, err := parseMemoryLimit(limit)
+	if err != nil {
+		return nil, models.Internal(models.EntityForum, slug.(string), err)
+	}
+	isDesc := bytes.Equal([]byte("true"), desc)
+
+	var sinceTime time.Time
+	if since != nil {
+		if sinceTime, err = time.Parse(time.RFC3339Nano, string(since)); err != nil {
+			return nil, models.Internal(models.EntityForum, slug.(string), err)
+		}
+	}
+
+	states := listedThreadStates(archived)
+	var threads models.Threads
+	for _, thread := range storage.threads {
+		if !strings.EqualFold(thread.Forum, slug.(string)) || !containsState(states, thread.State) {
+			continue
+		}
+		if since != nil {
+			if isDesc && thread.Created.After(sinceTime) || !isDesc && thread.Created.Before(sinceTime) {
+				continue
+			}
+		}
+		threads = append(threads, *thread)
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if isDesc {
+			return threads[i].Created.After(threads[j].Created)
+		}
+		return threads[i].Created.Before(threads[j].Created)
+	})
+	if rowsLimit >= 0 && len(threads) > rowsLimit {
+		threads = threads[:rowsLimit]
+	}
+
+	return &threads, nil
+}
+
+func (storage *MemoryStorage) GetForumLeaderboard(ctx context.Context, slug string, limit int) (*models.Users, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	users := models.Users{}
+	for nickname, reputation := range storage.reputations(slug) {
+		user := *storage.usersByNick[nickname]
+		reputation := reputation
+		user.Reputation = &reputation
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if *users[i].Reputation != *users[j].Reputation {
+			return *users[i].Reputation > *users[j].Reputation
+		}
+		return strings.ToLower(users[i].Nickname) < strings.ToLower(users[j].Nickname)
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return &users, nil
+}
+
+// threads
+
+func (storage *MemoryStorage) CreatePosts(ctx context.Context, slugOrID interface{}, posts *models.Posts) (*models.Posts, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	thread, ok := storage.findThread(slugOrID.(string))
+	if !ok || thread.State == models.ThreadDeleted {
+		return nil, models.NotFound(models.EntityThread, slugOrID.(string))
+	}
+	if thread.State != models.ThreadOpen {
+		return nil, models.Conflict(models.EntityThread, slugOrID.(string), "thread is "+thread.State)
+	}
+
+	if len(*posts) == 0 {
+		return nil, nil
+	}
+
+	authors := make([]*models.User, len(*posts))
+	parents := make([][]int32, len(*posts))
+	for i, post := range *posts {
+		if post.Parent != 0 {
+			parent, ok := storage.findPost(int(post.Parent))
+			if !ok || parent.post.Thread != thread.ID {
+				return nil, models.Conflict(models.EntityPost, strconv.Itoa(int(post.Parent)), "parent post was created in another thread")
+			}
+			parents[i] = parent.post.Parents
+		}
+	}
+	for i, post := range *posts {
+		author, ok := storage.usersByNick[strings.ToLower(post.Author)]
+		if !ok {
+			return nil, models.NotFound(models.EntityUser, post.Author)
+		}
+		authors[i] = author
+	}
+
+	created := time.Unix(0, 0)
+	currentPosts := new(models.Posts)
+	for i, post := range *posts {
+		post.ID = len(storage.posts) + 1
+		post.Thread = thread.ID
+		post.Forum = thread.Forum
+		post.Created = created
+		post.Author = authors[i].Nickname
+		post.Parents = append(append([]int32(nil), parents[i]...), int32(post.ID))
+
+		storage.posts = append(storage.posts, &memoryPost{post: post, mainParent: post.Parents[0]})
+		storage.addForumUser(thread.Forum, authors[i])
+		*currentPosts = append(*currentPosts, post)
+	}
+
+	storage.forums[strings.ToLower(thread.Forum)].Posts += len(*posts)
+	storage.enqueue(postsCreated(*currentPosts)...)
+
+	return currentPosts, nil
+}
+
+func (storage *MemoryStorage) UpdateThread(ctx context.Context, threadID int, threadUpdate *models.ThreadUpdate) (*models.Thread, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	thread, ok := storage.findThread(strconv.Itoa(threadID))
+	if !ok {
+		return nil, models.NotFound(models.EntityThread, strconv.Itoa(threadID))
+	}
+
+	revision := models.Revision{Editor: threadUpdate.Editor, Created: time.Now(), Title: thread.Title, Message: thread.Message}
+	if threadUpdate.Message != nil {
+		thread.Message = *threadUpdate.Message
+	}
+	if threadUpdate.Title != nil {
+		thread.Title = *threadUpdate.Title
+	}
+	if thread.Title != revision.Title || thread.Message != revision.Message {
+		storage.threadRevisions[thread.ID] = append(storage.threadRevisions[thread.ID], revision)
+	}
+
+	updated := *thread
+	return &updated, nil
+}
+
+func (storage *MemoryStorage) GetThreadRevisions(ctx context.Context, threadID int) (*models.Revisions, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	revisions := append(models.Revisions{}, storage.threadRevisions[threadID]...)
+	return &revisions, nil
+}
+
+func (storage *MemoryStorage) GetThreadEdits(ctx context.Context, threadIDs []int) (map[int]time.Time, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	edits := make(map[int]time.Time)
+	for _, id := range threadIDs {
+		if revisions := storage.threadRevisions[id]; len(revisions) > 0 {
+			edits[id] = revisions[len(revisions)-1].Created
+		}
+	}
+	return edits, nil
+}
+
+func (storage *MemoryStorage) SetThreadState(ctx context.Context, slugOrID string, from []string, to string) (*models.Thread, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	thread, ok := storage.findThread(slugOrID)
+	if !ok {
+		return nil, models.NotFound(models.EntityThread, slugOrID)
+	}
+	if thread.State != to && !containsState(from, thread.State) {
+		return nil, models.Conflict(models.EntityThread, slugOrID, "thread is "+thread.State)
+	}
+
+	if delta := deletedDelta(thread.State, to); delta != 0 {
+		livePosts := 0
+		for _, post := range storage.posts {
+			if post != nil && post.post.Thread == thread.ID && !post.post.IsDeleted {
+				livePosts++
+			}
+		}
+		forum := storage.forums[strings.ToLower(thread.Forum)]
+		forum.Threads += delta
+		forum.Posts += delta * livePosts
+	}
+	thread.State = to
+
+	updated := *thread
+	return &updated, nil
+}
+
+func (storage *MemoryStorage) GetThreadPosts(ctx context.Context, slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.Internal(models.EntityThread, *slugOrID, err)
+	}
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	thread, ok := storage.findThread(*slugOrID)
+	if !ok || thread.State == models.ThreadDeleted {
+		return nil, models.NotFound(models.EntityThread, *slugOrID)
+	}
+
+	rowsLimit, err := parseMemoryLimit(limit)
+	if err != nil {
+		return nil, models.Internal(models.EntityThread, *slugOrID, err)
+	}
+	isDesc := bytes.Equal([]byte("true"), desc)
+
+	var sincePost *memoryPost
+	if since != nil {
+		sinceID, err := strconv.Atoi(string(since))
+		if err != nil {
+			return nil, models.Internal(models.EntityThread, *slugOrID, err)
+		}
+		if sincePost, ok = storage.findPost(sinceID); !ok {
+			return &models.Posts{}, nil
+		}
+	}
+
+	var threadPosts []*memoryPost
+	for _, post := range storage.posts {
+		if post != nil && post.post.Thread == thread.ID {
+			threadPosts = append(threadPosts, post)
+		}
+	}
+
+	var selected []*memoryPost
+	switch true {
+	case bytes.Equal([]byte("tree"), sort):
+		selected = memoryPostsTree(threadPosts, rowsLimit, sincePost, isDesc)
+	case bytes.Equal([]byte("parent_tree"), sort):
+		selected = memoryPostsParentTree(threadPosts, rowsLimit, sincePost, isDesc)
+	default:
+		selected = memoryPostsFlat(threadPosts, rowsLimit, sincePost, isDesc)
+	}
+
+	var posts models.Posts
+	for _, post := range selected {
+		posts = append(posts, post.view())
+	}
+
+	return &posts, nil
+}
+
+func memoryPostsFlat(posts []*memoryPost, limit int, since *memoryPost, desc bool) []*memoryPost {
+	var selected []*memoryPost
+	for _, post := range posts {
+		if since != nil && (desc && post.post.ID >= since.post.ID || !desc && post.post.ID <= since.post.ID) {
+			continue
+		}
+		selected = append(selected, post)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if desc {
+			return selected[i].post.ID > selected[j].post.ID
+		}
+		return selected[i].post.ID < selected[j].post.ID
+	})
+
+	return limitMemoryPosts(selected, limit)
+}
+
+func memoryPostsTree(posts []*memoryPost, limit int, since *memoryPost, desc bool) []*memoryPost {
+	var selected []*memoryPost
+	for _, post := range posts {
+		if since != nil {
+			cmp := compareParents(post.post.Parents, since.post.Parents)
+			if desc && cmp >= 0 || !desc && cmp <= 0 {
+				continue
+			}
+		}
+		selected = append(selected, post)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		cmp := compareParents(selected[i].post.Parents, selected[j].post.Parents)
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	return limitMemoryPosts(selected, limit)
+}
+
+func memoryPostsParentTree(posts []*memoryPost, limit int, since *memoryPost, desc bool) []*memoryPost {
+	var roots []*memoryPost
+	for _, post := range posts {
+		if post.post.Parent != 0 {
+			continue
+		}
+		if since != nil && (desc && post.mainParent >= since.mainParent || !desc && post.mainParent <= since.mainParent) {
+			continue
+		}
+		roots = append(roots, post)
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		if desc {
+			return roots[i].post.ID > roots[j].post.ID
+		}
+		return roots[i].post.ID < roots[j].post.ID
+	})
+	roots = limitMemoryPosts(roots, limit)
+
+	selectedRoots := make(map[int32]bool, len(roots))
+	for _, root := range roots {
+		selectedRoots[int32(root.post.ID)] = true
+	}
+
+	var selected []*memoryPost
+	for _, post := range posts {
+		if selectedRoots[post.mainParent] {
+			selected = append(selected, post)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		lhs, rhs := selected[i].post.Parents, selected[j].post.Parents
+		if desc && lhs[0] != rhs[0] {
+			return lhs[0] > rhs[0]
+		}
+		return compareParents(lhs, rhs) < 0
+	})
+
+	return selected
+}
+
+func (storage *MemoryStorage) PutVote(ctx context.Context, slugOrID interface{}, vote *models.Vote) (*models.Thread, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	thread, ok := storage.findThread(slugOrID.(string))
+	if !ok {
+		return nil, models.NotFound(models.EntityThread, slugOrID.(string))
+	}
+	user, ok := storage.usersByNick[strings.ToLower(vote.Nickname)]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, vote.Nickname)
+	}
+
+	key := memoryVoteKey{nickname: strings.ToLower(user.Nickname), threadID: thread.ID}
+	thread.Votes += vote.Voice - storage.votes[key].voice
+	storage.votes[key] = memoryVote{voice: vote.Voice, voted: time.Now()}
+
+	updated := *thread
+	storage.enqueue(threadVoted(&updated, vote.Nickname, vote.Voice))
+	return &updated, nil
+}
+
+func (storage *MemoryStorage) DeleteVote(ctx context.Context, threadID int, nickname string) (*models.Thread, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	thread, ok := storage.findThread(strconv.Itoa(threadID))
+	if !ok {
+		return nil, models.NotFound(models.EntityThread, strconv.Itoa(threadID))
+	}
+
+	key := memoryVoteKey{nickname: strings.ToLower(nickname), threadID: thread.ID}
+	thread.Votes -= storage.votes[key].voice
+	delete(storage.votes, key)
+
+	updated := *thread
+	storage.enqueue(threadVoted(&updated, nickname, 0))
+	return &updated, nil
+}
+
+func (storage *MemoryStorage) GetThreadVotes(ctx context.Context, threadID int, query models.VotersQuery) (*models.ThreadVotes, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	since := strings.ToLower(query.Since)
+	votes := &models.ThreadVotes{Voters: []models.Voter{}}
+	for key, vote := range storage.votes {
+		if key.threadID != threadID {
+			continue
+		}
+		votes.Votes += vote.voice
+		if vote.voice > 0 {
+			votes.Up++
+		} else {
+			votes.Down++
+		}
+		if since != "" && (query.Desc && key.nickname >= since || !query.Desc && key.nickname <= since) {
+			continue
+		}
+		votes.Voters = append(votes.Voters, models.Voter{Nickname: storage.usersByNick[key.nickname].Nickname, Voice: vote.voice, Voted: vote.voted})
+	}
+
+	sort.Slice(votes.Voters, func(i, j int) bool {
+		lhs, rhs := strings.ToLower(votes.Voters[i].Nickname), strings.ToLower(votes.Voters[j].Nickname)
+		if query.Desc {
+			return lhs > rhs
+		}
+		return lhs < rhs
+	})
+	if len(votes.Voters) > query.Limit {
+		votes.Voters = votes.Voters[:query.Limit]
+	}
+
+	return votes, nil
+}
+
+// post
+
+func (storage *MemoryStorage) GetPostDetails(ctx context.Context, id *string, related []byte) (*models.PostDetails, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	postID, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	post, ok := storage.findPost(postID)
+	if !ok {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+
+	postDetails := models.PostDetails{}
+	found := post.view()
+	postDetails.PostDetails = &found
+
+	if related == nil {
+		return &postDetails, nil
+	}
+
+	for _, val := range strings.Split(string(related), ",") {
+		switch val {
+		case "user":
+			author := *storage.usersByNick[strings.ToLower(found.Author)]
+			postDetails.AuthorDetails = &author
+		case "forum":
+			forum := *storage.forums[strings.ToLower(found.Forum)]
+			postDetails.ForumDetails = &forum
+		case "thread":
+			thread, _ := storage.findThread(strconv.Itoa(found.Thread))
+			threadDetails := *thread
+			postDetails.ThreadDetails = &threadDetails
+		}
+	}
+	return &postDetails, nil
+}
+
+func (storage *MemoryStorage) UpdatePostDetails(ctx context.Context, id *string, postUpd *models.PostUpdate) (*models.Post, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	postID, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	post, ok := storage.findPost(postID)
+	if !ok {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	if post.post.IsDeleted {
+		return nil, models.Conflict(models.EntityPost, *id, "post is deleted")
+	}
+
+	changed := postUpd.Message != nil && *postUpd.Message != post.post.Message
+	if changed {
+		post.revisions = append(post.revisions, models.Revision{Editor: postUpd.Editor, Created: time.Now(), Message: post.post.Message})
+		post.post.Message = *postUpd.Message
+		post.post.IsEdited = true
+	}
+
+	postUpdated := post.view()
+	if changed {
+		storage.enqueue(postEvent(models.HookPostUpdated, &postUpdated))
+	}
+	return &postUpdated, nil
+}
+
+func (storage *MemoryStorage) GetPostRevisions(ctx context.Context, id int) (*models.Revisions, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	revisions := models.Revisions{}
+	if post, ok := storage.findPost(id); ok {
+		revisions = append(revisions, post.revisions...)
+	}
+	return &revisions, nil
+}
+
+func (storage *MemoryStorage) GetPostEdits(ctx context.Context, ids []int) (map[int]time.Time, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	edits := make(map[int]time.Time)
+	for _, id := range ids {
+		if post, ok := storage.findPost(id); ok && len(post.revisions) > 0 {
+			edits[id] = post.revisions[len(post.revisions)-1].Created
+		}
+	}
+	return edits, nil
+}
+
+func (storage *MemoryStorage) GetPosts(ctx context.Context, ids []int) (map[int]*models.Post, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	posts := make(map[int]*models.Post, len(ids))
+	for _, id := range ids {
+		if post, ok := storage.findPost(id); ok {
+			found := post.view()
+			posts[id] = &found
+		}
+	}
+	return posts, nil
+}
+
+func (storage *MemoryStorage) PutPostVote(ctx context.Context, id *string, vote *models.Vote) (*models.Post, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	postID, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	post, ok := storage.findPost(postID)
+	if !ok {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	if post.post.IsDeleted {
+		return nil, models.Conflict(models.EntityPost, *id, "post is deleted")
+	}
+	user, ok := storage.usersByNick[strings.ToLower(vote.Nickname)]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, vote.Nickname)
+	}
+
+	if post.voices == nil {
+		post.voices = make(map[string]int)
+	}
+	key := strings.ToLower(user.Nickname)
+	post.post.Votes += vote.Voice - post.voices[key]
+	post.voices[key] = vote.Voice
+
+	voted := post.view()
+	storage.enqueue(postVoted(&voted, vote.Nickname, vote.Voice))
+	return &voted, nil
+}
+
+func (storage *MemoryStorage) DeletePost(ctx context.Context, id *string) (*models.Post, error) {
+	return storage.setPostDeleted(id, true)
+}
+
+func (storage *MemoryStorage) RestorePost(ctx context.Context, id *string) (*models.Post, error) {
+	return storage.setPostDeleted(id, false)
+}
+
+func (storage *MemoryStorage) setPostDeleted(id *string, deleted bool) (*models.Post, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	postID, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	post, ok := storage.findPost(postID)
+	if !ok {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+
+	if post.post.IsDeleted != deleted {
+		post.post.IsDeleted = deleted
+		if deleted {
+			storage.forums[strings.ToLower(post.post.Forum)].Posts--
+		} else {
+			storage.forums[strings.ToLower(post.post.Forum)].Posts++
+		}
+	}
+
+	found := post.view()
+	return &found, nil
+}
+
+func (storage *MemoryStorage) PurgePost(ctx context.Context, id *string) (int, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	postID, err := strconv.Atoi(*id)
+	if err != nil {
+		return 0, models.NotFound(models.EntityPost, *id)
+	}
+	if _, ok := storage.findPost(postID); !ok {
+		return 0, models.NotFound(models.EntityPost, *id)
+	}
+
+	purged := 0
+	for i, post := range storage.posts {
+		if post == nil || !memoryHasAncestor(post.post.Parents, int32(postID)) {
+			continue
+		}
+		if !post.post.IsDeleted {
+			storage.forums[strings.ToLower(post.post.Forum)].Posts--
+		}
+		storage.posts[i] = nil
+		purged++
+	}
+	return purged, nil
+}
+
+// search
+
+// Search matches whole words, all of them required, in place of the Postgres
+// text search; it knows none of the websearch operators.
+func (storage *MemoryStorage) Search(ctx context.Context, query models.SearchQuery) (*models.SearchResults, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	terms := make(map[string]bool)
+	for _, term := range memoryWords(query.Text) {
+		terms[term] = true
+	}
+
+	var results []models.SearchResult
+	matches := func(forum, author string) bool {
+		return (query.Forum == "" || strings.EqualFold(forum, query.Forum)) &&
+			(query.Author == "" || strings.EqualFold(author, query.Author))
+	}
+	for _, thread := range storage.threads {
+		document := thread.Title + " " + thread.Message
+		if thread.State == models.ThreadDeleted || !matches(thread.Forum, thread.Author) || !memoryMatches(terms, document) {
+			continue
+		}
+		// Title words count extra, as the weight 'A' does in Postgres.
+		found := *thread
+		results = append(results, models.SearchResult{Kind: models.SearchThread,
+			Rank:    memoryRank(terms, found.Title, 2) + memoryRank(terms, document, 1),
+			Snippet: memoryHighlight(document, terms), Thread: &found})
+	}
+	for _, post := range storage.posts {
+		if post == nil || post.post.IsDeleted || storage.threads[post.post.Thread-1].State == models.ThreadDeleted ||
+			!matches(post.post.Forum, post.post.Author) || !memoryMatches(terms, post.post.Message) {
+			continue
+		}
+		found := post.view()
+		results = append(results, models.SearchResult{Kind: models.SearchPost, Rank: memoryRank(terms, found.Message, 1),
+			Snippet: memoryHighlight(found.Message, terms), Post: &found})
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Cursor().Before(results[j].Cursor()) })
+	page := &models.SearchResults{Results: make([]models.SearchResult, 0, query.Limit)}
+	for _, result := range results {
+		if query.Since != nil && !query.Since.Before(result.Cursor()) {
+			continue
+		}
+		if len(page.Results) == query.Limit {
+			break
+		}
+		page.Results = append(page.Results, result)
+	}
+	if len(page.Results) == query.Limit && query.Limit > 0 {
+		page.Next = page.Results[len(page.Results)-1].Cursor().String()
+	}
+	return page, nil
+}
+
+func memoryWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func memoryMatches(terms map[string]bool, document string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	found := make(map[string]bool, len(terms))
+	for _, word := range memoryWords(document) {
+		if terms[word] {
+			found[word] = true
+		}
+	}
+	return len(found) == len(terms)
+}
+
+// memoryRank is the share of words in document that match, times weight.
+func memoryRank(terms map[string]bool, document string, weight float32) float32 {
+	words := memoryWords(document)
+	hits := 0
+	for _, word := range words {
+		if terms[word] {
+			hits++
+		}
+	}
+	if hits == 0 {
+		return 0
+	}
+	return weight * float32(hits) / float32(len(words))
+}
+
+// memoryHighlight wraps the matching words of text like ts_headline does.
+func memoryHighlight(text string, terms map[string]bool) string {
+	var snippet strings.Builder
+	word := -1
+	flush := func(end int) {
+		if word < 0 {
+			return
+		}
+		if terms[strings.ToLower(text[word:end])] {
+			snippet.WriteString("<mark>" + text[word:end] + "</mark>")
+		} else {
+			snippet.WriteString(text[word:end])
+		}
+		word = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if word < 0 {
+				word = i
+			}
+			continue
+		}
+		flush(i)
+		snippet.WriteRune(r)
+	}
+	flush(len(text))
+	return snippet.String()
+}
+
+// webhooks
+
+func (storage *MemoryStorage) CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	if hook.Forum != "" {
+		forum, ok := storage.forums[strings.ToLower(hook.Forum)]
+		if !ok {
+			return models.NotFound(models.EntityForum, hook.Forum)
+		}
+		hook.Forum = forum.Slug
+	}
+	storage.webhookID++
+	hook.ID = storage.webhookID
+	hook.Created = time.Now().UTC()
+	stored := *hook
+	stored.Events = append([]string(nil), hook.Events...)
+	storage.webhooks = append(storage.webhooks, &stored)
+	return nil
+}
+
+func (storage *MemoryStorage) GetWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	hooks := models.Webhooks{}
+	for _, hook := range storage.webhooks {
+		found := *hook
+		found.Secret = ""
+		hooks = append(hooks, found)
+	}
+	return &hooks, nil
+}
+
+func (storage *MemoryStorage) DeleteWebhook(ctx context.Context, id int) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	index := storage.webhookIndex(id)
+	if index < 0 {
+		return models.NotFound(models.EntityWebhook, strconv.Itoa(id))
+	}
+	storage.webhooks = append(storage.webhooks[:index], storage.webhooks[index+1:]...)
+	kept := storage.deliveries[:0]
+	for _, delivery := range storage.deliveries {
+		if delivery.delivery.Webhook != id {
+			kept = append(kept, delivery)
+		}
+	}
+	storage.deliveries = kept
+	return nil
+}
+
+func (storage *MemoryStorage) SetWebhooks(enabled bool) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	storage.hooked = enabled
+}
+
+func (storage *MemoryStorage) EnqueueWebhookEvents(ctx context.Context, events []models.WebhookEvent) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.queueDeliveries(events)
+	return nil
+}
+
+func (storage *MemoryStorage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	now := time.Now()
+	var due []*memoryDelivery
+	for _, delivery := range storage.deliveries {
+		if delivery.delivery.State == models.DeliveryPending && !delivery.next.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].next.Before(due[j].next) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	deliveries := make([]models.PendingDelivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.next = now.Add(lease)
+		hook := storage.webhooks[storage.webhookIndex(delivery.delivery.Webhook)]
+		deliveries = append(deliveries, models.PendingDelivery{
+			ID:       delivery.delivery.ID,
+			Webhook:  hook.ID,
+			URL:      hook.URL,
+			Secret:   hook.Secret,
+			Event:    delivery.delivery.Event,
+			Payload:  delivery.delivery.Payload,
+			Attempts: delivery.delivery.Attempts,
+		})
+	}
+	return deliveries, nil
+}
+
+func (storage *MemoryStorage) FinishWebhookAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	for _, delivery := range storage.deliveries {
+		if delivery.delivery.ID != id {
+			continue
+		}
+		found := &delivery.delivery
+		found.State, found.Status, found.Error = attempt.State, attempt.Status, attempt.Error
+		found.Attempts++
+		if attempt.State == models.DeliveryPending {
+			delivery.next = attempt.Next
+		} else {
+			finished := time.Now().UTC()
+			found.Finished = &finished
+		}
+		return nil
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) GetWebhookDeliveries(ctx context.Context, webhookID int, query models.DeliveriesQuery) (*models.WebhookDeliveries, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	if storage.webhookIndex(webhookID) < 0 {
+		return nil, models.NotFound(models.EntityWebhook, strconv.Itoa(webhookID))
+	}
+	deliveries := models.WebhookDeliveries{}
+	for i := len(storage.deliveries) - 1; i >= 0 && len(deliveries) < query.Limit; i-- {
+		delivery := storage.deliveries[i]
+		found := delivery.delivery
+		if found.Webhook != webhookID || (query.Since != 0 && found.ID >= query.Since) ||
+			(query.State != "" && found.State != query.State) {
+			continue
+		}
+		if found.State == models.DeliveryPending {
+			next := delivery.next.UTC()
+			found.NextAttempt = &next
+		}
+		deliveries = append(deliveries, found)
+	}
+	return &deliveries, nil
+}
+
+// export
+
+// ExportForum copies the records of the forum under the lock and writes them
+// after it, so a slow reader holds up no one; the memory backend keeps the
+// whole forum in memory anyway.
+func (storage *MemoryStorage) ExportForum(ctx context.Context, slug string, write func(*models.ExportRecord) error) error {
+	records, err := storage.exportRecords(slug)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err = ctx.Err(); err != nil {
+			return models.Internal(models.EntityForum, slug, err)
+		}
+		if err = write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (storage *MemoryStorage) exportRecords(slug string) ([]*models.ExportRecord, error) {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	stored, ok := storage.forums[strings.ToLower(slug)]
+	if !ok {
+		return nil, models.NotFound(models.EntityForum, slug)
+	}
+	forum := *stored
+
+	// Users are gathered by lower-cased nickname as the forum goes by.
+	users := map[string]struct{}{strings.ToLower(forum.Author): {}}
+	for nickname := range storage.moderators[strings.ToLower(forum.Slug)] {
+		users[strings.ToLower(nickname)] = struct{}{}
+	}
+
+	var content []*models.ExportRecord
+	threads := make(map[int]struct{})
+	for _, stored := range storage.threads {
+		if !strings.EqualFold(stored.Forum, forum.Slug) {
+			continue
+		}
+		thread := *stored
+		threads[thread.ID] = struct{}{}
+		users[strings.ToLower(thread.Author)] = struct{}{}
+		content = append(content, &models.ExportRecord{Type: models.ExportThread, Thread: &thread})
+	}
+
+	var posts []*memoryPost
+	for _, post := range storage.posts {
+		if post == nil {
+			continue
+		}
+		if _, ok := threads[post.post.Thread]; ok {
+			posts = append(posts, post)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		lhs, rhs := posts[i].post, posts[j].post
+		if lhs.Thread != rhs.Thread {
+			return lhs.Thread < rhs.Thread
+		}
+		return lessPath(lhs.Parents, rhs.Parents)
+	})
+	var postVotes []*models.ExportRecord
+	for _, stored := range posts {
+		post := stored.post
+		post.Parents = append([]int32(nil), stored.post.Parents...)
+		users[strings.ToLower(post.Author)] = struct{}{}
+		content = append(content, &models.ExportRecord{Type: models.ExportPost, Post: &post})
+
+		voters := make([]string, 0, len(stored.voices))
+		for nickname := range stored.voices {
+			voters = append(voters, nickname)
+		}
+		sort.Strings(voters)
+		for _, nickname := range voters {
+			users[nickname] = struct{}{}
+			vote := &models.Vote{Nickname: storage.usersByNick[nickname].Nickname, Voice: stored.voices[nickname]}
+			postVotes = append(postVotes, &models.ExportRecord{Type: models.ExportPostVote, Vote: vote, Target: post.ID})
+		}
+	}
+
+	var threadVotes []*models.ExportRecord
+	for key, stored := range storage.votes {
+		if _, ok := threads[key.threadID]; !ok {
+			continue
+		}
+		users[key.nickname] = struct{}{}
+		voted := stored.voted
+		vote := &models.Vote{Nickname: storage.usersByNick[key.nickname].Nickname, Voice: stored.voice}
+		threadVotes = append(threadVotes, &models.ExportRecord{Type: models.ExportThreadVote, Vote: vote, Target: key.threadID, Voted: &voted})
+	}
+	sort.Slice(threadVotes, func(i, j int) bool {
+		if threadVotes[i].Target != threadVotes[j].Target {
+			return threadVotes[i].Target < threadVotes[j].Target
+		}
+		return strings.ToLower(threadVotes[i].Vote.Nickname) < strings.ToLower(threadVotes[j].Vote.Nickname)
+	})
+
+	nicknames := make([]string, 0, len(users))
+	for nickname := range users {
+		if _, ok := storage.usersByNick[nickname]; ok {
+			nicknames = append(nicknames, nickname)
+		}
+	}
+	sort.Strings(nicknames)
+	records := make([]*models.ExportRecord, 0, len(nicknames)+1+len(content)+len(threadVotes)+len(postVotes))
+	for _, nickname := range nicknames {
+		stored := storage.usersByNick[nickname]
+		user := models.User{Email: stored.Email, Nickname: stored.Nickname, Fullname: stored.Fullname, About: stored.About}
+		records = append(records, &models.ExportRecord{Type: models.ExportUser, User: &user})
+	}
+	records = append(records, &models.ExportRecord{Type: models.ExportForum, Forum: &forum})
+	records = append(records, content...)
+	records = append(records, threadVotes...)
+	return append(records, postVotes...), nil
+}
+
+// helpers, callers must hold the lock
+
+// enqueue queues deliveries of the events a write made, if SetWebhooks asked
+// for them.
+func (storage *MemoryStorage) enqueue(events ...models.WebhookEvent) {
+	if storage.hooked {
+		storage.queueDeliveries(events)
+	}
+}
+
+func (storage *MemoryStorage) queueDeliveries(events []models.WebhookEvent) {
+	now := time.Now().UTC()
+	for _, event := range events {
+		var payload []byte
+		for _, hook := range storage.webhooks {
+			if !memoryHookTakes(hook, event) {
+				continue
+			}
+			if payload == nil {
+				payload, _ = easyjson.Marshal(event)
+			}
+			storage.deliveryID++
+			storage.deliveries = append(storage.deliveries, &memoryDelivery{
+				delivery: models.WebhookDelivery{ID: storage.deliveryID, Webhook: hook.ID, Event: event.Event,
+					State: models.DeliveryPending, Created: now, Payload: payload},
+				next: now,
+			})
+		}
+	}
+}
+
+// memoryHookTakes reports whether event is due to hook, as the join of
+// EnqueueWebhookEvents decides it.
+func memoryHookTakes(hook *models.Webhook, event models.WebhookEvent) bool {
+	if hook.Forum != "" && !strings.EqualFold(hook.Forum, event.Forum) {
+		return false
+	}
+	for _, name := range hook.Events {
+		if name == event.Event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookIndex returns the index of the webhook in storage.webhooks, -1 if
+// there is none.
+func (storage *MemoryStorage) webhookIndex(id int) int {
+	for i, hook := range storage.webhooks {
+		if hook.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (storage *MemoryStorage) findThread(slugOrID string) (*models.Thread, bool) {
+	id, err := strconv.Atoi(slugOrID)
+	if err != nil {
+		thread, ok := storage.threadsBySlug[strings.ToLower(slugOrID)]
+		return thread, ok
+	}
+	if id < 1 || id > len(storage.threads) {
+		return nil, false
+	}
+	return storage.threads[id-1], true
+}
+
+// lessPath orders posts by their path of parents, as Postgres orders arrays:
+// element by element, a prefix first.
+func lessPath(lhs, rhs []int32) bool {
+	for i := 0; i < len(lhs) && i < len(rhs); i++ {
+		if lhs[i] != rhs[i] {
+			return lhs[i] < rhs[i]
+		}
+	}
+	return len(lhs) < len(rhs)
+}
+
+func (storage *MemoryStorage) findPost(id int) (*memoryPost, bool) {
+	if id < 1 || id > len(storage.posts) || storage.posts[id-1] == nil {
+		return nil, false
+	}
+	return storage.posts[id-1], true
+}
+
+// reputations sums the votes on the threads and posts of every author, only
+// counting those in forum unless it is empty, like GetReputation does.
+func (storage *MemoryStorage) reputations(forum string) map[string]int {
+	reputations := make(map[string]int)
+	for _, thread := range storage.threads {
+		if thread.State == models.ThreadDeleted || forum != "" && !strings.EqualFold(thread.Forum, forum) {
+			continue
+		}
+		reputations[strings.ToLower(thread.Author)] += thread.Votes
+	}
+	for _, post := range storage.posts {
+		if post == nil || post.post.IsDeleted || forum != "" && !strings.EqualFold(post.post.Forum, forum) {
+			continue
+		}
+		if storage.threads[post.post.Thread-1].State == models.ThreadDeleted {
+			continue
+		}
+		reputations[strings.ToLower(post.post.Author)] += post.post.Votes
+	}
+	return reputations
+}
+
+func (storage *MemoryStorage) countPosts() int {
+	count := 0
+	for _, post := range storage.posts {
+		if post != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// memoryHasAncestor reports whether the materialized path parents passes
+// through id, the post itself included.
+func memoryHasAncestor(parents []int32, id int32) bool {
+	for _, parent := range parents {
+		if parent == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (storage *MemoryStorage) addForumUser(forumSlug string, user *models.User) {
+	forumKey := strings.ToLower(forumSlug)
+	if storage.forumUsers[forumKey] == nil {
+		storage.forumUsers[forumKey] = make(map[string]*models.User)
+	}
+	storage.forumUsers[forumKey][strings.ToLower(user.Nickname)] = user
+}
+
+// parseMemoryLimit mirrors the `$n::TEXT::INTEGER` cast of the SQL queries,
+// a missing limit means no limit at all.
+func parseMemoryLimit(limit []byte) (int, error) {
+	if limit == nil {
+		return -1, nil
+	}
+	return strconv.Atoi(string(limit))
+}
+
+func limitMemoryPosts(posts []*memoryPost, limit int) []*memoryPost {
+	if limit >= 0 && len(posts) > limit {
+		return posts[:limit]
+	}
+	return posts
+}
+
+// compareParents orders materialized paths the way Postgres compares INT[].
+func compareParents(lhs, rhs []int32) int {
+	for i := 0; i < len(lhs) && i < len(rhs); i++ {
+		if lhs[i] != rhs[i] {
+			if lhs[i] < rhs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(lhs) - len(rhs)
+}
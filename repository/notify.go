@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/mailru/easyjson"
+	"strconv"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+)
+
+// eventChannel is the LISTEN/NOTIFY channel events travel on between server
+// instances sharing a database.
+const eventChannel = "forum_events"
+
+// maxNotification bounds the payload of one notification, which NOTIFY
+// limits to 8000 bytes.
+const maxNotification = 7900
+
+// Publish announces events to every instance listening on the database, this
+// one included, in one round trip however many there are. They travel as
+// few notifications as fit them, with the posts and threads cut down to their
+// ids; listeners load them themselves.
+func (storage *Storage) Publish(ctx context.Context, events ...models.Event) error {
+	query := statement("Publish.query", `SELECT pg_notify($1, payload) FROM unnest($2::TEXT[]) AS payload`)
+
+	if len(events) == 0 {
+		return nil
+	}
+	if _, err := storage.db.ExecEx(ctx, query, nil, eventChannel, formatNotifications(events)); err != nil {
+		return storage.internal(ctx, models.EntityThread, strconv.Itoa(events[0].Thread), err)
+	}
+	return nil
+}
+
+// Listen holds a connection listening for the events announced by Publish and
+// hands them to handle as they were announced together, with only the ids of
+// the posts and threads filled in, until ctx is done or the connection fails.
+// Events announced while nobody listens are lost.
+func (storage *Storage) Listen(ctx context.Context, handle func([]models.Event)) error {
+	conn, err := storage.db.AcquireEx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if conn.IsAlive() {
+			_ = conn.Unlisten(eventChannel)
+		}
+		storage.db.Release(conn)
+	}()
+
+	if err = conn.Listen(eventChannel); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		events, err := parseNotification(notification.Payload)
+		if err != nil {
+			logging.For(ctx, storage.log).Warn().Err(err).Str("payload", notification.Payload).Msg("ignoring event notification")
+			continue
+		}
+		handle(events)
+	}
+}
+
+// formatNotifications encodes events as JSON arrays of at most
+// maxNotification bytes, with their posts and threads cut down to their ids.
+func formatNotifications(events []models.Event) []string {
+	var payloads []string
+	batch := []byte{'['}
+	for _, event := range events {
+		if event.Post != nil {
+			event.Post = &models.Post{ID: event.Post.ID}
+		}
+		if event.Details != nil {
+			event.Details = &models.Thread{ID: event.Details.ID}
+		}
+		encoded, _ := easyjson.Marshal(event)
+		if len(batch) > 1 && len(batch)+len(encoded)+1 > maxNotification {
+			payloads = append(payloads, string(append(batch[:len(batch)-1], ']')))
+			batch = []byte{'['}
+		}
+		batch = append(append(batch, encoded...), ',')
+	}
+	if len(batch) > 1 {
+		payloads = append(payloads, string(append(batch[:len(batch)-1], ']')))
+	}
+	return payloads
+}
+
+func parseNotification(payload string) ([]models.Event, error) {
+	var events models.Events
+	if err := easyjson.Unmarshal([]byte(payload), &events); err != nil || len(events) == 0 {
+		return nil, fmt.Errorf("malformed event notification %q", payload)
+	}
+	for _, event := range events {
+		switch event.Type {
+		case models.EventThread, models.EventPost, models.EventEdit, models.EventVote:
+		default:
+			return nil, fmt.Errorf("malformed event notification %q", payload)
+		}
+		if event.Post == nil && event.Details == nil {
+			return nil, fmt.Errorf("malformed event notification %q", payload)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"strings"
+	"technopark-forum/models"
+	"testing"
+)
+
+func TestNotification(t *testing.T) {
+	event := models.Event{Type: models.EventEdit, Forum: "pirates", Thread: 3, User: "jack", Post: &models.Post{ID: 42, Thread: 3, Message: "ahoy"}}
+	vote := models.Event{Type: models.EventVote, Thread: 3, User: "jack", Details: &models.Thread{ID: 3, Title: "treasure"}, Vote: &models.Vote{Nickname: "jack", Voice: -1}}
+	payloads := formatNotifications([]models.Event{event, vote})
+	if len(payloads) != 1 {
+		t.Fatalf("formatNotifications = %q, want one notification", payloads)
+	}
+	parsed, err := parseNotification(payloads[0])
+	if err != nil || len(parsed) != 2 {
+		t.Fatalf("parseNotification(%q) = %+v, %v", payloads[0], parsed, err)
+	}
+	if edit := parsed[0]; edit.Type != event.Type || edit.Forum != "pirates" || edit.Thread != 3 || edit.User != "jack" || edit.Post.ID != 42 || edit.Post.Message != "" {
+		t.Errorf("edit notification parsed as %+v, want the post cut down to its id", edit)
+	}
+	if vote := parsed[1]; vote.Details == nil || vote.Details.ID != 3 || vote.Details.Title != "" || vote.Vote == nil || vote.Vote.Voice != -1 {
+		t.Errorf("vote notification parsed as %+v", vote)
+	}
+
+	for _, payload := range []string{"", "[]", "post:3:42", `[{"type":"post","thread":3}]`, `[{"type":"like","thread":3,"post":{"id":42}}]`} {
+		if _, err := parseNotification(payload); err == nil {
+			t.Errorf("parseNotification(%q) succeeded", payload)
+		}
+	}
+}
+
+func TestNotificationBatches(t *testing.T) {
+	// A large batch of posts is split so no notification exceeds the limit.
+	batch := make([]models.Event, 500)
+	for i := range batch {
+		batch[i] = models.Event{Type: models.EventPost, Forum: strings.Repeat("f", 20), Thread: 3, User: "jack", Post: &models.Post{ID: i + 1}}
+	}
+	payloads := formatNotifications(batch)
+	if len(payloads) < 2 {
+		t.Fatalf("formatNotifications of %d events = %d notifications, want them split", len(batch), len(payloads))
+	}
+	next := 1
+	for _, payload := range payloads {
+		if len(payload) > maxNotification {
+			t.Errorf("notification of %d bytes, want at most %d", len(payload), maxNotification)
+		}
+		events, err := parseNotification(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			if event.Post.ID != next {
+				t.Fatalf("event of post %d, want %d: events must keep their order", event.Post.ID, next)
+			}
+			next++
+		}
+	}
+	if next != len(batch)+1 {
+		t.Errorf("notifications carry %d events, want %d", next-1, len(batch))
+	}
+}
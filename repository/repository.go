@@ -5,8 +5,7 @@ import (
 	"context"
 	"github.com/emirpasic/gods/sets/treeset"
 	"github.com/jackc/pgx"
-	"log"
-	"net/http"
+	"github.com/rs/zerolog"
 	"strconv"
 	"strings"
 	"technopark-forum/models"
@@ -14,17 +13,19 @@ import (
 )
 
 type Storage struct {
-	db *pgx.ConnPool
+	db     *pgx.ConnPool
+	log    zerolog.Logger
+	outbox webhookOutbox
 }
 
-func NewForumStorage(db *pgx.ConnPool) *Storage {
-	return &Storage{db: db}
+func NewForumStorage(db *pgx.ConnPool, log zerolog.Logger) *Storage {
+	return &Storage{db: db, log: log}
 }
 
 // service
 
-func (storage *Storage) GetStatus() (*models.Status, error) {
-	tx, err := storage.db.Begin()
+func (storage *Storage) GetStatus(ctx context.Context) (*models.Status, error) {
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +34,7 @@ func (storage *Storage) GetStatus() (*models.Status, error) {
 	}(tx)
 
 	status := new(models.Status)
-	err = tx.QueryRow("SELECT (SELECT count(*) FROM forums) as forums, (SELECT count(*) FROM posts) as posts, (SELECT count(*) FROM users) as users, (SELECT count(*) FROM threads) as threads").Scan(&status.Forum, &status.Post, &status.User, &status.Thread)
+	err = tx.QueryRowEx(ctx, statement("GetStatus.query", "SELECT (SELECT count(*) FROM forums) as forums, (SELECT count(*) FROM posts) as posts, (SELECT count(*) FROM users) as users, (SELECT count(*) FROM threads) as threads"), nil).Scan(&status.Forum, &status.Post, &status.User, &status.Thread)
 	if err != nil {
 		return nil, err
 	}
@@ -41,29 +42,56 @@ func (storage *Storage) GetStatus() (*models.Status, error) {
 	return status, nil
 }
 
-func (storage *Storage) Clear() error {
-	tx, err := storage.db.Begin()
+func (storage *Storage) EstimateStatus(ctx context.Context) (*models.Status, error) {
+	query := statement("EstimateStatus.query", `SELECT relname::TEXT, greatest(reltuples, 0)::BIGINT FROM pg_class
+WHERE relkind = 'r' AND relnamespace = 'public'::regnamespace AND relname IN ('forums', 'posts', 'users', 'threads')`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, "status", err)
+	}
+	defer rows.Close()
+
+	status := new(models.Status)
+	counts := map[string]*int{"forums": &status.Forum, "posts": &status.Post, "users": &status.User, "threads": &status.Thread}
+	for rows.Next() {
+		var table string
+		var count int64
+		if err = rows.Scan(&table, &count); err != nil {
+			return nil, storage.internal(ctx, models.EntityForum, "status", err)
+		}
+		*counts[table] = int(count)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, "status", err)
+	}
+
+	return status, nil
+}
+
+func (storage *Storage) Clear(ctx context.Context) error {
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func(tx *pgx.Tx) {
-		_ = tx.Commit()
+		_ = tx.Rollback()
 	}(tx)
 
-	_, err = tx.Exec("TRUNCATE forum_users, posts, threads, forums, users RESTART IDENTITY CASCADE")
+	_, err = tx.ExecEx(ctx, statement("Clear.query", "TRUNCATE webhook_deliveries, webhooks, auth_tokens, forum_moderators, post_votes, post_revisions, thread_revisions, forum_users, posts, threads, forums, users RESTART IDENTITY CASCADE"), nil)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.CommitEx(ctx)
 }
 
 // user
 
-func (storage *Storage) CreateUser(user *models.User) (*models.Users, error) {
-	queryInsert := `INSERT INTO users (email, nickname, fullname, about) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`
+func (storage *Storage) CreateUser(ctx context.Context, user *models.User) (*models.Users, error) {
+	queryInsert := statement("CreateUser.queryInsert", `INSERT INTO users (email, nickname, fullname, about, password_hash) VALUES ($1, $2, $3, $4, NULLIF($5, '')) ON CONFLICT DO NOTHING`)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -71,13 +99,13 @@ func (storage *Storage) CreateUser(user *models.User) (*models.Users, error) {
 		_ = tx.Rollback()
 	}(tx)
 
-	response, err := tx.Exec(queryInsert, user.Email, user.Nickname, user.Fullname, user.About)
+	response, err := tx.ExecEx(ctx, queryInsert, nil, user.Email, user.Nickname, user.Fullname, user.About, user.PasswordHash)
 	if err != nil {
 		return nil, err
 	}
 	if response.RowsAffected() == 0 {
-		querySelect := `SELECT email::TEXT, nickname::TEXT, fullname, about FROM users WHERE email=$1 OR nickname=$2`
-		rows, err := tx.Query(querySelect, user.Email, user.Nickname)
+		querySelect := statement("CreateUser.querySelect", `SELECT email::TEXT, nickname::TEXT, fullname, about FROM users WHERE email=$1 OR nickname=$2`)
+		rows, err := tx.QueryEx(ctx, querySelect, nil, user.Email, user.Nickname)
 		if err != nil {
 			return nil, err
 		}
@@ -96,28 +124,34 @@ func (storage *Storage) CreateUser(user *models.User) (*models.Users, error) {
 		_ = tx.Rollback()
 		return &users, nil
 	}
+	if err = storage.enqueue(ctx, tx, userCreated(user)); err != nil {
+		return nil, err
+	}
 
-	_ = tx.Commit()
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityUser, user.Nickname, err)
+	}
 	return nil, nil
 }
 
-func (storage *Storage) GetUserProfile(nickname string) (*models.User, error) {
-	query := `SELECT nickname::TEXT, email::TEXT, fullname, about FROM users WHERE nickname = $1`
+func (storage *Storage) GetUserProfile(ctx context.Context, nickname string) (*models.User, error) {
+	query := statement("GetUserProfile.query", `SELECT nickname::TEXT, email::TEXT, fullname, about FROM users WHERE nickname = $1`)
 
 	user := new(models.User)
 	user.Nickname = nickname
-	err := storage.db.QueryRow(query, nickname).Scan(&user.Nickname, &user.Email, &user.Fullname, &user.About)
+	err := storage.db.QueryRowEx(ctx, query, nil, nickname).Scan(&user.Nickname, &user.Email, &user.Fullname, &user.About)
 	if err != nil {
-		return nil, models.UserNotFound(nickname)
+		return nil, notFoundOr(err, models.EntityUser, nickname)
 	}
 
 	return user, nil
 }
 
-func (storage *Storage) UpdateUserProfile(oldUser *models.User) (*models.User, error) {
-	query := `UPDATE users SET ` +
-		`email = COALESCE($1, users.email), fullname = COALESCE($2, users.fullname), about = COALESCE($3, users.about) ` +
-		`WHERE nickname=$4 RETURNING email::TEXT, nickname::TEXT, fullname, about`
+func (storage *Storage) UpdateUserProfile(ctx context.Context, oldUser *models.User) (*models.User, error) {
+	query := statement("UpdateUserProfile.query", `UPDATE users SET `+
+		`email = COALESCE($1, users.email), fullname = COALESCE($2, users.fullname), about = COALESCE($3, users.about), `+
+		`password_hash = COALESCE(NULLIF($5, ''), users.password_hash) `+
+		`WHERE nickname=$4 RETURNING email::TEXT, nickname::TEXT, fullname, about`)
 
 	var (
 		newEmail    *string = nil
@@ -135,24 +169,186 @@ func (storage *Storage) UpdateUserProfile(oldUser *models.User) (*models.User, e
 	}
 
 	newUser := new(models.User)
-	err := storage.db.QueryRow(query, &newEmail, &newFullname, &newAbout, oldUser.Nickname).
+	err := storage.db.QueryRowEx(ctx, query, nil, &newEmail, &newFullname, &newAbout, oldUser.Nickname, oldUser.PasswordHash).
 		Scan(&newUser.Email, &newUser.Nickname, &newUser.Fullname, &newUser.About)
 	if err != nil {
-		if _, ok := err.(pgx.PgError); ok {
-			return nil, models.UsersProfileConflict(oldUser.Nickname)
+		if pgErrorCode(err) == pgUniqueViolation {
+			return nil, models.Conflict(models.EntityUser, oldUser.Nickname, "email is already taken")
 		}
-		return nil, models.UserNotFound(oldUser.Nickname)
+		return nil, notFoundOr(err, models.EntityUser, oldUser.Nickname)
 	}
 
 	return newUser, nil
 }
 
+func (storage *Storage) GetReputation(ctx context.Context, nickname string) (int, error) {
+	query := statement("GetReputation.query", `SELECT (SELECT coalesce(sum(votes), 0) FROM threads WHERE author = $1 AND votes <> 0 AND state <> 'deleted')
+     + (SELECT coalesce(sum(p.votes), 0) FROM posts p JOIN threads t ON t.id = p.thread
+        WHERE p.author = $1::TEXT AND p.votes <> 0 AND p.deleted_at IS NULL AND t.state <> 'deleted')`)
+
+	var reputation int
+	if err := storage.db.QueryRowEx(ctx, query, nil, nickname).Scan(&reputation); err != nil {
+		return 0, storage.internal(ctx, models.EntityUser, nickname, err)
+	}
+	return reputation, nil
+}
+
+// auth
+
+func (storage *Storage) GetPasswordHash(ctx context.Context, nickname string) (string, string, error) {
+	query := statement("GetPasswordHash.query", `SELECT nickname::TEXT, COALESCE(password_hash, '') FROM users WHERE nickname = $1`)
+
+	var canonical, hash string
+	err := storage.db.QueryRowEx(ctx, query, nil, nickname).Scan(&canonical, &hash)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", "", models.NotFound(models.EntityUser, nickname)
+		}
+		return "", "", storage.internal(ctx, models.EntityUser, nickname, err)
+	}
+
+	return canonical, hash, nil
+}
+
+func (storage *Storage) CreateSession(ctx context.Context, tokenHash string, session *models.Session) error {
+	queryPrune := statement("CreateSession.queryPrune", `DELETE FROM auth_tokens WHERE nickname = $1 AND expires_at <= now()`)
+	queryInsert := statement("CreateSession.queryInsert", `INSERT INTO auth_tokens (token_hash, nickname, expires_at) VALUES ($1, $2, $3)`)
+
+	tx, err := storage.db.BeginEx(ctx, nil)
+	if err != nil {
+		return storage.internal(ctx, models.EntitySession, session.Nickname, err)
+	}
+	defer func(tx *pgx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if _, err = tx.ExecEx(ctx, queryPrune, nil, session.Nickname); err != nil {
+		return storage.internal(ctx, models.EntitySession, session.Nickname, err)
+	}
+	if _, err = tx.ExecEx(ctx, queryInsert, nil, tokenHash, session.Nickname, session.Expires); err != nil {
+		if pgErrorCode(err) == pgForeignKeyViolation {
+			return models.NotFound(models.EntityUser, session.Nickname)
+		}
+		return storage.internal(ctx, models.EntitySession, session.Nickname, err)
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return storage.internal(ctx, models.EntitySession, session.Nickname, err)
+	}
+	return nil
+}
+
+func (storage *Storage) GetSession(ctx context.Context, tokenHash string) (*models.Session, error) {
+	query := statement("GetSession.query", `SELECT nickname::TEXT, expires_at FROM auth_tokens WHERE token_hash = $1 AND expires_at > now()`)
+
+	session := new(models.Session)
+	err := storage.db.QueryRowEx(ctx, query, nil, tokenHash).Scan(&session.Nickname, &session.Expires)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, models.NotFound(models.EntitySession, "token")
+		}
+		return nil, storage.internal(ctx, models.EntitySession, "token", err)
+	}
+
+	return session, nil
+}
+
+func (storage *Storage) DeleteSession(ctx context.Context, tokenHash string) error {
+	query := statement("DeleteSession.query", `DELETE FROM auth_tokens WHERE token_hash = $1`)
+
+	if _, err := storage.db.ExecEx(ctx, query, nil, tokenHash); err != nil {
+		return storage.internal(ctx, models.EntitySession, "token", err)
+	}
+	return nil
+}
+
+// roles
+
+func (storage *Storage) GetUserRole(ctx context.Context, nickname string) (string, error) {
+	query := statement("GetUserRole.query", `SELECT role FROM users WHERE nickname = $1`)
+
+	var role string
+	if err := storage.db.QueryRowEx(ctx, query, nil, nickname).Scan(&role); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", models.NotFound(models.EntityUser, nickname)
+		}
+		return "", storage.internal(ctx, models.EntityUser, nickname, err)
+	}
+	return role, nil
+}
+
+func (storage *Storage) SetUserRole(ctx context.Context, nickname string, role string) (*models.UserRole, error) {
+	query := statement("SetUserRole.query", `UPDATE users SET role = $2 WHERE nickname = $1 RETURNING nickname::TEXT, role`)
+
+	userRole := new(models.UserRole)
+	if err := storage.db.QueryRowEx(ctx, query, nil, nickname, role).Scan(&userRole.Nickname, &userRole.Role); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, models.NotFound(models.EntityUser, nickname)
+		}
+		return nil, storage.internal(ctx, models.EntityUser, nickname, err)
+	}
+	return userRole, nil
+}
+
+func (storage *Storage) IsModerator(ctx context.Context, forum string, nickname string) (bool, error) {
+	query := statement("IsModerator.query", `SELECT EXISTS(SELECT 1 FROM forum_moderators WHERE forum = $1 AND nickname = $2)`)
+
+	var moderator bool
+	if err := storage.db.QueryRowEx(ctx, query, nil, forum, nickname).Scan(&moderator); err != nil {
+		return false, storage.internal(ctx, models.EntityForum, forum, err)
+	}
+	return moderator, nil
+}
+
+func (storage *Storage) AddModerator(ctx context.Context, forum string, nickname string) error {
+	query := statement("AddModerator.query", `INSERT INTO forum_moderators (forum, nickname)
+SELECT f.slug, u.nickname FROM forums f, users u WHERE f.slug = $1 AND u.nickname = $2 ON CONFLICT DO NOTHING`)
+
+	if _, err := storage.db.ExecEx(ctx, query, nil, forum, nickname); err != nil {
+		return storage.internal(ctx, models.EntityForum, forum, err)
+	}
+	return nil
+}
+
+func (storage *Storage) RemoveModerator(ctx context.Context, forum string, nickname string) error {
+	query := statement("RemoveModerator.query", `DELETE FROM forum_moderators WHERE forum = $1 AND nickname = $2`)
+
+	if _, err := storage.db.ExecEx(ctx, query, nil, forum, nickname); err != nil {
+		return storage.internal(ctx, models.EntityForum, forum, err)
+	}
+	return nil
+}
+
+func (storage *Storage) GetForumModerators(ctx context.Context, forum string) (*models.Users, error) {
+	query := statement("GetForumModerators.query", `SELECT email::TEXT, u.nickname::TEXT, fullname, about FROM forum_moderators m
+JOIN users u ON m.nickname = u.nickname WHERE m.forum = $1 ORDER BY lower(u.nickname)`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil, forum)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, forum, err)
+	}
+	defer rows.Close()
+
+	users := models.Users{}
+	for rows.Next() {
+		var user models.User
+		if err = rows.Scan(&user.Email, &user.Nickname, &user.Fullname, &user.About); err != nil {
+			return nil, storage.internal(ctx, models.EntityForum, forum, err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, forum, err)
+	}
+	return &users, nil
+}
+
 // forum
 
-func (storage *Storage) CreateForum(forum *models.Forum) error {
-	query := `INSERT INTO forums(title, author, slug) values ($1, (SELECT nickname::TEXT FROM users WHERE nickname=$2), $3);`
+func (storage *Storage) CreateForum(ctx context.Context, forum *models.Forum) error {
+	query := statement("CreateForum.query", `INSERT INTO forums(title, author, slug) values ($1, (SELECT nickname::TEXT FROM users WHERE nickname=$2), $3);`)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -160,35 +356,44 @@ func (storage *Storage) CreateForum(forum *models.Forum) error {
 		_ = tx.Rollback()
 	}(tx)
 
-	_, err = tx.Exec(query, forum.Title, forum.Author, forum.Slug)
+	_, err = tx.ExecEx(ctx, query, nil, forum.Title, forum.Author, forum.Slug)
 	if err != nil {
-		return err
+		switch pgErrorCode(err) {
+		case pgUniqueViolation:
+			return models.Conflict(models.EntityForum, forum.Slug, "")
+		case pgNotNullViolation:
+			return models.NotFound(models.EntityUser, forum.Author)
+		}
+		return storage.internal(ctx, models.EntityForum, forum.Slug, err)
 	}
 
-	_ = tx.Commit()
+	if err = tx.CommitEx(ctx); err != nil {
+		return storage.internal(ctx, models.EntityForum, forum.Slug, err)
+	}
 	return nil
 }
 
-func (storage *Storage) GetForum(slug string) (*models.Forum, error) {
-	query := `SELECT title, slug::TEXT, author::TEXT, posts, threads FROM forums WHERE slug=$1`
+func (storage *Storage) GetForum(ctx context.Context, slug string) (*models.Forum, error) {
+	query := statement("GetForum.query", `SELECT title, slug::TEXT, author::TEXT, posts, threads FROM forums WHERE slug=$1`)
 
 	forum := new(models.Forum)
 
-	err := storage.db.QueryRow(query, slug).
+	err := storage.db.QueryRowEx(ctx, query, nil, slug).
 		Scan(&forum.Title, &forum.Slug, &forum.Author, &forum.Posts, &forum.Threads)
 	if err != nil {
-		return nil, err
+		return nil, notFoundOr(err, models.EntityForum, slug)
 	}
 
-	return forum, err
+	return forum, nil
 }
 
-func (storage *Storage) CreateThread(user *models.User, forum *models.Forum, thread *models.Thread) (*models.Thread, error) {
-	query := `INSERT INTO threads(title, author, forum, message, slug, created_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING RETURNING id`
+func (storage *Storage) CreateThread(ctx context.Context, user *models.User, forum *models.Forum, thread *models.Thread) (*models.Thread, error) {
+	query := statement("CreateThread.query", `INSERT INTO threads(title, author, forum, message, slug, created_at, search)
+VALUES ($1, $2, $3, $4, $5, $6, setweight(to_tsvector('simple', $1), 'A') || to_tsvector('simple', $4)) ON CONFLICT DO NOTHING RETURNING id`)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, thread.Slug, err)
 	}
 	defer func(tx *pgx.Tx) {
 		_ = tx.Rollback()
@@ -201,44 +406,52 @@ func (storage *Storage) CreateThread(user *models.User, forum *models.Forum, thr
 		kostil = &thread.Slug
 	}
 
-	err = tx.QueryRow(query, thread.Title, thread.Author, forum.Slug,
+	err = tx.QueryRowEx(ctx, query, nil, thread.Title, thread.Author, forum.Slug,
 		thread.Message, kostil, thread.Created).
 		Scan(&thread.ID)
 	if err != nil {
 		existingThread := new(models.Thread)
-		queryExists := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads WHERE slug=$1`
+		queryExists := statement("CreateThread.queryExists", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads WHERE slug=$1`)
 
-		if err = tx.QueryRow(queryExists, thread.Slug).
+		if err = tx.QueryRowEx(ctx, queryExists, nil, thread.Slug).
 			Scan(&existingThread.ID, &existingThread.Slug, &existingThread.Title,
 				&existingThread.Message, &existingThread.Forum, &existingThread.Author, &existingThread.Created,
-				&existingThread.Votes); err == nil {
+				&existingThread.Votes, &existingThread.State); err == nil {
 
 			_ = tx.Rollback()
-			return existingThread, models.Conflict
+			return existingThread, models.Conflict(models.EntityThread, thread.Slug, "")
 		}
 
 		_ = tx.Rollback()
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, thread.Slug, err)
 	}
 
-	queryUpdateForumUsers := `INSERT INTO forum_users(nickname, forum) VALUES ($1, $2) ON CONFLICT DO NOTHING`
-	_, err = storage.db.Exec(queryUpdateForumUsers, thread.Author, thread.Forum)
+	queryUpdateForumUsers := statement("CreateThread.queryUpdateForumUsers", `INSERT INTO forum_users(nickname, forum) VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+	_, err = tx.ExecEx(ctx, queryUpdateForumUsers, nil, thread.Author, thread.Forum)
 	if err != nil {
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, thread.Slug, err)
 	}
-	queryUpdateForum := `UPDATE forums SET threads = forums.threads + 1 WHERE slug = $1`
-	_, err = storage.db.Exec(queryUpdateForum, thread.Forum)
+	queryUpdateForum := statement("CreateThread.queryUpdateForum", `UPDATE forums SET threads = forums.threads + 1 WHERE slug = $1`)
+	_, err = tx.ExecEx(ctx, queryUpdateForum, nil, thread.Forum)
 	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, thread.Slug, err)
+	}
+	thread.State = models.ThreadOpen
+	created := *thread
+	created.Forum, created.Author = forum.Slug, user.Nickname
+	if err = storage.enqueue(ctx, tx, threadCreated(&created)); err != nil {
 		return nil, err
 	}
 
-	_ = tx.Commit()
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, thread.Slug, err)
+	}
 	return thread, nil
 }
 
-func (storage *Storage) GetThread(slugOrID interface{}) (*models.Thread, error) {
-	queryBySlug := `SELECT id, title, author::TEXT, forum::TEXT, message, votes, slug::TEXT, created_at FROM threads WHERE slug=$1`
-	queryByID := `SELECT id, title, author::TEXT, forum::TEXT, message, votes, slug::TEXT, created_at FROM threads WHERE id=$1`
+func (storage *Storage) GetThread(ctx context.Context, slugOrID interface{}) (*models.Thread, error) {
+	queryBySlug := statement("GetThread.queryBySlug", `SELECT id, title, author::TEXT, forum::TEXT, message, votes, slug::TEXT, created_at, state FROM threads WHERE slug=$1 AND state <> 'deleted'`)
+	queryByID := statement("GetThread.queryByID", `SELECT id, title, author::TEXT, forum::TEXT, message, votes, slug::TEXT, created_at, state FROM threads WHERE id=$1 AND state <> 'deleted'`)
 
 	thread := new(models.Thread)
 
@@ -247,16 +460,16 @@ func (storage *Storage) GetThread(slugOrID interface{}) (*models.Thread, error)
 	var slug *string = nil
 
 	if err != nil {
-		err = storage.db.QueryRow(queryBySlug, slugOrID).
-			Scan(&thread.ID, &thread.Title, &thread.Author, &thread.Forum, &thread.Message, &thread.Votes, &slug, &thread.Created)
+		err = storage.db.QueryRowEx(ctx, queryBySlug, nil, slugOrID).
+			Scan(&thread.ID, &thread.Title, &thread.Author, &thread.Forum, &thread.Message, &thread.Votes, &slug, &thread.Created, &thread.State)
 		if err != nil {
-			return nil, err
+			return nil, notFoundOr(err, models.EntityThread, slugOrID.(string))
 		}
 	} else {
-		err = storage.db.QueryRow(queryByID, slugOrID).
-			Scan(&thread.ID, &thread.Title, &thread.Author, &thread.Forum, &thread.Message, &thread.Votes, &slug, &thread.Created)
+		err = storage.db.QueryRowEx(ctx, queryByID, nil, slugOrID).
+			Scan(&thread.ID, &thread.Title, &thread.Author, &thread.Forum, &thread.Message, &thread.Votes, &slug, &thread.Created, &thread.State)
 		if err != nil {
-			return nil, err
+			return nil, notFoundOr(err, models.EntityThread, slugOrID.(string))
 		}
 	}
 	if slug == nil {
@@ -268,34 +481,33 @@ func (storage *Storage) GetThread(slugOrID interface{}) (*models.Thread, error)
 	return thread, nil
 }
 
-func (storage *Storage) GetForumUsers(slug interface{}, limit []byte, since []byte, desc []byte) (*models.Users, error) {
-	queryDesc := `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
-JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORDER BY lower(forum_users.nickname) DESC LIMIT $2::TEXT::INTEGER`
-	querySinceDesc := `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
-JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 AND lower(forum_users.nickname) < lower($2) ORDER BY lower(forum_users.nickname) DESC LIMIT $3::TEXT::INTEGER`
-	querySince := `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
-JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 AND lower(forum_users.nickname) > lower($2) ORDER BY lower(forum_users.nickname) LIMIT $3::TEXT::INTEGER`
-	query := `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
-JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORDER BY lower(forum_users.nickname) LIMIT $2::TEXT::INTEGER`
+func (storage *Storage) GetForumUsers(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte) (*models.Users, error) {
+	queryDesc := statement("GetForumUsers.queryDesc", `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
+JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORDER BY lower(forum_users.nickname) DESC LIMIT $2::TEXT::INTEGER`)
+	querySinceDesc := statement("GetForumUsers.querySinceDesc", `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
+JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 AND lower(forum_users.nickname) < lower($2) ORDER BY lower(forum_users.nickname) DESC LIMIT $3::TEXT::INTEGER`)
+	querySince := statement("GetForumUsers.querySince", `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
+JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 AND lower(forum_users.nickname) > lower($2) ORDER BY lower(forum_users.nickname) LIMIT $3::TEXT::INTEGER`)
+	query := statement("GetForumUsers.query", `SELECT email::TEXT, forum_users.nickname::TEXT, fullname, about FROM forum_users 
+JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORDER BY lower(forum_users.nickname) LIMIT $2::TEXT::INTEGER`)
 
 	var err error
 	var rows *pgx.Rows
 	if since == nil {
 		if bytes.Equal([]byte("true"), desc) {
-			rows, err = storage.db.Query(queryDesc, slug, limit)
+			rows, err = storage.db.QueryEx(ctx, queryDesc, nil, slug, limit)
 		} else {
-			rows, err = storage.db.Query(query, slug, limit)
+			rows, err = storage.db.QueryEx(ctx, query, nil, slug, limit)
 		}
 	} else {
 		if bytes.Equal([]byte("true"), desc) {
-			rows, err = storage.db.Query(querySinceDesc, slug, since, limit)
+			rows, err = storage.db.QueryEx(ctx, querySinceDesc, nil, slug, since, limit)
 		} else {
-			rows, err = storage.db.Query(querySince, slug, since, limit)
+			rows, err = storage.db.QueryEx(ctx, querySince, nil, slug, since, limit)
 		}
 	}
 	if err != nil {
-		rows.Close()
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityForum, slug.(string), err)
 	}
 	var users models.Users
 
@@ -303,7 +515,7 @@ JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORD
 		user := new(models.User)
 		if err = rows.Scan(&user.Email, &user.Nickname, &user.Fullname, &user.About); err != nil {
 			rows.Close()
-			return nil, err
+			return nil, storage.internal(ctx, models.EntityForum, slug.(string), err)
 		}
 		users = append(users, *user)
 	}
@@ -313,36 +525,37 @@ JOIN users u on forum_users.nickname = u.nickname WHERE forum_users.forum=$1 ORD
 	return &users, nil
 }
 
-func (storage *Storage) GetForumThreads(slug interface{}, limit []byte, since []byte, desc []byte) (*models.Threads, error) {
-	queryDesc := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads
-WHERE forum = $1 ORDER BY created_at DESC LIMIT $2::TEXT::INTEGER`
-	querySinceDesc := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads
-WHERE forum = $1 AND created_at <= $2::TEXT::TIMESTAMPTZ ORDER BY created_at DESC LIMIT $3::TEXT::INTEGER`
-	querySince := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads
-WHERE forum = $1 AND created_at >= $2::TEXT::TIMESTAMPTZ ORDER BY created_at LIMIT $3::TEXT::INTEGER`
-	query := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads
-WHERE forum = $1 ORDER BY created_at LIMIT $2::TEXT::INTEGER`
+func (storage *Storage) GetForumThreads(ctx context.Context, slug interface{}, limit []byte, since []byte, desc []byte, archived bool) (*models.Threads, error) {
+	queryDesc := statement("GetForumThreads.queryDesc", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads
+WHERE forum = $1 AND state = ANY($3::TEXT[]) ORDER BY created_at DESC LIMIT $2::TEXT::INTEGER`)
+	querySinceDesc := statement("GetForumThreads.querySinceDesc", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads
+WHERE forum = $1 AND created_at <= $2::TEXT::TIMESTAMPTZ AND state = ANY($4::TEXT[]) ORDER BY created_at DESC LIMIT $3::TEXT::INTEGER`)
+	querySince := statement("GetForumThreads.querySince", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads
+WHERE forum = $1 AND created_at >= $2::TEXT::TIMESTAMPTZ AND state = ANY($4::TEXT[]) ORDER BY created_at LIMIT $3::TEXT::INTEGER`)
+	query := statement("GetForumThreads.query", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads
+WHERE forum = $1 AND state = ANY($3::TEXT[]) ORDER BY created_at LIMIT $2::TEXT::INTEGER`)
+
+	states := listedThreadStates(archived)
 
 	var err error
 	var rows *pgx.Rows
 
 	if since == nil {
 		if bytes.Equal([]byte("true"), desc) {
-			rows, err = storage.db.Query(queryDesc, slug, limit)
+			rows, err = storage.db.QueryEx(ctx, queryDesc, nil, slug, limit, states)
 		} else {
-			rows, err = storage.db.Query(query, slug, limit)
+			rows, err = storage.db.QueryEx(ctx, query, nil, slug, limit, states)
 		}
 	} else {
 		if bytes.Equal([]byte("true"), desc) {
-			rows, err = storage.db.Query(querySinceDesc, slug, since, limit)
+			rows, err = storage.db.QueryEx(ctx, querySinceDesc, nil, slug, since, limit, states)
 		} else {
-			rows, err = storage.db.Query(querySince, slug, since, limit)
+			rows, err = storage.db.QueryEx(ctx, querySince, nil, slug, since, limit, states)
 		}
 	}
 
 	if err != nil {
-		rows.Close()
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityForum, slug.(string), err)
 	}
 
 	var slugMoc *string = nil
@@ -350,8 +563,9 @@ WHERE forum = $1 ORDER BY created_at LIMIT $2::TEXT::INTEGER`
 	for rows.Next() {
 		thread := new(models.Thread)
 		if err = rows.Scan(&thread.ID, &slugMoc, &thread.Title, &thread.Message,
-			&thread.Forum, &thread.Author, &thread.Created, &thread.Votes); err != nil {
-			return nil, err
+			&thread.Forum, &thread.Author, &thread.Created, &thread.Votes, &thread.State); err != nil {
+			rows.Close()
+			return nil, storage.internal(ctx, models.EntityForum, slug.(string), err)
 		}
 		if slugMoc == nil {
 			thread.Slug = ""
@@ -368,13 +582,50 @@ WHERE forum = $1 ORDER BY created_at LIMIT $2::TEXT::INTEGER`
 
 // threads
 
-func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (*models.Posts, error) {
-	queryBySlug := `SELECT id, forum::TEXT FROM threads WHERE slug=$1`
-	queryByID := `SELECT id, forum::TEXT FROM threads WHERE id=$1`
+func (storage *Storage) GetForumLeaderboard(ctx context.Context, slug string, limit int) (*models.Users, error) {
+	query := statement("GetForumLeaderboard.query", `WITH earned AS (SELECT author::TEXT AS nickname, votes
+                FROM threads
+                WHERE forum = $1 AND state <> 'deleted'
+                UNION ALL
+                SELECT p.author, p.votes
+                FROM posts p JOIN threads t ON t.id = p.thread
+                WHERE p.forum::CITEXT = $1 AND p.deleted_at IS NULL AND t.state <> 'deleted')
+SELECT u.email::TEXT, u.nickname::TEXT, u.fullname, u.about, sum(e.votes)::INTEGER AS reputation
+FROM earned e JOIN users u ON u.nickname = e.nickname
+GROUP BY u.email, u.nickname, u.fullname, u.about
+ORDER BY reputation DESC, lower(u.nickname)
+LIMIT $2`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil, slug, limit)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, slug, err)
+	}
+	defer rows.Close()
+
+	users := models.Users{}
+	for rows.Next() {
+		var user models.User
+		var reputation int
+		if err = rows.Scan(&user.Email, &user.Nickname, &user.Fullname, &user.About, &reputation); err != nil {
+			return nil, storage.internal(ctx, models.EntityForum, slug, err)
+		}
+		user.Reputation = &reputation
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityForum, slug, err)
+	}
+	return &users, nil
+}
+
+func (storage *Storage) CreatePosts(ctx context.Context, slugOrID interface{}, posts *models.Posts) (*models.Posts, error) {
+	queryBySlug := statement("CreatePosts.queryBySlug", `SELECT id, forum::TEXT, state FROM threads WHERE slug=$1 AND state <> 'deleted'`)
+	queryByID := statement("CreatePosts.queryByID", `SELECT id, forum::TEXT, state FROM threads WHERE id=$1 AND state <> 'deleted'`)
+	threadKey := slugOrID.(string)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 	defer func(tx *pgx.Tx) {
 		_ = tx.Rollback()
@@ -384,28 +635,32 @@ func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (
 	created := time.Unix(0, 0)
 
 	var (
-		forumSlug string
+		forumSlug   string
+		threadState string
 	)
 
 	threadIdentifier, err := strconv.Atoi(slugOrID.(string))
 	if err != nil {
-		if err = tx.QueryRow(queryBySlug, slugOrID).Scan(&threadIdentifier, &forumSlug); err != nil {
-			return nil, models.ThreadNotFound
+		if err = tx.QueryRowEx(ctx, queryBySlug, nil, slugOrID).Scan(&threadIdentifier, &forumSlug, &threadState); err != nil {
+			return nil, models.NotFound(models.EntityThread, threadKey)
 		}
 	} else {
-		if err = tx.QueryRow(queryByID, threadIdentifier).Scan(&threadIdentifier, &forumSlug); err != nil {
-			return nil, models.ThreadNotFound
+		if err = tx.QueryRowEx(ctx, queryByID, nil, threadIdentifier).Scan(&threadIdentifier, &forumSlug, &threadState); err != nil {
+			return nil, models.NotFound(models.EntityThread, threadKey)
 		}
 	}
+	if threadState != models.ThreadOpen {
+		return nil, models.Conflict(models.EntityThread, threadKey, "thread is "+threadState)
+	}
 
 	if len(*posts) == 0 {
 		return nil, nil
 	}
 
-	query := `SELECT array_agg(nextval('posts_id_seq')::BIGINT) FROM generate_series(1, $1)`
+	query := statement("CreatePosts.query", `SELECT array_agg(nextval('posts_id_seq')::BIGINT) FROM generate_series(1, $1)`)
 	ids := make([]int64, 0, len(*posts))
-	if err = tx.QueryRow(query, len(*posts)).Scan(&ids); err != nil {
-		return nil, err
+	if err = tx.QueryRowEx(ctx, query, nil, len(*posts)).Scan(&ids); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 
 	comparator := func(lhs, rhs interface{}) int {
@@ -415,9 +670,9 @@ func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (
 	var postsNeedParents []int
 	authorSet := treeset.NewWith(comparator)
 
-	querySelectParents := `SELECT thread, parents FROM posts WHERE id = $1`
-	if _, err := tx.Prepare("selectParentAndParents", querySelectParents); err != nil {
-		return nil, err
+	querySelectParents := statement("CreatePosts.querySelectParents", `SELECT thread, parents FROM posts WHERE id = $1`)
+	if _, err := tx.PrepareEx(ctx, "selectParentAndParents", querySelectParents, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 	for i, post := range *posts {
 		authorSet.Add(strings.ToLower(post.Author))
@@ -427,9 +682,9 @@ func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (
 		}
 	}
 
-	queryGetProfiles := `SELECT nickname::TEXT, email::TEXT, about, fullname FROM users WHERE nickname = $1`
-	if _, err := tx.Prepare("getUserProfileQuery", queryGetProfiles); err != nil {
-		return nil, err
+	queryGetProfiles := statement("CreatePosts.queryGetProfiles", `SELECT nickname::TEXT, email::TEXT, about, fullname FROM users WHERE nickname = $1`)
+	if _, err := tx.PrepareEx(ctx, "getUserProfileQuery", queryGetProfiles, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 
 	authorOrderedSet := authorSet.Values()
@@ -438,17 +693,17 @@ func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (
 	}
 
 	var parentThreadID int64
-	if err = batch.Send(context.Background(), nil); err != nil {
-		return nil, err
+	if err = batch.Send(ctx, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 
 	for _, postIdx := range postsNeedParents {
 		if err = batch.QueryRowResults().
 			Scan(&parentThreadID, &(*posts)[postIdx].Parents); err != nil {
-			return nil, models.Conflict
+			return nil, models.Conflict(models.EntityPost, strconv.Itoa(int((*posts)[postIdx].Parent)), "parent post was created in another thread")
 		}
 		if parentThreadID != 0 && parentThreadID != int64(threadIdentifier) {
-			return nil, models.Conflict
+			return nil, models.Conflict(models.EntityPost, strconv.Itoa(int((*posts)[postIdx].Parent)), "parent post was created in another thread")
 		}
 	}
 
@@ -459,16 +714,16 @@ func (storage *Storage) CreatePosts(slugOrID interface{}, posts *models.Posts) (
 		user := models.User{}
 		if err = batch.QueryRowResults().
 			Scan(&user.Nickname, &user.Email, &user.About, &user.Fullname); err != nil {
-			return nil, models.UserNotFoundSimple
+			return nil, models.NotFound(models.EntityUser, userNickname.(string))
 		}
 		userModelsOrderedSet = append(userModelsOrderedSet, user)
 		authorRealNicknameMap[userNickname.(string)] = user.Nickname
 	}
 
-	queryInsertPost := `INSERT INTO posts(id, author, message, created_at, forum, thread, parent, parents, main_parent)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at`
-	if _, err := tx.Prepare("insertIntoPost", queryInsertPost); err != nil {
-		return nil, err
+	queryInsertPost := statement("CreatePosts.queryInsertPost", `INSERT INTO posts(id, author, message, created_at, forum, thread, parent, parents, main_parent, search)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, to_tsvector('simple', $3)) RETURNING created_at`)
+	if _, err := tx.PrepareEx(ctx, "insertIntoPost", queryInsertPost, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 	currentPosts := new(models.Posts)
 	for index, post := range *posts {
@@ -482,102 +737,205 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at`
 		*currentPosts = append(*currentPosts, post)
 	}
 
-	queryInsertForumUser := `INSERT INTO forum_users(forum, nickname) VALUES ($1, $2) ON CONFLICT DO NOTHING`
-	if _, err := tx.Prepare("insertIntoForumUsers", queryInsertForumUser); err != nil {
-		return nil, err
+	queryInsertForumUser := statement("CreatePosts.queryInsertForumUser", `INSERT INTO forum_users(forum, nickname) VALUES ($1, $2) ON CONFLICT DO NOTHING`)
+	if _, err := tx.PrepareEx(ctx, "insertIntoForumUsers", queryInsertForumUser, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 	for _, user := range userModelsOrderedSet {
 		batch.Queue("insertIntoForumUsers", []interface{}{forumSlug, user.Nickname}, nil, nil)
 	}
-	if err = batch.Send(context.Background(), nil); err != nil {
-		return nil, err
+	if err = batch.Send(ctx, nil); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
 
 	for range *posts {
 		if _, err := batch.ExecResults(); err != nil {
-			return nil, err
+			return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 		}
 	}
 
 	for range userModelsOrderedSet {
 		if _, err := batch.ExecResults(); err != nil {
-			return nil, err
+			return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 		}
 	}
 
-	queryUpdate := `UPDATE forums SET posts=posts+$2 WHERE slug=$1`
-	_, err = tx.Exec(queryUpdate, forumSlug, len(*posts))
+	queryUpdate := statement("CreatePosts.queryUpdate", `UPDATE forums SET posts=posts+$2 WHERE slug=$1`)
+	_, err = tx.ExecEx(ctx, queryUpdate, nil, forumSlug, len(*posts))
 	if err != nil {
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
 	}
-
-	if err = tx.Commit(); err != nil {
+	if err = storage.enqueue(ctx, tx, postsCreated(*currentPosts)...); err != nil {
 		return nil, err
 	}
 
-	_ = tx.Commit()
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, threadKey, err)
+	}
 	return currentPosts, nil
 }
 
-func (storage *Storage) UpdateThread(threadID int, threadUpdate *models.ThreadUpdate) (*models.Thread, error) {
-	query := `UPDATE threads SET message = coalesce($1, message), title = coalesce($2,title) WHERE id = $3 
-RETURNING  id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes`
+func (storage *Storage) UpdateThread(ctx context.Context, threadID int, threadUpdate *models.ThreadUpdate) (*models.Thread, error) {
+	query := statement("UpdateThread.query", `UPDATE threads SET message = coalesce($1, message), title = coalesce($2,title),
+search = setweight(to_tsvector('simple', coalesce($2, title)), 'A') || to_tsvector('simple', coalesce($1, message)) WHERE id = $3 
+RETURNING  id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state`)
+	queryRevision := statement("UpdateThread.queryRevision", `INSERT INTO thread_revisions (thread, editor, title, message)
+SELECT id, NULLIF($2, ''), title, message FROM threads WHERE id = $1 AND (title <> coalesce($3, title) OR message <> coalesce($4, message)) FOR UPDATE`)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
 	}
 	defer func(tx *pgx.Tx) {
-		_ = tx.Commit()
+		_ = tx.Rollback()
 	}(tx)
 
+	if _, err = tx.ExecEx(ctx, queryRevision, nil, threadID, threadUpdate.Editor, threadUpdate.Title, threadUpdate.Message); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
+	}
+
 	thread := new(models.Thread)
 
-	if err = tx.QueryRow(query, threadUpdate.Message, threadUpdate.Title, threadID).
-		Scan(&thread.ID, &thread.Slug, &thread.Title, &thread.Message, &thread.Forum,
-			&thread.Author, &thread.Created, &thread.Votes); err != nil {
-		return nil, err
+	var slug *string
+	if err = tx.QueryRowEx(ctx, query, nil, threadUpdate.Message, threadUpdate.Title, threadID).
+		Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum,
+			&thread.Author, &thread.Created, &thread.Votes, &thread.State); err != nil {
+		return nil, notFoundOr(err, models.EntityThread, strconv.Itoa(threadID))
+	}
+	if slug != nil {
+		thread.Slug = *slug
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
+	}
+	return thread, nil
+}
+
+// GetThreadRevisions returns the titles and messages edits of the thread
+// replaced, oldest first.
+func (storage *Storage) GetThreadRevisions(ctx context.Context, threadID int) (*models.Revisions, error) {
+	query := statement("GetThreadRevisions.query", `SELECT coalesce(editor::TEXT, ''), edited_at, title, message FROM thread_revisions WHERE thread = $1 ORDER BY id`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil, threadID)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
+	}
+	defer rows.Close()
+
+	revisions := models.Revisions{}
+	for rows.Next() {
+		var revision models.Revision
+		if err = rows.Scan(&revision.Editor, &revision.Created, &revision.Title, &revision.Message); err != nil {
+			return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(threadID), err)
+	}
+	return &revisions, nil
+}
+
+func (storage *Storage) GetThreadEdits(ctx context.Context, threadIDs []int) (map[int]time.Time, error) {
+	query := statement("GetThreadEdits.query", `SELECT thread, max(edited_at) FROM thread_revisions WHERE thread = ANY($1::INTEGER[]) GROUP BY thread`)
+
+	return storage.edits(ctx, query, models.EntityThread, threadIDs)
+}
+
+func (storage *Storage) SetThreadState(ctx context.Context, slugOrID string, from []string, to string) (*models.Thread, error) {
+	queryBySlug := statement("SetThreadState.queryBySlug", `SELECT id, forum::TEXT, state FROM threads WHERE slug=$1 FOR UPDATE`)
+	queryByID := statement("SetThreadState.queryByID", `SELECT id, forum::TEXT, state FROM threads WHERE id=$1 FOR UPDATE`)
+	queryUpdate := statement("SetThreadState.queryUpdate", `UPDATE threads SET state = $2 WHERE id = $1
+RETURNING id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state`)
+	queryCounters := statement("SetThreadState.queryCounters", `UPDATE forums
+SET threads = forums.threads + $2,
+    posts   = forums.posts + $2 * (SELECT count(*) FROM posts WHERE thread = $3 AND deleted_at IS NULL)
+WHERE slug = $1`)
+
+	tx, err := storage.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, slugOrID, err)
+	}
+	defer func(tx *pgx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var id int
+	var forum, state string
+	if _, err = strconv.Atoi(slugOrID); err != nil {
+		err = tx.QueryRowEx(ctx, queryBySlug, nil, slugOrID).Scan(&id, &forum, &state)
+	} else {
+		err = tx.QueryRowEx(ctx, queryByID, nil, slugOrID).Scan(&id, &forum, &state)
+	}
+	if err != nil {
+		return nil, notFoundOr(err, models.EntityThread, slugOrID)
+	}
+	if state != to && !containsState(from, state) {
+		return nil, models.Conflict(models.EntityThread, slugOrID, "thread is "+state)
+	}
+
+	thread := new(models.Thread)
+	var slug *string
+	if err = tx.QueryRowEx(ctx, queryUpdate, nil, id, to).
+		Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum,
+			&thread.Author, &thread.Created, &thread.Votes, &thread.State); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, slugOrID, err)
+	}
+	if slug != nil {
+		thread.Slug = *slug
+	}
+
+	// Deleted threads and their posts are not counted by the forum.
+	if delta := deletedDelta(state, to); delta != 0 {
+		if _, err = tx.ExecEx(ctx, queryCounters, nil, forum, delta, id); err != nil {
+			return nil, storage.internal(ctx, models.EntityThread, slugOrID, err)
+		}
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, slugOrID, err)
 	}
 	return thread, nil
 }
 
-func (storage *Storage) GetThreadPosts(slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, int) {
-	queryByID := `SELECT id FROM threads WHERE id=$1`
-	queryBySlug := `SELECT id FROM threads WHERE slug=$1`
+func (storage *Storage) GetThreadPosts(ctx context.Context, slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, error) {
+	queryByID := statement("GetThreadPosts.queryByID", `SELECT id FROM threads WHERE id=$1 AND state <> 'deleted'`)
+	queryBySlug := statement("GetThreadPosts.queryBySlug", `SELECT id FROM threads WHERE slug=$1 AND state <> 'deleted'`)
 
 	var ID int
 	if _, err := strconv.Atoi(*slugOrID); err != nil {
-		if err = storage.db.QueryRow(queryBySlug, slugOrID).Scan(&ID); err != nil {
-			return nil, http.StatusNotFound
+		if err = storage.db.QueryRowEx(ctx, queryBySlug, nil, slugOrID).Scan(&ID); err != nil {
+			return nil, notFoundOr(err, models.EntityThread, *slugOrID)
 		}
 	} else {
-		if err = storage.db.QueryRow(queryByID, slugOrID).Scan(&ID); err != nil {
-			return nil, http.StatusNotFound
+		if err = storage.db.QueryRowEx(ctx, queryByID, nil, slugOrID).Scan(&ID); err != nil {
+			return nil, notFoundOr(err, models.EntityThread, *slugOrID)
 		}
 	}
 
 	switch true {
 	case bytes.Equal([]byte("tree"), sort):
-		postsTree, status := getThreadPostsTree(storage, ID, limit, since, desc)
-		return postsTree, status
+		postsTree, err := getThreadPostsTree(ctx, storage, ID, limit, since, desc)
+		return postsTree, err
 	case bytes.Equal([]byte("parent_tree"), sort):
-		postsParentTree, status := getThreadPostsParentTree(storage, ID, limit, since, desc)
-		return postsParentTree, status
+		postsParentTree, err := getThreadPostsParentTree(ctx, storage, ID, limit, since, desc)
+		return postsParentTree, err
 	default:
-		PostsFlat, status := getThreadPostsFlat(storage, ID, limit, since, desc)
-		return PostsFlat, status
+		PostsFlat, err := getThreadPostsFlat(ctx, storage, ID, limit, since, desc)
+		return PostsFlat, err
 	}
 }
 
-func getThreadPostsTree(storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, int) {
-	getPostsTreeSinceLimitDesc := `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent FROM posts
-WHERE thread = $1 AND parents < (SELECT parents FROM posts WHERE id = $3::TEXT::INTEGER) ORDER BY parents DESC LIMIT $2::TEXT::BIGINT`
-	getPostsTreeSinceLimit := `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent
-FROM posts WHERE thread = $1 AND parents > (SELECT parents FROM posts WHERE id = $3::TEXT::INTEGER) ORDER BY parents LIMIT $2::TEXT::BIGINT`
-	getPostsTreeLimitDesc := `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent FROM posts
-WHERE thread = $1 ORDER BY parents DESC LIMIT $2::TEXT::BIGINT`
-	getPostsTreeLimit := `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent FROM posts
-WHERE thread = $1 ORDER BY parents LIMIT $2::TEXT::BIGINT`
+func getThreadPostsTree(ctx context.Context, storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, error) {
+	getPostsTreeSinceLimitDesc := statement("getThreadPostsTree.getPostsTreeSinceLimitDesc", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes FROM posts
+WHERE thread = $1 AND parents < (SELECT parents FROM posts WHERE id = $3::TEXT::INTEGER) ORDER BY parents DESC LIMIT $2::TEXT::BIGINT`)
+	getPostsTreeSinceLimit := statement("getThreadPostsTree.getPostsTreeSinceLimit", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes
+FROM posts WHERE thread = $1 AND parents > (SELECT parents FROM posts WHERE id = $3::TEXT::INTEGER) ORDER BY parents LIMIT $2::TEXT::BIGINT`)
+	getPostsTreeLimitDesc := statement("getThreadPostsTree.getPostsTreeLimitDesc", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes FROM posts
+WHERE thread = $1 ORDER BY parents DESC LIMIT $2::TEXT::BIGINT`)
+	getPostsTreeLimit := statement("getThreadPostsTree.getPostsTreeLimit", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes FROM posts
+WHERE thread = $1 ORDER BY parents LIMIT $2::TEXT::BIGINT`)
 
 	var (
 		err  error
@@ -586,35 +944,35 @@ WHERE thread = $1 ORDER BY parents LIMIT $2::TEXT::BIGINT`
 	if since != nil {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsTreeSinceLimitDesc, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeSinceLimitDesc, nil, ID, limit, since)
 			} else {
-				rows, err = storage.db.Query(getPostsTreeSinceLimit, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeSinceLimit, nil, ID, limit, since)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsTreeSinceLimitDesc, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeSinceLimitDesc, nil, ID, nil, since)
 			} else {
-				rows, err = storage.db.Query(getPostsTreeSinceLimit, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeSinceLimit, nil, ID, nil, since)
 			}
 		}
 	} else {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsTreeLimitDesc, ID, limit)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeLimitDesc, nil, ID, limit)
 			} else {
-				rows, err = storage.db.Query(getPostsTreeLimit, ID, limit)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeLimit, nil, ID, limit)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsTreeLimitDesc, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeLimitDesc, nil, ID, nil)
 			} else {
-				rows, err = storage.db.Query(getPostsTreeLimit, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsTreeLimit, nil, ID, nil)
 			}
 		}
 	}
 
 	if err != nil {
-		return nil, http.StatusInternalServerError
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 	}
 
 	var posts models.Posts
@@ -624,25 +982,28 @@ WHERE thread = $1 ORDER BY parents LIMIT $2::TEXT::BIGINT`
 
 		if err = rows.Scan(&post.ID, &post.Author, &post.Message,
 			&post.Created, &post.Forum, &post.Thread,
-			&post.IsEdited, &post.Parent); err != nil {
-			return nil, http.StatusInternalServerError
+			&post.IsEdited, &post.Parent, &post.IsDeleted, &post.Votes); err != nil {
+			rows.Close()
+			return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 		}
+		post.Tombstone()
 		posts = append(posts, *post)
 	}
 	rows.Close()
 
-	return &posts, http.StatusOK
+	return &posts, nil
 }
 
-func getThreadPostsParentTree(storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, int) {
-	getPostsParentTreeSinceLimitDesc := `SELECT p.id,
+func getThreadPostsParentTree(ctx context.Context, storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, error) {
+	getPostsParentTreeSinceLimitDesc := statement("getThreadPostsParentTree.getPostsParentTreeSinceLimitDesc", `SELECT p.id,
 	p.author::TEXT,
 	p.message,
 	p.created_at,
 	p.forum::TEXT,
 	p.thread,
 	p.is_edited,
-	p.parent
+	p.parent,
+	p.deleted_at IS NOT NULL, p.votes
 FROM posts p
 JOIN (
 	SELECT id
@@ -655,15 +1016,16 @@ JOIN (
 	ORDER BY id DESC
 	LIMIT $2::TEXT::INTEGER) s
 ON p.main_parent=s.id
-ORDER BY p.parents[1] DESC, p.parents[2:]`
-	getPostsParentTreeSinceLimit := `SELECT p.id,
+ORDER BY p.parents[1] DESC, p.parents[2:]`)
+	getPostsParentTreeSinceLimit := statement("getThreadPostsParentTree.getPostsParentTreeSinceLimit", `SELECT p.id,
 	p.author::TEXT,
 	p.message,
 	p.created_at,
 	p.forum::TEXT,
 	p.thread,
 	p.is_edited,
-	p.parent
+	p.parent,
+	p.deleted_at IS NOT NULL, p.votes
 FROM posts p
 JOIN (
 	SELECT id
@@ -676,15 +1038,16 @@ JOIN (
 	ORDER BY id
 	LIMIT $2::TEXT::INTEGER) s
 ON p.main_parent=s.id
-ORDER BY p.parents`
-	getPostsParentTreeLimitDesc := `SELECT p.id,
+ORDER BY p.parents`)
+	getPostsParentTreeLimitDesc := statement("getThreadPostsParentTree.getPostsParentTreeLimitDesc", `SELECT p.id,
 	p.author::TEXT,
 	p.message,
 	p.created_at,
 	p.forum::TEXT,
 	p.thread,
 	p.is_edited,
-	p.parent
+	p.parent,
+	p.deleted_at IS NOT NULL, p.votes
 FROM posts p
 JOIN (
 	SELECT id
@@ -693,15 +1056,16 @@ JOIN (
 	ORDER BY id DESC
 	LIMIT $2::TEXT::INTEGER) s
 ON p.main_parent=s.id
-ORDER BY p.parents[1] DESC, p.parents[2:]`
-	getPostsParentTreeLimit := `SELECT p.id,
+ORDER BY p.parents[1] DESC, p.parents[2:]`)
+	getPostsParentTreeLimit := statement("getThreadPostsParentTree.getPostsParentTreeLimit", `SELECT p.id,
 	p.author::TEXT,
 	p.message,
 	p.created_at,
 	p.forum::TEXT,
 	p.thread,
 	p.is_edited,
-	p.parent
+	p.parent,
+	p.deleted_at IS NOT NULL, p.votes
 FROM posts p
 JOIN (
 	SELECT id
@@ -710,7 +1074,7 @@ JOIN (
 	ORDER BY id
 	LIMIT $2::TEXT::INTEGER) s
 ON p.main_parent=s.id
-ORDER BY p.parents`
+ORDER BY p.parents`)
 	var (
 		err  error
 		rows *pgx.Rows
@@ -719,35 +1083,35 @@ ORDER BY p.parents`
 	if since != nil {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsParentTreeSinceLimitDesc, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeSinceLimitDesc, nil, ID, limit, since)
 			} else {
-				rows, err = storage.db.Query(getPostsParentTreeSinceLimit, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeSinceLimit, nil, ID, limit, since)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsParentTreeSinceLimitDesc, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeSinceLimitDesc, nil, ID, nil, since)
 			} else {
-				rows, err = storage.db.Query(getPostsParentTreeSinceLimit, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeSinceLimit, nil, ID, nil, since)
 			}
 		}
 	} else {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				/*men*/ rows, err = storage.db.Query(getPostsParentTreeLimitDesc, ID, limit)
+				/*men*/ rows, err = storage.db.QueryEx(ctx, getPostsParentTreeLimitDesc, nil, ID, limit)
 			} else {
-				rows, err = storage.db.Query(getPostsParentTreeLimit, ID, limit)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeLimit, nil, ID, limit)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsParentTreeLimitDesc, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeLimitDesc, nil, ID, nil)
 			} else {
-				rows, err = storage.db.Query(getPostsParentTreeLimit, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsParentTreeLimit, nil, ID, nil)
 			}
 		}
 	}
 
 	if err != nil {
-		return nil, http.StatusInternalServerError
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 	}
 
 	var posts models.Posts
@@ -757,67 +1121,73 @@ ORDER BY p.parents`
 
 		if err = rows.Scan(&post.ID, &post.Author, &post.Message,
 			&post.Created, &post.Forum, &post.Thread,
-			&post.IsEdited, &post.Parent); err != nil {
-			return nil, http.StatusInternalServerError
+			&post.IsEdited, &post.Parent, &post.IsDeleted, &post.Votes); err != nil {
+			rows.Close()
+			return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 		}
+		post.Tombstone()
 		posts = append(posts, *post)
 	}
 	rows.Close()
 
-	return &posts, http.StatusOK
+	return &posts, nil
 }
 
-func getThreadPostsFlat(storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, int) {
-	getPostsFlatSinceLimitDesc := `SELECT id,
+func getThreadPostsFlat(ctx context.Context, storage *Storage, ID int, limit []byte, since []byte, desc []byte) (*models.Posts, error) {
+	getPostsFlatSinceLimitDesc := statement("getThreadPostsFlat.getPostsFlatSinceLimitDesc", `SELECT id,
 	author::TEXT,
 	message,
 	created_at,
 	forum::TEXT,
 	thread,
 	is_edited,
-	parent
+	parent,
+	deleted_at IS NOT NULL, votes
 FROM posts
 WHERE thread=$1
 	AND id < $3::TEXT::INTEGER
 ORDER BY id DESC
-LIMIT $2::TEXT::BIGINT`
-	getPostsFlatSinceLimit := `SELECT id,
+LIMIT $2::TEXT::BIGINT`)
+	getPostsFlatSinceLimit := statement("getThreadPostsFlat.getPostsFlatSinceLimit", `SELECT id,
 	author::TEXT,
 	message,
 	created_at,
 	forum::TEXT,
 	thread,
 	is_edited,
-	parent
+	parent,
+	deleted_at IS NOT NULL, votes
 FROM posts
 WHERE thread=$1
 	AND id > $3::TEXT::INTEGER
 ORDER BY id
-LIMIT $2::TEXT::BIGINT`
-	getPostsFlatLimitDesc := `SELECT id,
+LIMIT $2::TEXT::BIGINT`)
+	getPostsFlatLimitDesc := statement("getThreadPostsFlat.getPostsFlatLimitDesc", `SELECT id,
 	author::TEXT,
 	message,
 	created_at,
 	forum::TEXT,
 	thread,
 	is_edited,
-	parent
+	parent,
+	deleted_at IS NOT NULL, votes
 FROM posts
 WHERE thread=$1
 ORDER BY id DESC
-LIMIT $2::TEXT::BIGINT`
-	getPostsFlatLimit := `SELECT id,
+LIMIT $2::TEXT::BIGINT`)
+	getPostsFlatLimit := statement("getThreadPostsFlat.getPostsFlatLimit", `SELECT id,
 	author::TEXT,
 	message,
 	created_at,
 	forum::TEXT,
 	thread,
 	is_edited,
-	parent
+	parent,
+	deleted_at IS NOT NULL, votes
 FROM posts
 WHERE thread=$1
 ORDER BY id
-LIMIT $2::TEXT::BIGINT`
+LIMIT $2::TEXT::BIGINT`)
 
 	var (
 		err  error
@@ -827,35 +1197,35 @@ LIMIT $2::TEXT::BIGINT`
 	if since != nil {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsFlatSinceLimitDesc, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatSinceLimitDesc, nil, ID, limit, since)
 			} else {
-				rows, err = storage.db.Query(getPostsFlatSinceLimit, ID, limit, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatSinceLimit, nil, ID, limit, since)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsFlatSinceLimitDesc, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatSinceLimitDesc, nil, ID, nil, since)
 			} else {
-				rows, err = storage.db.Query(getPostsFlatSinceLimit, ID, nil, since)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatSinceLimit, nil, ID, nil, since)
 			}
 		}
 	} else {
 		if limit != nil {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsFlatLimitDesc, ID, limit)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatLimitDesc, nil, ID, limit)
 			} else {
-				rows, err = storage.db.Query(getPostsFlatLimit, ID, limit)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatLimit, nil, ID, limit)
 			}
 		} else {
 			if bytes.Equal(desc, []byte("true")) {
-				rows, err = storage.db.Query(getPostsFlatLimitDesc, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatLimitDesc, nil, ID, nil)
 			} else {
-				rows, err = storage.db.Query(getPostsFlatLimit, ID, nil)
+				rows, err = storage.db.QueryEx(ctx, getPostsFlatLimit, nil, ID, nil)
 			}
 		}
 	}
 
 	if err != nil {
-		return nil, http.StatusInternalServerError
+		return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 	}
 
 	var posts models.Posts
@@ -865,18 +1235,20 @@ LIMIT $2::TEXT::BIGINT`
 
 		if err = rows.Scan(&post.ID, &post.Author, &post.Message,
 			&post.Created, &post.Forum, &post.Thread,
-			&post.IsEdited, &post.Parent); err != nil {
-			return nil, http.StatusInternalServerError
+			&post.IsEdited, &post.Parent, &post.IsDeleted, &post.Votes); err != nil {
+			rows.Close()
+			return nil, storage.internal(ctx, models.EntityThread, strconv.Itoa(ID), err)
 		}
+		post.Tombstone()
 		posts = append(posts, *post)
 	}
 	rows.Close()
 
-	return &posts, http.StatusOK
+	return &posts, nil
 }
 
-func (storage *Storage) PutVote(slugOrID interface{}, vote *models.Vote) (*models.Thread, error) {
-	putVoteByThreadSlug := `WITH sub AS (
+func (storage *Storage) PutVote(ctx context.Context, slugOrID interface{}, vote *models.Vote) (*models.Thread, error) {
+	putVoteByThreadSlug := statement("PutVote.putVoteByThreadSlug", `WITH sub AS (
 	INSERT INTO votes (user_nickname, thread_id, voice)
 	VALUES (
 		$1,
@@ -885,7 +1257,8 @@ func (storage *Storage) PutVote(slugOrID interface{}, vote *models.Vote) (*model
 	ON CONFLICT ON CONSTRAINT unique_user_and_thread
 	DO UPDATE
 		SET prev_voice = votes.voice ,
-			voice = EXCLUDED.voice
+			voice = EXCLUDED.voice,
+			voted_at = now()
 	RETURNING prev_voice,
 		voice,
 		thread_id)
@@ -899,8 +1272,9 @@ RETURNING id,
 	forum::TEXT,
 	author::TEXT,
 	created_at,
-	votes`
-	putVoteByThreadID := `WITH sub AS (
+	votes,
+	state`)
+	putVoteByThreadID := statement("PutVote.putVoteByThreadID", `WITH sub AS (
 	INSERT INTO votes (user_nickname, thread_id, voice)
 	VALUES (
 		$1,
@@ -908,7 +1282,8 @@ RETURNING id,
 	ON CONFLICT ON CONSTRAINT unique_user_and_thread
 		DO UPDATE
 			SET prev_voice = votes.voice ,
-				voice = EXCLUDED.voice
+				voice = EXCLUDED.voice,
+				voted_at = now()
 	RETURNING prev_voice, voice, thread_id)
 UPDATE threads
 SET votes = votes - (SELECT prev_voice-voice FROM sub)
@@ -919,14 +1294,15 @@ RETURNING id,
 	forum::TEXT,
 	author::TEXT,
 	created_at,
-	votes`
+	votes,
+	state`)
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, storage.internal(ctx, models.EntityVote, slugOrID.(string), err)
 	}
 	defer func(tx *pgx.Tx) {
-		_ = tx.Commit()
+		_ = tx.Rollback()
 	}(tx)
 
 	_, err = strconv.Atoi(slugOrID.(string))
@@ -936,9 +1312,9 @@ RETURNING id,
 	var slug *string
 
 	if err != nil {
-		err = tx.QueryRow(putVoteByThreadSlug, vote.Nickname, slugOrID, vote.Voice).Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum, &thread.Author, &thread.Created, &thread.Votes)
+		err = tx.QueryRowEx(ctx, putVoteByThreadSlug, nil, vote.Nickname, slugOrID, vote.Voice).Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum, &thread.Author, &thread.Created, &thread.Votes, &thread.State)
 	} else {
-		err = tx.QueryRow(putVoteByThreadID, vote.Nickname, slugOrID, vote.Voice).Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum, &thread.Author, &thread.Created, &thread.Votes)
+		err = tx.QueryRowEx(ctx, putVoteByThreadID, nil, vote.Nickname, slugOrID, vote.Voice).Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum, &thread.Author, &thread.Created, &thread.Votes, &thread.State)
 	}
 	if slug == nil {
 		thread.Slug = ""
@@ -946,35 +1322,128 @@ RETURNING id,
 		thread.Slug = *slug
 	}
 	if err != nil {
+		if pgError, ok := err.(pgx.PgError); ok && pgError.ConstraintName == "votes_user_nickname_fkey" {
+			return nil, models.NotFound(models.EntityUser, vote.Nickname)
+		}
+		switch pgErrorCode(err) {
+		case pgNotNullViolation, pgForeignKeyViolation:
+			return nil, models.NotFound(models.EntityThread, slugOrID.(string))
+		}
+		return nil, notFoundOr(err, models.EntityThread, slugOrID.(string))
+	}
+	if err = storage.enqueue(ctx, tx, threadVoted(thread, vote.Nickname, vote.Voice)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityVote, slugOrID.(string), err)
+	}
+	return thread, nil
+}
+
+func (storage *Storage) DeleteVote(ctx context.Context, threadID int, nickname string) (*models.Thread, error) {
+	query := statement("DeleteVote.query", `WITH removed AS (DELETE FROM votes WHERE thread_id = $1 AND user_nickname = $2 RETURNING voice)
+UPDATE threads
+SET votes = votes - coalesce((SELECT sum(voice) FROM removed), 0)
+WHERE id = $1
+RETURNING id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state`)
+
+	key := strconv.Itoa(threadID)
+	tx, err := storage.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, key, err)
+	}
+	defer func(tx *pgx.Tx) {
 		_ = tx.Rollback()
+	}(tx)
+
+	thread := new(models.Thread)
+	var slug *string
+	err = tx.QueryRowEx(ctx, query, nil, threadID, nickname).
+		Scan(&thread.ID, &slug, &thread.Title, &thread.Message, &thread.Forum,
+			&thread.Author, &thread.Created, &thread.Votes, &thread.State)
+	if err != nil {
+		return nil, notFoundOr(err, models.EntityThread, key)
+	}
+	if slug != nil {
+		thread.Slug = *slug
+	}
+	if err = storage.enqueue(ctx, tx, threadVoted(thread, nickname, 0)); err != nil {
 		return nil, err
 	}
 
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, key, err)
+	}
 	return thread, nil
 }
 
+func (storage *Storage) GetThreadVotes(ctx context.Context, threadID int, query models.VotersQuery) (*models.ThreadVotes, error) {
+	queryTotals := statement("GetThreadVotes.queryTotals", `SELECT coalesce(sum(voice), 0), count(*) FILTER (WHERE voice > 0), count(*) FILTER (WHERE voice < 0)
+FROM votes WHERE thread_id = $1`)
+	queryVoters := statement("GetThreadVotes.queryVoters", `SELECT user_nickname::TEXT, voice, voted_at FROM votes
+WHERE thread_id = $1 AND ($2::TEXT = '' OR lower(user_nickname) > lower($2::TEXT)) ORDER BY lower(user_nickname) LIMIT $3`)
+	queryVotersDesc := statement("GetThreadVotes.queryVotersDesc", `SELECT user_nickname::TEXT, voice, voted_at FROM votes
+WHERE thread_id = $1 AND ($2::TEXT = '' OR lower(user_nickname) < lower($2::TEXT)) ORDER BY lower(user_nickname) DESC LIMIT $3`)
+
+	key := strconv.Itoa(threadID)
+	votes := &models.ThreadVotes{Voters: []models.Voter{}}
+	err := storage.db.QueryRowEx(ctx, queryTotals, nil, threadID).Scan(&votes.Votes, &votes.Up, &votes.Down)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, key, err)
+	}
+
+	queryPage := queryVoters
+	if query.Desc {
+		queryPage = queryVotersDesc
+	}
+	rows, err := storage.db.QueryEx(ctx, queryPage, nil, threadID, query.Since, query.Limit)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, key, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var voter models.Voter
+		if err = rows.Scan(&voter.Nickname, &voter.Voice, &voter.Voted); err != nil {
+			return nil, storage.internal(ctx, models.EntityThread, key, err)
+		}
+		votes.Voters = append(votes.Voters, voter)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityThread, key, err)
+	}
+	return votes, nil
+}
+
 // post
 
-func (storage *Storage) GetPostDetails(id *string, related []byte) (*models.PostDetails, int) {
-	queryUsers := `SELECT nickname::TEXT, email::TEXT, about, fullname FROM users WHERE nickname = $1`
-	queryForum := `SELECT slug::TEXT, title, posts, threads, author::TEXT FROM forums WHERE slug=$1`
-	queryThread := `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes FROM threads WHERE id=$1`
-	queryPost := `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent FROM posts WHERE id=$1`
+func (storage *Storage) GetPostDetails(ctx context.Context, id *string, related []byte) (*models.PostDetails, error) {
+	queryUsers := statement("GetPostDetails.queryUsers", `SELECT nickname::TEXT, email::TEXT, about, fullname FROM users WHERE nickname = $1`)
+	queryForum := statement("GetPostDetails.queryForum", `SELECT slug::TEXT, title, posts, threads, author::TEXT FROM forums WHERE slug=$1`)
+	queryThread := statement("GetPostDetails.queryThread", `SELECT id, slug::TEXT, title, message, forum::TEXT, author::TEXT, created_at, votes, state FROM threads WHERE id=$1`)
+	queryPost := statement("GetPostDetails.queryPost", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes FROM posts WHERE id=$1`)
+
+	if _, err := strconv.Atoi(*id); err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
 
 	postDetails := models.PostDetails{}
 	postDetails.PostDetails = &models.Post{}
 
-	err := storage.db.QueryRow(queryPost, id).
+	err := storage.db.QueryRowEx(ctx, queryPost, nil, id).
 		Scan(&postDetails.PostDetails.ID, &postDetails.PostDetails.Author,
 			&postDetails.PostDetails.Message, &postDetails.PostDetails.Created,
 			&postDetails.PostDetails.Forum, &postDetails.PostDetails.Thread,
-			&postDetails.PostDetails.IsEdited, &postDetails.PostDetails.Parent)
+			&postDetails.PostDetails.IsEdited, &postDetails.PostDetails.Parent,
+			&postDetails.PostDetails.IsDeleted, &postDetails.PostDetails.Votes)
 	if err != nil {
-		return nil, http.StatusNotFound
+		return nil, notFoundOr(err, models.EntityPost, *id)
 	}
+	postDetails.PostDetails.Tombstone()
 
 	if related == nil {
-		return &postDetails, http.StatusOK
+		return &postDetails, nil
 	}
 
 	relatedArr := strings.Split(string(related), ",")
@@ -983,48 +1452,373 @@ func (storage *Storage) GetPostDetails(id *string, related []byte) (*models.Post
 		switch val {
 		case "user":
 			postDetails.AuthorDetails = &models.User{}
-			storage.db.QueryRow(queryUsers, &postDetails.PostDetails.Author).
+			storage.db.QueryRowEx(ctx, queryUsers, nil, &postDetails.PostDetails.Author).
 				Scan(&postDetails.AuthorDetails.Nickname, &postDetails.AuthorDetails.Email,
 					&postDetails.AuthorDetails.About, &postDetails.AuthorDetails.Fullname)
 		case "forum":
 			postDetails.ForumDetails = &models.Forum{}
-			storage.db.QueryRow(queryForum, postDetails.PostDetails.Forum).
+			storage.db.QueryRowEx(ctx, queryForum, nil, postDetails.PostDetails.Forum).
 				Scan(&postDetails.ForumDetails.Slug, &postDetails.ForumDetails.Title,
 					&postDetails.ForumDetails.Posts, &postDetails.ForumDetails.Threads,
 					&postDetails.ForumDetails.Author)
 		case "thread":
 			postDetails.ThreadDetails = &models.Thread{}
-			storage.db.QueryRow(queryThread, postDetails.PostDetails.Thread).
+			storage.db.QueryRowEx(ctx, queryThread, nil, postDetails.PostDetails.Thread).
 				Scan(&postDetails.ThreadDetails.ID, &postDetails.ThreadDetails.Slug,
 					&postDetails.ThreadDetails.Title, &postDetails.ThreadDetails.Message,
 					&postDetails.ThreadDetails.Forum, &postDetails.ThreadDetails.Author,
-					&postDetails.ThreadDetails.Created, &postDetails.ThreadDetails.Votes)
+					&postDetails.ThreadDetails.Created, &postDetails.ThreadDetails.Votes,
+					&postDetails.ThreadDetails.State)
 		}
 	}
-	return &postDetails, http.StatusOK
+	return &postDetails, nil
 }
 
-func (storage *Storage) UpdatePostDetails(id *string, postUpd *models.PostUpdate) (*models.Post, int) {
-	query := `UPDATE posts SET message=coalesce($2,message), is_edited=(CASE WHEN $2 IS NULL OR $2 = message THEN FALSE ELSE TRUE END) 
-WHERE ID=$1 RETURNING id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent`
+func (storage *Storage) UpdatePostDetails(ctx context.Context, id *string, postUpd *models.PostUpdate) (*models.Post, error) {
+	query := statement("UpdatePostDetails.query", `UPDATE posts SET message=coalesce($2,message), is_edited=(is_edited OR coalesce($2 <> message, FALSE)),
+search=to_tsvector('simple', coalesce($2, message)) 
+WHERE ID=$1 AND deleted_at IS NULL RETURNING id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, votes`)
+	queryDeleted := statement("UpdatePostDetails.queryDeleted", `SELECT deleted_at IS NOT NULL FROM posts WHERE id=$1`)
+	queryRevision := statement("UpdatePostDetails.queryRevision", `INSERT INTO post_revisions (post, editor, message)
+SELECT id, NULLIF($2, ''), message FROM posts WHERE id = $1 AND deleted_at IS NULL AND message <> $3 FOR UPDATE`)
+
+	if _, err := strconv.Atoi(*id); err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
 
-	tx, err := storage.db.Begin()
+	tx, err := storage.db.BeginEx(ctx, nil)
 	if err != nil {
-		return nil, http.StatusInternalServerError
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
 	}
 	defer func(tx *pgx.Tx) {
-		_ = tx.Commit()
+		_ = tx.Rollback()
 	}(tx)
 
+	// A revision is only recorded when the message changes, and only then is
+	// the update announced; is_edited stays set from the first edit on.
+	var changed bool
+	if postUpd.Message != nil {
+		revision, err := tx.ExecEx(ctx, queryRevision, nil, id, postUpd.Editor, postUpd.Message)
+		if err != nil {
+			return nil, storage.internal(ctx, models.EntityPost, *id, err)
+		}
+		changed = revision.RowsAffected() > 0
+	}
+
 	postUpdated := models.Post{}
 
-	err = tx.QueryRow(query, id, postUpd.Message).
+	err = tx.QueryRowEx(ctx, query, nil, id, postUpd.Message).
 		Scan(&postUpdated.ID, &postUpdated.Author, &postUpdated.Message,
 			&postUpdated.Created, &postUpdated.Forum, &postUpdated.Thread,
-			&postUpdated.IsEdited, &postUpdated.Parent)
+			&postUpdated.IsEdited, &postUpdated.Parent, &postUpdated.Votes)
+	if err == pgx.ErrNoRows {
+		var deleted bool
+		if storage.db.QueryRowEx(ctx, queryDeleted, nil, id).Scan(&deleted) == nil && deleted {
+			return nil, models.Conflict(models.EntityPost, *id, "post is deleted")
+		}
+	}
 	if err != nil {
-		return nil, http.StatusNotFound
+		return nil, notFoundOr(err, models.EntityPost, *id)
 	}
+	if changed {
+		if err = storage.enqueue(ctx, tx, postEvent(models.HookPostUpdated, &postUpdated)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	return &postUpdated, nil
+}
+
+// GetPostRevisions returns the messages edits of the post replaced, oldest first.
+func (storage *Storage) GetPostRevisions(ctx context.Context, id int) (*models.Revisions, error) {
+	query := statement("GetPostRevisions.query", `SELECT coalesce(editor::TEXT, ''), edited_at, message FROM post_revisions WHERE post = $1 ORDER BY id`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil, id)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, strconv.Itoa(id), err)
+	}
+	defer rows.Close()
+
+	revisions := models.Revisions{}
+	for rows.Next() {
+		var revision models.Revision
+		if err = rows.Scan(&revision.Editor, &revision.Created, &revision.Message); err != nil {
+			return nil, storage.internal(ctx, models.EntityPost, strconv.Itoa(id), err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, strconv.Itoa(id), err)
+	}
+	return &revisions, nil
+}
+
+func (storage *Storage) GetPostEdits(ctx context.Context, ids []int) (map[int]time.Time, error) {
+	query := statement("GetPostEdits.query", `SELECT post, max(edited_at) FROM post_revisions WHERE post = ANY($1::INTEGER[]) GROUP BY post`)
 
-	return &postUpdated, http.StatusOK
+	return storage.edits(ctx, query, models.EntityPost, ids)
+}
+
+// edits runs query, which returns the ids of the edited ones among ids and
+// when they were edited last.
+func (storage *Storage) edits(ctx context.Context, query string, entity models.Entity, ids []int) (map[int]time.Time, error) {
+	edits := make(map[int]time.Time)
+	if len(ids) == 0 {
+		return edits, nil
+	}
+	rows, err := storage.db.QueryEx(ctx, query, nil, ids)
+	if err != nil {
+		return nil, storage.internal(ctx, entity, "", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var edited time.Time
+		if err = rows.Scan(&id, &edited); err != nil {
+			return nil, storage.internal(ctx, entity, "", err)
+		}
+		edits[id] = edited
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, entity, "", err)
+	}
+	return edits, nil
+}
+
+func (storage *Storage) GetPosts(ctx context.Context, ids []int) (map[int]*models.Post, error) {
+	query := statement("GetPosts.query", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes
+FROM posts WHERE id = ANY($1::INTEGER[])`)
+
+	posts := make(map[int]*models.Post, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	rows, err := storage.db.QueryEx(ctx, query, nil, ids)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, "", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		post := new(models.Post)
+		err = rows.Scan(&post.ID, &post.Author, &post.Message, &post.Created, &post.Forum, &post.Thread, &post.IsEdited, &post.Parent, &post.IsDeleted, &post.Votes)
+		if err != nil {
+			return nil, storage.internal(ctx, models.EntityPost, "", err)
+		}
+		post.Tombstone()
+		posts[post.ID] = post
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, "", err)
+	}
+	return posts, nil
+}
+
+func (storage *Storage) PutPostVote(ctx context.Context, id *string, vote *models.Vote) (*models.Post, error) {
+	queryLock := statement("PutPostVote.queryLock", `SELECT deleted_at IS NOT NULL FROM posts WHERE id = $1 FOR UPDATE`)
+	queryPrevious := statement("PutPostVote.queryPrevious", `SELECT voice FROM post_votes WHERE post_id = $1 AND user_nickname = $2`)
+	queryVote := statement("PutPostVote.queryVote", `INSERT INTO post_votes (user_nickname, post_id, voice)
+VALUES ((SELECT nickname FROM users WHERE nickname = $2), $1, $3)
+ON CONFLICT (user_nickname, post_id) DO UPDATE SET voice = EXCLUDED.voice`)
+	queryUpdate := statement("PutPostVote.queryUpdate", `UPDATE posts SET votes = votes + $2 WHERE id = $1
+RETURNING id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, votes`)
+
+	if _, err := strconv.Atoi(*id); err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+
+	tx, err := storage.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	defer func(tx *pgx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// Locking the post serialises the votes on it, so the previous voice read
+	// below is still current when the counter is adjusted.
+	var deleted bool
+	if err = tx.QueryRowEx(ctx, queryLock, nil, id).Scan(&deleted); err != nil {
+		return nil, notFoundOr(err, models.EntityPost, *id)
+	}
+	if deleted {
+		return nil, models.Conflict(models.EntityPost, *id, "post is deleted")
+	}
+
+	var previous int
+	err = tx.QueryRowEx(ctx, queryPrevious, nil, id, vote.Nickname).Scan(&previous)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	if _, err = tx.ExecEx(ctx, queryVote, nil, id, vote.Nickname, vote.Voice); err != nil {
+		if pgErrorCode(err) == pgNotNullViolation {
+			return nil, models.NotFound(models.EntityUser, vote.Nickname)
+		}
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+
+	post := new(models.Post)
+	err = tx.QueryRowEx(ctx, queryUpdate, nil, id, vote.Voice-previous).
+		Scan(&post.ID, &post.Author, &post.Message, &post.Created, &post.Forum,
+			&post.Thread, &post.IsEdited, &post.Parent, &post.Votes)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	if err = storage.enqueue(ctx, tx, postVoted(post, vote.Nickname, vote.Voice)); err != nil {
+		return nil, err
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	return post, nil
+}
+
+func (storage *Storage) DeletePost(ctx context.Context, id *string) (*models.Post, error) {
+	query := statement("DeletePost.query", `WITH deleted AS (UPDATE posts SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING forum)
+UPDATE forums SET posts = forums.posts - 1 FROM deleted WHERE forums.slug = deleted.forum::CITEXT`)
+
+	return storage.setPostDeleted(ctx, id, query)
+}
+
+func (storage *Storage) RestorePost(ctx context.Context, id *string) (*models.Post, error) {
+	query := statement("RestorePost.query", `WITH restored AS (UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING forum)
+UPDATE forums SET posts = forums.posts + 1 FROM restored WHERE forums.slug = restored.forum::CITEXT`)
+
+	return storage.setPostDeleted(ctx, id, query)
+}
+
+// setPostDeleted runs a DeletePost or RestorePost statement, which only touch
+// posts not already in the wanted state so forums.posts is adjusted once, and
+// returns the post as it reads afterwards.
+func (storage *Storage) setPostDeleted(ctx context.Context, id *string, query string) (*models.Post, error) {
+	querySelect := statement("setPostDeleted.querySelect", `SELECT id, author::TEXT, message, created_at, forum::TEXT, thread, is_edited, parent, deleted_at IS NOT NULL, votes FROM posts WHERE id=$1`)
+
+	if _, err := strconv.Atoi(*id); err != nil {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+
+	tx, err := storage.db.BeginEx(ctx, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	defer func(tx *pgx.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if _, err = tx.ExecEx(ctx, query, nil, id); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+
+	post := new(models.Post)
+	err = tx.QueryRowEx(ctx, querySelect, nil, id).
+		Scan(&post.ID, &post.Author, &post.Message, &post.Created, &post.Forum,
+			&post.Thread, &post.IsEdited, &post.Parent, &post.IsDeleted, &post.Votes)
+	if err != nil {
+		return nil, notFoundOr(err, models.EntityPost, *id)
+	}
+	post.Tombstone()
+
+	if err = tx.CommitEx(ctx); err != nil {
+		return nil, storage.internal(ctx, models.EntityPost, *id, err)
+	}
+	return post, nil
+}
+
+func (storage *Storage) PurgePost(ctx context.Context, id *string) (int, error) {
+	query := statement("PurgePost.query", `WITH target AS (SELECT thread FROM posts WHERE id = $1),
+purged AS (DELETE FROM posts p USING target WHERE p.thread = target.thread AND p.parents @> ARRAY[$1::INTEGER]
+           RETURNING p.forum, p.deleted_at),
+counted AS (SELECT forum, count(*) AS total, count(*) FILTER (WHERE deleted_at IS NULL) AS live FROM purged GROUP BY forum)
+UPDATE forums SET posts = forums.posts - counted.live FROM counted WHERE forums.slug = counted.forum::CITEXT
+RETURNING counted.total`)
+
+	if _, err := strconv.Atoi(*id); err != nil {
+		return 0, models.NotFound(models.EntityPost, *id)
+	}
+
+	var purged int
+	if err := storage.db.QueryRowEx(ctx, query, nil, id).Scan(&purged); err != nil {
+		return 0, notFoundOr(err, models.EntityPost, *id)
+	}
+	return purged, nil
+}
+
+// search
+
+func (storage *Storage) Search(ctx context.Context, query models.SearchQuery) (*models.SearchResults, error) {
+	querySearch := statement("Search.query", `WITH query AS (SELECT websearch_to_tsquery('simple', $1) AS q),
+matches AS (SELECT 'thread'::TEXT AS kind, t.id, ts_rank(t.search, query.q) AS rank
+            FROM threads t, query
+            WHERE t.search @@ query.q AND t.state <> 'deleted' AND ($2::TEXT = '' OR t.forum = $2::CITEXT) AND ($3::TEXT = '' OR t.author = $3::CITEXT)
+            UNION ALL
+            SELECT 'post'::TEXT, p.id, ts_rank(p.search, query.q)
+            FROM posts p JOIN threads pt ON pt.id = p.thread, query
+            WHERE p.search @@ query.q AND p.deleted_at IS NULL AND pt.state <> 'deleted' AND ($2::TEXT = '' OR p.forum::CITEXT = $2::CITEXT) AND ($3::TEXT = '' OR p.author::CITEXT = $3::CITEXT))
+SELECT m.kind, m.id, m.rank,
+       ts_headline('simple', coalesce(p.message, t.title || ' ' || t.message), query.q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'),
+       coalesce(p.author, t.author::TEXT), coalesce(p.message, t.message), coalesce(p.forum, t.forum::TEXT), coalesce(p.created_at, t.created_at),
+       p.thread, p.is_edited, p.parent, t.title, t.slug::TEXT, coalesce(p.votes, t.votes), t.state
+FROM matches m
+         CROSS JOIN query
+         LEFT JOIN posts p ON m.kind = 'post' AND p.id = m.id
+         LEFT JOIN threads t ON m.kind = 'thread' AND t.id = m.id
+WHERE $4::REAL IS NULL OR (m.rank, m.kind, m.id) < ($4::REAL, $5::TEXT, $6::INTEGER)
+ORDER BY m.rank DESC, m.kind DESC, m.id DESC
+LIMIT $7`)
+
+	var sinceRank *float32
+	var sinceKind *string
+	var sinceID *int
+	if query.Since != nil {
+		sinceRank, sinceKind, sinceID = &query.Since.Rank, &query.Since.Kind, &query.Since.ID
+	}
+
+	rows, err := storage.db.QueryEx(ctx, querySearch, nil,
+		query.Text, query.Forum, query.Author, sinceRank, sinceKind, sinceID, query.Limit)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntitySearch, query.Text, err)
+	}
+	defer rows.Close()
+
+	results := &models.SearchResults{Results: make([]models.SearchResult, 0, query.Limit)}
+	for rows.Next() {
+		var result models.SearchResult
+		var id int
+		var author, message, forum string
+		var created time.Time
+		var thread, votes *int
+		var isEdited *bool
+		var parent *int32
+		var title, slug, state *string
+		err = rows.Scan(&result.Kind, &id, &result.Rank, &result.Snippet,
+			&author, &message, &forum, &created, &thread, &isEdited, &parent, &title, &slug, &votes, &state)
+		if err != nil {
+			return nil, storage.internal(ctx, models.EntitySearch, query.Text, err)
+		}
+
+		if result.Kind == models.SearchPost {
+			result.Post = &models.Post{ID: id, Author: author, Message: message, Forum: forum, Created: created,
+				Thread: *thread, IsEdited: *isEdited, Parent: *parent, Votes: *votes}
+		} else {
+			result.Thread = &models.Thread{ID: id, Author: author, Message: message, Forum: forum, Created: created,
+				Title: *title, Votes: *votes, State: *state}
+			if slug != nil {
+				result.Thread.Slug = *slug
+			}
+		}
+		results.Results = append(results.Results, result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntitySearch, query.Text, err)
+	}
+
+	if len(results.Results) == query.Limit && query.Limit > 0 {
+		results.Next = results.Results[len(results.Results)-1].Cursor().String()
+	}
+	return results, nil
 }
@@ -0,0 +1,24 @@
+package repository
+
+import "sync"
+
+// statementNames maps the SQL text of every query Storage runs to a stable
+// name, so instrumentation can label queries without quoting them.
+var statementNames sync.Map
+
+// statement registers sql under name and returns it unchanged.
+func statement(name string, sql string) string {
+	if _, ok := statementNames.Load(sql); !ok {
+		statementNames.Store(sql, name)
+	}
+	return sql
+}
+
+// StatementName returns the name sql was registered under as "Method.query",
+// or "other" for SQL that Storage has not run yet.
+func StatementName(sql string) string {
+	if name, ok := statementNames.Load(sql); ok {
+		return name.(string)
+	}
+	return "other"
+}
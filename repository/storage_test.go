@@ -0,0 +1,1056 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx"
+	"github.com/rs/zerolog"
+	"os"
+	"strconv"
+	"strings"
+	"technopark-forum/migrate"
+	"technopark-forum/models"
+	"testing"
+	"time"
+)
+
+// ctx is the context every storage call in these tests runs under.
+var ctx = context.Background()
+
+// backends returns every ForumRepository the behavioural tests run against.
+// The Postgres backend is only exercised when FORUM_TEST_DATABASE_URL points
+// at a database; it is migrated up and cleared before every test.
+func backends(t *testing.T) map[string]ForumRepository {
+	result := map[string]ForumRepository{"memory": NewMemoryStorage()}
+
+	uri := os.Getenv("FORUM_TEST_DATABASE_URL")
+	if uri == "" {
+		return result
+	}
+	connConfig, err := pgx.ParseURI(uri)
+	if err != nil {
+		t.Fatalf("parse FORUM_TEST_DATABASE_URL: %s", err)
+	}
+	db, err := pgx.NewConnPool(pgx.ConnPoolConfig{ConnConfig: connConfig, MaxConnections: 4})
+	if err != nil {
+		t.Fatalf("connect to test database: %s", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate test database: %s", err)
+	}
+
+	storage := NewForumStorage(db, zerolog.Nop())
+	if err = storage.Clear(ctx); err != nil {
+		t.Fatalf("clear test database: %s", err)
+	}
+	result["postgres"] = storage
+	return result
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, repo ForumRepository)) {
+	for name, repo := range backends(t) {
+		repo := repo
+		t.Run(name, func(t *testing.T) {
+			test(t, repo)
+		})
+	}
+}
+
+func mustCreateUser(t *testing.T, repo ForumRepository, nickname string) {
+	t.Helper()
+	user := &models.User{Nickname: nickname, Email: nickname + "@example.com", Fullname: nickname}
+	if conflicts, err := repo.CreateUser(ctx, user); err != nil || conflicts != nil {
+		t.Fatalf("CreateUser(%s) = %v, %v", nickname, conflicts, err)
+	}
+}
+
+func mustCreateThread(t *testing.T, repo ForumRepository, forumSlug, author, slug string, created time.Time) *models.Thread {
+	t.Helper()
+	user, err := repo.GetUserProfile(ctx, author)
+	if err != nil {
+		t.Fatalf("GetUserProfile(%s): %s", author, err)
+	}
+	forum, err := repo.GetForum(ctx, forumSlug)
+	if err != nil {
+		t.Fatalf("GetForum(%s): %s", forumSlug, err)
+	}
+	thread, err := repo.CreateThread(ctx, user, forum, &models.Thread{
+		Title: slug, Author: user.Nickname, Forum: forum.Slug, Message: slug, Slug: slug, Created: created,
+	})
+	if err != nil {
+		t.Fatalf("CreateThread(%s): %s", slug, err)
+	}
+	return thread
+}
+
+func mustCreatePosts(t *testing.T, repo ForumRepository, threadID int, posts models.Posts) models.Posts {
+	t.Helper()
+	created, err := repo.CreatePosts(ctx, strconv.Itoa(threadID), &posts)
+	if err != nil {
+		t.Fatalf("CreatePosts: %s", err)
+	}
+	return *created
+}
+
+func postIDs(posts *models.Posts) []int {
+	var ids []int
+	for _, post := range *posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func equalIDs(lhs, rhs []int) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for i := range lhs {
+		if lhs[i] != rhs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUsers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "Alice")
+		mustCreateUser(t, repo, "bob")
+
+		conflicts, err := repo.CreateUser(ctx, &models.User{Nickname: "ALICE", Email: "BOB@example.com", Fullname: "x"})
+		if err != nil || conflicts == nil || len(*conflicts) != 2 {
+			t.Fatalf("CreateUser conflict = %v, %v; want both existing users", conflicts, err)
+		}
+
+		user, err := repo.GetUserProfile(ctx, "alice")
+		if err != nil || user.Nickname != "Alice" {
+			t.Fatalf("GetUserProfile(alice) = %v, %v", user, err)
+		}
+		if _, err = repo.GetUserProfile(ctx, "carol"); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("GetUserProfile(carol) error = %v, want not found", err)
+		}
+
+		updated, err := repo.UpdateUserProfile(ctx, &models.User{Nickname: "alice", About: "hi"})
+		if err != nil || updated.About != "hi" || updated.Email != "Alice@example.com" {
+			t.Fatalf("UpdateUserProfile = %v, %v", updated, err)
+		}
+		if _, err = repo.UpdateUserProfile(ctx, &models.User{Nickname: "alice", Email: "bob@EXAMPLE.com"}); !errors.Is(err, models.ErrConflict) {
+			t.Fatalf("UpdateUserProfile with a taken email error = %v, want conflict", err)
+		}
+		if _, err = repo.UpdateUserProfile(ctx, &models.User{Nickname: "carol", About: "x"}); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("UpdateUserProfile for a missing user error = %v, want not found", err)
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		user := &models.User{Nickname: "Alice", Email: "alice@example.com", Fullname: "Alice", PasswordHash: "hash"}
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		mustCreateUser(t, repo, "bob")
+
+		nickname, hash, err := repo.GetPasswordHash(ctx, "alice")
+		if err != nil || nickname != "Alice" || hash != "hash" {
+			t.Fatalf("GetPasswordHash(alice) = %q, %q, %v", nickname, hash, err)
+		}
+		if _, hash, err = repo.GetPasswordHash(ctx, "bob"); err != nil || hash != "" {
+			t.Fatalf("GetPasswordHash(bob) = %q, %v; want no hash", hash, err)
+		}
+		if _, err = repo.UpdateUserProfile(ctx, &models.User{Nickname: "bob", PasswordHash: "new"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, hash, _ = repo.GetPasswordHash(ctx, "bob"); hash != "new" {
+			t.Errorf("GetPasswordHash(bob) after update = %q", hash)
+		}
+
+		expires := time.Now().Add(time.Hour)
+		if err = repo.CreateSession(ctx, "live", &models.Session{Nickname: "Alice", Expires: expires}); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.CreateSession(ctx, "stale", &models.Session{Nickname: "Alice", Expires: time.Now().Add(-time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.CreateSession(ctx, "ghost", &models.Session{Nickname: "carol", Expires: expires}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("CreateSession for a missing user error = %v, want not found", err)
+		}
+
+		session, err := repo.GetSession(ctx, "live")
+		if err != nil || session.Nickname != "Alice" {
+			t.Fatalf("GetSession(live) = %+v, %v", session, err)
+		}
+		if _, err = repo.GetSession(ctx, "stale"); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetSession(stale) error = %v, want not found", err)
+		}
+		if err = repo.DeleteSession(ctx, "live"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = repo.GetSession(ctx, "live"); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetSession after DeleteSession error = %v, want not found", err)
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "Bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+
+		if role, err := repo.GetUserRole(ctx, "bob"); err != nil || role != models.RoleUser {
+			t.Fatalf("GetUserRole(bob) = %q, %v; want user", role, err)
+		}
+		if role, err := repo.SetUserRole(ctx, "bob", models.RoleAdmin); err != nil || role.Nickname != "Bob" || role.Role != models.RoleAdmin {
+			t.Fatalf("SetUserRole(bob) = %+v, %v", role, err)
+		}
+		if role, _ := repo.GetUserRole(ctx, "BOB"); role != models.RoleAdmin {
+			t.Errorf("GetUserRole(BOB) after SetUserRole = %q", role)
+		}
+		if _, err := repo.SetUserRole(ctx, "carol", models.RoleAdmin); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("SetUserRole(carol) error = %v, want not found", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := repo.AddModerator(ctx, "go", "Bob"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		moderators, err := repo.GetForumModerators(ctx, "GO")
+		if err != nil || len(*moderators) != 1 || (*moderators)[0].Nickname != "Bob" {
+			t.Fatalf("GetForumModerators = %v, %v", moderators, err)
+		}
+		if moderator, err := repo.IsModerator(ctx, "go", "bob"); err != nil || !moderator {
+			t.Errorf("IsModerator(go, bob) = %v, %v", moderator, err)
+		}
+		if err = repo.RemoveModerator(ctx, "go", "Bob"); err != nil {
+			t.Fatal(err)
+		}
+		if moderator, _ := repo.IsModerator(ctx, "go", "Bob"); moderator {
+			t.Error("IsModerator after RemoveModerator = true")
+		}
+	})
+}
+
+func TestForumsAndThreads(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "Alice")
+		if err := repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go-lang"}); err != nil {
+			t.Fatalf("CreateForum: %s", err)
+		}
+		err := repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "GO-LANG"})
+		if !errors.Is(err, models.ErrConflict) {
+			t.Fatalf("CreateForum duplicate error = %v, want conflict", err)
+		}
+		err = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "nobody", Slug: "other"})
+		if !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("CreateForum with unknown author error = %v, want not found", err)
+		}
+
+		base := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+		first := mustCreateThread(t, repo, "go-lang", "alice", "first", base)
+		mustCreateThread(t, repo, "go-lang", "alice", "second", base.Add(time.Hour))
+		mustCreateThread(t, repo, "go-lang", "alice", "third", base.Add(2*time.Hour))
+
+		user, _ := repo.GetUserProfile(ctx, "alice")
+		forum, _ := repo.GetForum(ctx, "go-lang")
+		existing, err := repo.CreateThread(ctx, user, forum, &models.Thread{Title: "t", Author: "Alice", Message: "m", Slug: "FIRST"})
+		if !errors.Is(err, models.ErrConflict) || existing == nil || existing.ID != first.ID {
+			t.Fatalf("CreateThread duplicate slug = %v, %v", existing, err)
+		}
+
+		if forum.Threads != 3 {
+			t.Errorf("forum threads = %d, want 3", forum.Threads)
+		}
+		bySlug, err := repo.GetThread(ctx, "First")
+		if err != nil || bySlug.ID != first.ID {
+			t.Fatalf("GetThread by slug = %v, %v", bySlug, err)
+		}
+		if _, err = repo.GetThread(ctx, "404"); !errors.Is(err, models.ErrNotFound) {
+			t.Fatalf("GetThread(404) error = %v, want not found", err)
+		}
+
+		threads, err := repo.GetForumThreads(ctx, "go-lang", []byte("2"), []byte(base.Add(2*time.Hour).Format(time.RFC3339)), []byte("true"), false)
+		if err != nil || len(*threads) != 2 || (*threads)[0].Slug != "third" || (*threads)[1].Slug != "second" {
+			t.Fatalf("GetForumThreads desc since = %v, %v", threads, err)
+		}
+
+		title := "renamed"
+		updated, err := repo.UpdateThread(ctx, first.ID, &models.ThreadUpdate{Title: &title})
+		if err != nil || updated.Title != title || updated.Message != "first" {
+			t.Fatalf("UpdateThread = %v, %v", updated, err)
+		}
+
+		users, err := repo.GetForumUsers(ctx, "go-lang", []byte("10"), nil, nil)
+		if err != nil || len(*users) != 1 || (*users)[0].Nickname != "Alice" {
+			t.Fatalf("GetForumUsers = %v, %v", users, err)
+		}
+	})
+}
+
+func TestPosts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "bob")
+		mustCreateUser(t, repo, "Carol")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "posts", time.Now())
+		other := mustCreateThread(t, repo, "go", "alice", "other", time.Now())
+
+		// 1
+		// ├── 3
+		// │   └── 5
+		// └── 4
+		// 2
+		// └── 6
+		roots := mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "1"}, {Author: "bob", Message: "2"},
+		})
+		mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "3", Parent: int32(roots[0].ID)},
+			{Author: "bob", Message: "4", Parent: int32(roots[0].ID)},
+		})
+		mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "5", Parent: 3},
+			{Author: "alice", Message: "6", Parent: int32(roots[1].ID)},
+		})
+
+		if _, err := repo.CreatePosts(ctx, strconv.Itoa(other.ID), &models.Posts{{Author: "alice", Message: "x", Parent: 1}}); !errors.Is(err, models.ErrConflict) {
+			t.Errorf("CreatePosts with parent from another thread error = %v, want conflict", err)
+		}
+		var notFound *models.NotFoundError
+		_, err := repo.CreatePosts(ctx, strconv.Itoa(thread.ID), &models.Posts{{Author: "dave", Message: "x"}})
+		if !errors.As(err, &notFound) || notFound.Entity != models.EntityUser {
+			t.Errorf("CreatePosts with unknown author error = %v, want user not found", err)
+		}
+		_, err = repo.CreatePosts(ctx, "missing", &models.Posts{})
+		if !errors.As(err, &notFound) || notFound.Entity != models.EntityThread {
+			t.Errorf("CreatePosts into missing thread error = %v, want thread not found", err)
+		}
+
+		slug := thread.Slug
+		tests := []struct {
+			name  string
+			limit string
+			since string
+			sort  string
+			desc  bool
+			want  []int
+		}{
+			{name: "flat", limit: "10", sort: "flat", want: []int{1, 2, 3, 4, 5, 6}},
+			{name: "flat desc since", limit: "3", since: "5", sort: "flat", desc: true, want: []int{4, 3, 2}},
+			{name: "tree", limit: "10", sort: "tree", want: []int{1, 3, 5, 4, 2, 6}},
+			{name: "tree since", limit: "2", since: "5", sort: "tree", want: []int{4, 2}},
+			{name: "tree desc", limit: "4", sort: "tree", desc: true, want: []int{6, 2, 4, 5}},
+			{name: "parent tree", limit: "1", sort: "parent_tree", want: []int{1, 3, 5, 4}},
+			{name: "parent tree desc", limit: "2", sort: "parent_tree", desc: true, want: []int{2, 6, 1, 3, 5, 4}},
+			{name: "parent tree since", limit: "1", since: "1", sort: "parent_tree", want: []int{2, 6}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var since, desc []byte
+				if tt.since != "" {
+					since = []byte(tt.since)
+				}
+				if tt.desc {
+					desc = []byte("true")
+				}
+				posts, err := repo.GetThreadPosts(ctx, &slug, []byte(tt.limit), since, []byte(tt.sort), desc)
+				if err != nil {
+					t.Fatalf("GetThreadPosts: %s", err)
+				}
+				if got := postIDs(posts); !equalIDs(got, tt.want) {
+					t.Errorf("GetThreadPosts ids = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		forum, _ := repo.GetForum(ctx, "go")
+		if forum.Posts != 6 {
+			t.Errorf("forum posts = %d, want 6", forum.Posts)
+		}
+		users, _ := repo.GetForumUsers(ctx, "go", nil, []byte("alice"), nil)
+		if len(*users) != 1 || (*users)[0].Nickname != "bob" {
+			t.Errorf("GetForumUsers since alice = %v, want [bob]", users)
+		}
+
+		id := "5"
+		details, err := repo.GetPostDetails(ctx, &id, []byte("user,thread"))
+		if err != nil || details.PostDetails.Parent != 3 ||
+			details.AuthorDetails.Nickname != "alice" || details.ThreadDetails.ID != thread.ID {
+			t.Fatalf("GetPostDetails = %v, %v", details, err)
+		}
+
+		message := "edited"
+		post, err := repo.UpdatePostDetails(ctx, &id, &models.PostUpdate{Message: &message})
+		if err != nil || !post.IsEdited || post.Message != message {
+			t.Fatalf("UpdatePostDetails = %v, %v", post, err)
+		}
+		// Updates that change nothing keep the post edited and add no revision.
+		for _, update := range []*models.PostUpdate{{Message: &message}, {}} {
+			if post, err = repo.UpdatePostDetails(ctx, &id, update); err != nil || !post.IsEdited || post.Message != message {
+				t.Errorf("UpdatePostDetails without a change = %v, %v", post, err)
+			}
+		}
+		if revisions, err := repo.GetPostRevisions(ctx, 5); err != nil || len(*revisions) != 1 {
+			t.Errorf("GetPostRevisions = %v, %v, want one revision", revisions, err)
+		}
+		missing := "100"
+		if _, err = repo.UpdatePostDetails(ctx, &missing, &models.PostUpdate{Message: &message}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("UpdatePostDetails missing post error = %v, want not found", err)
+		}
+	})
+}
+
+func TestVotes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "vote", time.Now())
+		id := strconv.Itoa(thread.ID)
+
+		steps := []struct {
+			slugOrID string
+			vote     models.Vote
+			want     int
+		}{
+			{slugOrID: id, vote: models.Vote{Nickname: "alice", Voice: 1}, want: 1},
+			{slugOrID: "vote", vote: models.Vote{Nickname: "bob", Voice: 1}, want: 2},
+			{slugOrID: id, vote: models.Vote{Nickname: "alice", Voice: -1}, want: 0},
+			{slugOrID: id, vote: models.Vote{Nickname: "alice", Voice: -1}, want: 0},
+		}
+		for _, step := range steps {
+			vote := step.vote
+			got, err := repo.PutVote(ctx, step.slugOrID, &vote)
+			if err != nil || got.Votes != step.want {
+				t.Fatalf("PutVote(%v) = %v, %v; want votes %d", step.vote, got, err, step.want)
+			}
+		}
+
+		if _, err := repo.PutVote(ctx, "missing", &models.Vote{Nickname: "alice", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("PutVote into missing thread error = %v, want not found", err)
+		}
+		if _, err := repo.PutVote(ctx, id, &models.Vote{Nickname: "carol", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("PutVote by missing user error = %v, want not found", err)
+		}
+
+		status, _ := repo.GetStatus(ctx)
+		if *status != (models.Status{Forum: 1, Thread: 1, User: 2}) {
+			t.Errorf("GetStatus = %v", status)
+		}
+		_ = repo.Clear(ctx)
+		status, _ = repo.GetStatus(ctx)
+		if *status != (models.Status{}) {
+			t.Errorf("GetStatus after Clear = %v", status)
+		}
+	})
+}
+
+func TestRetractVotes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		for _, nickname := range []string{"alice", "bob", "carol"} {
+			mustCreateUser(t, repo, nickname)
+		}
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "voters", time.Now())
+		id := strconv.Itoa(thread.ID)
+
+		for _, vote := range []models.Vote{{Nickname: "carol", Voice: 1}, {Nickname: "alice", Voice: 1}, {Nickname: "bob", Voice: -1}} {
+			vote := vote
+			if _, err := repo.PutVote(ctx, id, &vote); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		votes, err := repo.GetThreadVotes(ctx, thread.ID, models.VotersQuery{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if votes.Votes != 1 || votes.Up != 2 || votes.Down != 1 || len(votes.Voters) != 2 ||
+			votes.Voters[0].Nickname != "alice" || votes.Voters[1].Nickname != "bob" || votes.Voters[1].Voice != -1 || votes.Voters[0].Voted.IsZero() {
+			t.Errorf("GetThreadVotes = %+v; want alice and bob of 2 up and 1 down", votes)
+		}
+		votes, err = repo.GetThreadVotes(ctx, thread.ID, models.VotersQuery{Limit: 10, Since: "BOB", Desc: true})
+		if err != nil || len(votes.Voters) != 1 || votes.Voters[0].Nickname != "alice" {
+			t.Errorf("GetThreadVotes before bob = %+v, %v; want alice", votes, err)
+		}
+
+		got, err := repo.DeleteVote(ctx, thread.ID, "BOB")
+		if err != nil || got.Votes != 2 {
+			t.Fatalf("DeleteVote(bob) = %v, %v; want votes 2", got, err)
+		}
+		if got, err = repo.DeleteVote(ctx, thread.ID, "bob"); err != nil || got.Votes != 2 {
+			t.Errorf("DeleteVote(bob) again = %v, %v; want votes 2", got, err)
+		}
+		if votes, err = repo.GetThreadVotes(ctx, thread.ID, models.VotersQuery{Limit: 10}); err != nil || votes.Up != 2 || votes.Down != 0 || len(votes.Voters) != 2 {
+			t.Errorf("GetThreadVotes after retracting = %+v, %v", votes, err)
+		}
+		if _, err = repo.DeleteVote(ctx, 999, "bob"); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("DeleteVote on missing thread error = %v, want not found", err)
+		}
+	})
+}
+
+func TestPostVotes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "reputation", time.Now())
+		posts := mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "question"},
+			{Author: "bob", Message: "answer"},
+		})
+		question, answer := strconv.Itoa(posts[0].ID), strconv.Itoa(posts[1].ID)
+
+		steps := []struct {
+			vote models.Vote
+			want int
+		}{
+			{vote: models.Vote{Nickname: "alice", Voice: 1}, want: 1},
+			{vote: models.Vote{Nickname: "BOB", Voice: 1}, want: 2},
+			{vote: models.Vote{Nickname: "alice", Voice: -1}, want: 0},
+			{vote: models.Vote{Nickname: "alice", Voice: 1}, want: 2},
+		}
+		for _, step := range steps {
+			vote := step.vote
+			got, err := repo.PutPostVote(ctx, &answer, &vote)
+			if err != nil || got.Votes != step.want {
+				t.Fatalf("PutPostVote(%v) = %v, %v; want votes %d", step.vote, got, err, step.want)
+			}
+		}
+		if _, err := repo.PutPostVote(ctx, &question, &models.Vote{Nickname: "bob", Voice: -1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.PutVote(ctx, "reputation", &models.Vote{Nickname: "bob", Voice: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		details, err := repo.GetPostDetails(ctx, &answer, nil)
+		if err != nil || details.PostDetails.Votes != 2 {
+			t.Errorf("GetPostDetails = %+v, %v; want votes 2", details, err)
+		}
+		for nickname, want := range map[string]int{"alice": 0, "bob": 2, "carol": 0} {
+			if got, err := repo.GetReputation(ctx, nickname); err != nil || got != want {
+				t.Errorf("GetReputation(%s) = %d, %v; want %d", nickname, got, err, want)
+			}
+		}
+		leaders, err := repo.GetForumLeaderboard(ctx, "go", 10)
+		if err != nil || len(*leaders) != 2 || (*leaders)[0].Nickname != "bob" || *(*leaders)[0].Reputation != 2 ||
+			(*leaders)[1].Nickname != "alice" || *(*leaders)[1].Reputation != 0 {
+			t.Errorf("GetForumLeaderboard = %+v, %v; want bob then alice", leaders, err)
+		}
+		if leaders, err = repo.GetForumLeaderboard(ctx, "go", 1); err != nil || len(*leaders) != 1 {
+			t.Errorf("GetForumLeaderboard limited to 1 = %+v, %v", leaders, err)
+		}
+
+		missing := "999"
+		if _, err = repo.PutPostVote(ctx, &missing, &models.Vote{Nickname: "alice", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("PutPostVote on missing post error = %v, want not found", err)
+		}
+		if _, err = repo.PutPostVote(ctx, &answer, &models.Vote{Nickname: "carol", Voice: 1}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("PutPostVote by missing user error = %v, want not found", err)
+		}
+
+		if _, err = repo.DeletePost(ctx, &answer); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = repo.PutPostVote(ctx, &answer, &models.Vote{Nickname: "alice", Voice: 1}); !errors.Is(err, models.ErrConflict) {
+			t.Errorf("PutPostVote on deleted post error = %v, want conflict", err)
+		}
+		if got, _ := repo.GetReputation(ctx, "bob"); got != 0 {
+			t.Errorf("GetReputation(bob) after deleting the answer = %d, want 0", got)
+		}
+	})
+}
+
+func TestThreadStates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "lifecycle", time.Now())
+		mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "one"}, {Author: "alice", Message: "two"}})
+		id := strconv.Itoa(thread.ID)
+		all := []string{models.ThreadOpen, models.ThreadClosed, models.ThreadArchived, models.ThreadDeleted}
+
+		counters := func() (int, int) {
+			forum, err := repo.GetForum(ctx, "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return forum.Threads, forum.Posts
+		}
+		listed := func(archived bool) int {
+			threads, err := repo.GetForumThreads(ctx, "go", nil, nil, nil, archived)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return len(*threads)
+		}
+
+		if thread.State != models.ThreadOpen {
+			t.Errorf("new thread state = %q", thread.State)
+		}
+		if _, err := repo.SetThreadState(ctx, "lifecycle", all, models.ThreadClosed); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.CreatePosts(ctx, id, &models.Posts{{Author: "alice", Message: "late"}}); !errors.Is(err, models.ErrConflict) {
+			t.Errorf("CreatePosts into a closed thread error = %v, want conflict", err)
+		}
+
+		archived, err := repo.SetThreadState(ctx, id, all, models.ThreadArchived)
+		if err != nil || archived.State != models.ThreadArchived {
+			t.Fatalf("SetThreadState(archived) = %v, %v", archived, err)
+		}
+		if listed(false) != 0 || listed(true) != 1 {
+			t.Errorf("archived thread listed %d times by default and %d on request", listed(false), listed(true))
+		}
+
+		if _, err = repo.SetThreadState(ctx, id, all, models.ThreadDeleted); err != nil {
+			t.Fatal(err)
+		}
+		if threads, posts := counters(); threads != 0 || posts != 0 {
+			t.Errorf("forum counters after delete = %d threads, %d posts", threads, posts)
+		}
+		if _, err = repo.GetThread(ctx, id); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetThread on a deleted thread error = %v, want not found", err)
+		}
+		if _, err = repo.SetThreadState(ctx, id, []string{models.ThreadOpen}, models.ThreadClosed); !errors.Is(err, models.ErrConflict) {
+			t.Errorf("closing a deleted thread error = %v, want conflict", err)
+		}
+
+		restored, err := repo.SetThreadState(ctx, id, []string{models.ThreadDeleted}, models.ThreadOpen)
+		if err != nil || restored.State != models.ThreadOpen {
+			t.Fatalf("restore = %v, %v", restored, err)
+		}
+		if threads, posts := counters(); threads != 1 || posts != 2 {
+			t.Errorf("forum counters after restore = %d threads, %d posts", threads, posts)
+		}
+	})
+}
+
+func TestPostDeletion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "tombstones", time.Now())
+		root := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "root"}})[0]
+		reply := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "reply", Parent: int32(root.ID)}})[0]
+		leaf := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "leaf", Parent: int32(reply.ID)}})[0]
+		replyID, rootID := strconv.Itoa(reply.ID), strconv.Itoa(root.ID)
+
+		forumPosts := func() int {
+			forum, err := repo.GetForum(ctx, "go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return forum.Posts
+		}
+
+		for i := 0; i < 2; i++ {
+			deleted, err := repo.DeletePost(ctx, &replyID)
+			if err != nil || !deleted.IsDeleted || deleted.Message != "" {
+				t.Fatalf("DeletePost = %+v, %v", deleted, err)
+			}
+		}
+		if got := forumPosts(); got != 2 {
+			t.Errorf("forum posts after deleting one post twice = %d, want 2", got)
+		}
+
+		slug := strconv.Itoa(thread.ID)
+		for _, sort := range []string{"flat", "tree", "parent_tree"} {
+			posts, err := repo.GetThreadPosts(ctx, &slug, nil, nil, []byte(sort), nil)
+			if err != nil || !equalIDs(postIDs(posts), []int{root.ID, reply.ID, leaf.ID}) {
+				t.Fatalf("GetThreadPosts(%s) = %v, %v", sort, posts, err)
+			}
+			if tombstone := (*posts)[1]; !tombstone.IsDeleted || tombstone.Message != "" || (*posts)[2].Message != "leaf" {
+				t.Errorf("GetThreadPosts(%s) renders %+v", sort, *posts)
+			}
+		}
+
+		posts, err := repo.GetPosts(ctx, []int{reply.ID, leaf.ID, leaf.ID + 100})
+		if err != nil || len(posts) != 2 || !posts[reply.ID].IsDeleted || posts[reply.ID].Message != "" || posts[leaf.ID].Message != "leaf" {
+			t.Errorf("GetPosts = %+v, %v; want the tombstone and the leaf", posts, err)
+		}
+
+		message := "edited"
+		if _, err := repo.UpdatePostDetails(ctx, &replyID, &models.PostUpdate{Message: &message}); !errors.Is(err, models.ErrConflict) {
+			t.Errorf("UpdatePostDetails on a tombstone error = %v, want conflict", err)
+		}
+
+		restored, err := repo.RestorePost(ctx, &replyID)
+		if err != nil || restored.IsDeleted || restored.Message != "reply" || forumPosts() != 3 {
+			t.Fatalf("RestorePost = %+v, %v with %d forum posts", restored, err, forumPosts())
+		}
+
+		_, _ = repo.DeletePost(ctx, &replyID)
+		purged, err := repo.PurgePost(ctx, &rootID)
+		if err != nil || purged != 3 || forumPosts() != 0 {
+			t.Fatalf("PurgePost = %d, %v with %d forum posts, want 3 and 0", purged, err, forumPosts())
+		}
+		if _, err = repo.GetPostDetails(ctx, &rootID, nil); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetPostDetails after purge error = %v, want not found", err)
+		}
+		if status, _ := repo.GetStatus(ctx); status.Post != 0 {
+			t.Errorf("GetStatus after purge = %+v", status)
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "history", time.Now())
+		post := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "first"}})[0]
+		id := strconv.Itoa(post.ID)
+
+		for _, message := range []string{"second", "second", "third"} {
+			message := message
+			if _, err := repo.UpdatePostDetails(ctx, &id, &models.PostUpdate{Message: &message, Editor: "bob"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		revisions, err := repo.GetPostRevisions(ctx, post.ID)
+		if err != nil || len(*revisions) != 2 {
+			t.Fatalf("GetPostRevisions = %+v, %v; want the two replaced messages", revisions, err)
+		}
+		if first := (*revisions)[0]; first.Message != "first" || first.Editor != "bob" || first.Created.IsZero() {
+			t.Errorf("first post revision = %+v", first)
+		}
+		postEdited := (*revisions)[1].Created
+
+		title := "renamed"
+		if _, err = repo.UpdateThread(ctx, thread.ID, &models.ThreadUpdate{Title: &title}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = repo.UpdateThread(ctx, thread.ID, &models.ThreadUpdate{Title: &title}); err != nil {
+			t.Fatal(err)
+		}
+		revisions, err = repo.GetThreadRevisions(ctx, thread.ID)
+		if err != nil || len(*revisions) != 1 {
+			t.Fatalf("GetThreadRevisions = %+v, %v; want one revision", revisions, err)
+		}
+		if revision := (*revisions)[0]; revision.Title != "history" || revision.Message != "history" || revision.Editor != "" {
+			t.Errorf("thread revision = %+v", revision)
+		}
+
+		unedited := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "bob", Message: "untouched"}})[0]
+		postEdits, err := repo.GetPostEdits(ctx, []int{post.ID, unedited.ID})
+		if err != nil || len(postEdits) != 1 || !postEdits[post.ID].Equal(postEdited) {
+			t.Errorf("GetPostEdits = %v, %v; want only the post, edited at %s", postEdits, err, postEdited)
+		}
+		threadEdits, err := repo.GetThreadEdits(ctx, []int{thread.ID, 999})
+		if err != nil || len(threadEdits) != 1 || !threadEdits[thread.ID].Equal((*revisions)[0].Created) {
+			t.Errorf("GetThreadEdits = %v, %v; want only the thread", threadEdits, err)
+		}
+		if none, err := repo.GetPostEdits(ctx, nil); err != nil || len(none) != 0 {
+			t.Errorf("GetPostEdits(nil) = %v, %v", none, err)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		mustCreateUser(t, repo, "bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Sea", Author: "alice", Slug: "sea"})
+		thread := mustCreateThread(t, repo, "sea", "alice", "voyage", time.Now())
+		posts := mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "The black pearl sails at dawn"},
+			{Author: "bob", Message: "A pearl of wisdom"},
+			{Author: "bob", Message: "Nothing to see"},
+		})
+
+		edited := "Found a pearl after all"
+		id := strconv.Itoa(posts[2].ID)
+		if _, err := repo.UpdatePostDetails(ctx, &id, &models.PostUpdate{Message: &edited}); err != nil {
+			t.Fatal(err)
+		}
+		title := "Pearl hunting"
+		if _, err := repo.UpdateThread(ctx, thread.ID, &models.ThreadUpdate{Title: &title}); err != nil {
+			t.Fatal(err)
+		}
+
+		seen := map[string]bool{}
+		query := models.SearchQuery{Text: "pearl", Forum: "SEA", Limit: 3}
+		for page := 0; page < 2; page++ {
+			results, err := repo.Search(ctx, query)
+			if err != nil {
+				t.Fatalf("Search: %s", err)
+			}
+			for _, result := range results.Results {
+				cursor := result.Cursor()
+				seen[cursor.Kind+strconv.Itoa(cursor.ID)] = true
+				if !strings.Contains(strings.ToLower(result.Snippet), "<mark>pearl</mark>") {
+					t.Errorf("snippet %q does not highlight the match", result.Snippet)
+				}
+			}
+			if (page == 0) != (results.Next != "") {
+				t.Fatalf("page %d has next %q", page, results.Next)
+			}
+			query.Since, _ = models.ParseSearchCursor(results.Next)
+		}
+		if len(seen) != 4 || !seen["thread"+strconv.Itoa(thread.ID)] || !seen["post"+id] {
+			t.Errorf("search over two pages found %v, want the thread and all three posts", seen)
+		}
+
+		results, err := repo.Search(ctx, models.SearchQuery{Text: "pearl wisdom", Author: "bob", Limit: 10})
+		if err != nil || len(results.Results) != 1 || results.Results[0].Post == nil || results.Results[0].Post.ID != posts[1].ID {
+			t.Errorf("Search by author = %+v, %v", results, err)
+		}
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Rust", Author: "alice", Slug: "rust"})
+
+		global := &models.Webhook{URL: "http://example.com/all", Events: []string{models.HookThreadCreated, models.HookUserCreated}, Secret: "global-secret-16"}
+		scoped := &models.Webhook{URL: "http://example.com/go", Forum: "GO", Events: []string{models.HookThreadCreated}, Secret: "scoped-secret-16"}
+		for _, hook := range []*models.Webhook{global, scoped} {
+			if err := repo.CreateWebhook(ctx, hook); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if scoped.ID == 0 || scoped.Forum != "go" {
+			t.Errorf("CreateWebhook = %+v; want an id and the forum slug", scoped)
+		}
+		if err := repo.CreateWebhook(ctx, &models.Webhook{URL: "http://example.com", Forum: "missing", Events: []string{models.HookPostCreated}}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("CreateWebhook on missing forum error = %v, want not found", err)
+		}
+		hooks, err := repo.GetWebhooks(ctx)
+		if err != nil || len(*hooks) != 2 || (*hooks)[0].Secret != "" {
+			t.Errorf("GetWebhooks = %+v, %v; want both without secrets", hooks, err)
+		}
+
+		err = repo.EnqueueWebhookEvents(ctx, []models.WebhookEvent{
+			{Event: models.HookThreadCreated, Forum: "go"},
+			{Event: models.HookThreadCreated, Forum: "rust"},
+			{Event: models.HookPostCreated, Forum: "go"},
+			{Event: models.HookUserCreated},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claimed, err := repo.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+		if err != nil || len(claimed) != 4 {
+			t.Fatalf("ClaimWebhookDeliveries = %+v, %v; want 4 deliveries", claimed, err)
+		}
+		perHook := map[int]int{}
+		for _, delivery := range claimed {
+			perHook[delivery.Webhook]++
+			if delivery.URL == "" || delivery.Secret == "" || len(delivery.Payload) == 0 {
+				t.Errorf("claimed delivery %+v lacks its url, secret or payload", delivery)
+			}
+		}
+		if perHook[global.ID] != 3 || perHook[scoped.ID] != 1 {
+			t.Errorf("deliveries per webhook = %v; want 3 global and 1 for go", perHook)
+		}
+		if again, err := repo.ClaimWebhookDeliveries(ctx, 10, time.Minute); err != nil || len(again) != 0 {
+			t.Errorf("ClaimWebhookDeliveries while leased = %+v, %v; want none", again, err)
+		}
+
+		retry := time.Now().Add(-time.Second).UTC()
+		for _, delivery := range claimed {
+			attempt := models.DeliveryAttempt{State: models.DeliveryDelivered, Status: 204}
+			if delivery.Event == models.HookUserCreated {
+				attempt = models.DeliveryAttempt{State: models.DeliveryPending, Status: 500, Error: "answered 500", Next: retry}
+			}
+			if err = repo.FinishWebhookAttempt(ctx, delivery.ID, attempt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		retried, err := repo.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+		if err != nil || len(retried) != 1 || retried[0].Event != models.HookUserCreated || retried[0].Attempts != 1 {
+			t.Fatalf("ClaimWebhookDeliveries after a failure = %+v, %v; want the user.created retry", retried, err)
+		}
+		if err = repo.FinishWebhookAttempt(ctx, retried[0].ID, models.DeliveryAttempt{State: models.DeliveryFailed, Status: 500, Error: "answered 500"}); err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := repo.GetWebhookDeliveries(ctx, global.ID, models.DeliveriesQuery{Limit: 10})
+		if err != nil || len(*log) != 3 || (*log)[0].Event != models.HookUserCreated || (*log)[0].State != models.DeliveryFailed ||
+			(*log)[0].Attempts != 2 || (*log)[0].Finished == nil || (*log)[2].State != models.DeliveryDelivered {
+			t.Errorf("GetWebhookDeliveries = %+v, %v; want the failed user.created first", log, err)
+		}
+		if log, err = repo.GetWebhookDeliveries(ctx, global.ID, models.DeliveriesQuery{Limit: 10, Since: (*log)[0].ID, State: models.DeliveryDelivered}); err != nil || len(*log) != 2 {
+			t.Errorf("GetWebhookDeliveries delivered before the failure = %+v, %v; want 2", log, err)
+		}
+
+		if err = repo.DeleteWebhook(ctx, scoped.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err = repo.DeleteWebhook(ctx, scoped.ID); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("DeleteWebhook again error = %v, want not found", err)
+		}
+		if _, err = repo.GetWebhookDeliveries(ctx, scoped.ID, models.DeliveriesQuery{Limit: 10}); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("GetWebhookDeliveries of deleted webhook error = %v, want not found", err)
+		}
+	})
+}
+
+func TestWebhookOutbox(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		repo.SetWebhooks(true)
+		mustCreateUser(t, repo, "alice")
+		hook := &models.Webhook{URL: "http://example.com", Events: models.HookEvents, Secret: "0123456789abcdef"}
+		if err := repo.CreateWebhook(ctx, hook); err != nil {
+			t.Fatal(err)
+		}
+
+		mustCreateUser(t, repo, "bob")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "hooked", time.Now())
+		posts := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "alice", Message: "one"}, {Author: "bob", Message: "two"}})
+		id := strconv.Itoa(posts[0].ID)
+		message := "edited"
+		for _, update := range []*string{&message, &message} {
+			if _, err := repo.UpdatePostDetails(ctx, &id, &models.PostUpdate{Message: update}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := repo.PutVote(ctx, strconv.Itoa(thread.ID), &models.Vote{Nickname: "bob", Voice: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.DeleteVote(ctx, thread.ID, "bob"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.PutPostVote(ctx, &id, &models.Vote{Nickname: "bob", Voice: -1}); err != nil {
+			t.Fatal(err)
+		}
+
+		claimed, err := repo.ClaimWebhookDeliveries(ctx, 100, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int{}
+		for _, delivery := range claimed {
+			got[delivery.Event]++
+			if delivery.Event == models.HookPostCreated && strings.Contains(string(delivery.Payload), `"parents":[`) {
+				t.Errorf("post.created payload %s carries the path", delivery.Payload)
+			}
+		}
+		// alice came before the webhook, and the second edit changed nothing.
+		want := map[string]int{models.HookUserCreated: 1, models.HookThreadCreated: 1, models.HookPostCreated: 2, models.HookPostUpdated: 1, models.HookVoteChanged: 3}
+		for event, count := range want {
+			if got[event] != count {
+				t.Errorf("queued %v, want %v", got, want)
+				break
+			}
+		}
+
+		repo.SetWebhooks(false)
+		mustCreateUser(t, repo, "carol")
+		if claimed, err = repo.ClaimWebhookDeliveries(ctx, 100, time.Minute); err != nil || len(claimed) != 0 {
+			t.Errorf("ClaimWebhookDeliveries with webhooks off = %+v, %v; want none", claimed, err)
+		}
+	})
+}
+
+func TestExportForum(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		for _, nickname := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+			mustCreateUser(t, repo, nickname)
+		}
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Rust", Author: "frank", Slug: "rust"})
+		if err := repo.AddModerator(ctx, "go", "carol"); err != nil {
+			t.Fatal(err)
+		}
+		thread := mustCreateThread(t, repo, "go", "alice", "export", time.Now())
+		other := mustCreateThread(t, repo, "rust", "frank", "elsewhere", time.Now())
+		mustCreatePosts(t, repo, other.ID, models.Posts{{Author: "frank", Message: "not exported"}})
+		roots := mustCreatePosts(t, repo, thread.ID, models.Posts{
+			{Author: "alice", Message: "first"},
+			{Author: "bob", Message: "second"},
+		})
+		reply := mustCreatePosts(t, repo, thread.ID, models.Posts{{Author: "bob", Message: "reply", Parent: int32(roots[0].ID)}})[0]
+		if _, err := repo.PutVote(ctx, strconv.Itoa(thread.ID), &models.Vote{Nickname: "DAVE", Voice: 1}); err != nil {
+			t.Fatal(err)
+		}
+		answer := strconv.Itoa(roots[1].ID)
+		if _, err := repo.PutPostVote(ctx, &answer, &models.Vote{Nickname: "erin", Voice: -1}); err != nil {
+			t.Fatal(err)
+		}
+
+		var records []*models.ExportRecord
+		err := repo.ExportForum(ctx, "GO", func(record *models.ExportRecord) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kinds []string
+		for _, record := range records {
+			switch record.Type {
+			case models.ExportUser:
+				kinds = append(kinds, record.Type+":"+record.User.Nickname)
+			case models.ExportForum:
+				kinds = append(kinds, record.Type+":"+record.Forum.Slug)
+			case models.ExportThread:
+				kinds = append(kinds, record.Type+":"+record.Thread.Slug)
+			case models.ExportPost:
+				kinds = append(kinds, record.Type+":"+record.Post.Message)
+			default:
+				kinds = append(kinds, record.Type+":"+record.Vote.Nickname+":"+strconv.Itoa(record.Target))
+			}
+		}
+		want := []string{"user:alice", "user:bob", "user:carol", "user:dave", "user:erin", "forum:go", "thread:export",
+			"post:first", "post:reply", "post:second",
+			"thread_vote:dave:" + strconv.Itoa(thread.ID), "post_vote:erin:" + answer}
+		if strings.Join(kinds, " ") != strings.Join(want, " ") {
+			t.Fatalf("ExportForum records = %v, want %v", kinds, want)
+		}
+		if post := records[8].Post; post.ID != reply.ID || len(post.Parents) != 2 || int(post.Parents[0]) != roots[0].ID || int(post.Parents[1]) != reply.ID {
+			t.Errorf("exported reply = %+v; want its parents path", post)
+		}
+		if vote := records[10]; vote.Vote.Voice != 1 || vote.Voted == nil || vote.Voted.IsZero() {
+			t.Errorf("exported thread vote = %+v; want the voice and when it was cast", vote)
+		}
+		if vote := records[11].Vote; vote.Voice != -1 {
+			t.Errorf("exported post vote = %+v; want the voice", vote)
+		}
+
+		stop := errors.New("stop")
+		written := 0
+		err = repo.ExportForum(ctx, "go", func(record *models.ExportRecord) error {
+			written++
+			return stop
+		})
+		if err != stop || written != 1 {
+			t.Errorf("ExportForum with a failing write = %v after %d records, want the write error after one", err, written)
+		}
+		if err = repo.ExportForum(ctx, "missing", func(*models.ExportRecord) error { return nil }); !errors.Is(err, models.ErrNotFound) {
+			t.Errorf("ExportForum of missing forum error = %v, want not found", err)
+		}
+	})
+}
+
+func TestCanceledContext(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo ForumRepository) {
+		mustCreateUser(t, repo, "alice")
+		_ = repo.CreateForum(ctx, &models.Forum{Title: "Go", Author: "alice", Slug: "go"})
+		thread := mustCreateThread(t, repo, "go", "alice", "", time.Now())
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		slug := strconv.Itoa(thread.ID)
+		_, err := repo.GetThreadPosts(canceled, &slug, nil, nil, []byte("tree"), nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("GetThreadPosts on a canceled context: got %v, want context.Canceled", err)
+		}
+	})
+}
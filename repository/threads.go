@@ -0,0 +1,34 @@
+package repository
+
+import "technopark-forum/models"
+
+// listedThreadStates are the states GetForumThreads shows; archived threads
+// only when asked for, deleted ones never.
+func listedThreadStates(archived bool) []string {
+	if archived {
+		return []string{models.ThreadOpen, models.ThreadClosed, models.ThreadArchived}
+	}
+	return []string{models.ThreadOpen, models.ThreadClosed}
+}
+
+func containsState(states []string, state string) bool {
+	for _, candidate := range states {
+		if candidate == state {
+			return true
+		}
+	}
+	return false
+}
+
+// deletedDelta is how the forum counters change when a thread moves from one
+// state to another: -1 when it gets deleted, +1 when it is restored.
+func deletedDelta(from, to string) int {
+	switch {
+	case from != models.ThreadDeleted && to == models.ThreadDeleted:
+		return -1
+	case from == models.ThreadDeleted && to != models.ThreadDeleted:
+		return 1
+	default:
+		return 0
+	}
+}
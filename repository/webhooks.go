@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx"
+	"github.com/mailru/easyjson"
+	"strconv"
+	"sync"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+	"time"
+)
+
+// webhookCheckInterval is how long a Storage trusts its last look at whether
+// any webhook is registered. A webhook registered through another instance
+// gets the events written through this one once it looks again.
+const webhookCheckInterval = time.Second
+
+// webhookOutbox decides whether writes queue webhook deliveries: only when
+// enabled and while some webhook is registered, so writes pay for no INSERT
+// otherwise.
+type webhookOutbox struct {
+	mu         sync.Mutex
+	enabled    bool
+	registered bool
+	checked    time.Time
+}
+
+// execer is a connection pool or a transaction.
+type execer interface {
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error)
+}
+
+// webhooks
+
+func (storage *Storage) SetWebhooks(enabled bool) {
+	storage.outbox.mu.Lock()
+	defer storage.outbox.mu.Unlock()
+	storage.outbox.enabled = enabled
+	storage.outbox.checked = time.Time{}
+}
+
+// hooked reports whether writes should queue deliveries. When it cannot tell
+// it says yes: the INSERT matches events against the webhooks anyway.
+func (storage *Storage) hooked(ctx context.Context) bool {
+	query := statement("hooked.query", `SELECT EXISTS (SELECT 1 FROM webhooks)`)
+
+	outbox := &storage.outbox
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	if !outbox.enabled {
+		return false
+	}
+	if time.Since(outbox.checked) < webhookCheckInterval {
+		return outbox.registered
+	}
+	if err := storage.db.QueryRowEx(ctx, query, nil).Scan(&outbox.registered); err != nil {
+		logging.For(ctx, storage.log).Warn().Err(err).Msg("checking for webhooks failed")
+		return true
+	}
+	outbox.checked = time.Now()
+	return outbox.registered
+}
+
+// enqueue queues deliveries of events in tx, so they are stored if and only if
+// the write they report is.
+func (storage *Storage) enqueue(ctx context.Context, tx *pgx.Tx, events ...models.WebhookEvent) error {
+	if len(events) == 0 || !storage.hooked(ctx) {
+		return nil
+	}
+	return storage.insertDeliveries(ctx, tx, events)
+}
+
+func (storage *Storage) CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	query := statement("CreateWebhook.query", `INSERT INTO webhooks (url, forum, events, secret)
+VALUES ($1, NULLIF($2::TEXT, ''), $3, $4)
+RETURNING id, coalesce((SELECT f.slug::TEXT FROM forums f WHERE f.slug = webhooks.forum), ''), created_at`)
+
+	err := storage.db.QueryRowEx(ctx, query, nil, hook.URL, hook.Forum, hook.Events, hook.Secret).Scan(&hook.ID, &hook.Forum, &hook.Created)
+	if err != nil {
+		if pgErrorCode(err) == pgForeignKeyViolation {
+			return models.NotFound(models.EntityForum, hook.Forum)
+		}
+		return storage.internal(ctx, models.EntityWebhook, hook.URL, err)
+	}
+	storage.outbox.forget()
+	return nil
+}
+
+func (storage *Storage) GetWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	query := statement("GetWebhooks.query", `SELECT id, url, coalesce(forum::TEXT, ''), events, created_at FROM webhooks ORDER BY id`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+	}
+	defer rows.Close()
+
+	hooks := models.Webhooks{}
+	for rows.Next() {
+		var hook models.Webhook
+		if err = rows.Scan(&hook.ID, &hook.URL, &hook.Forum, &hook.Events, &hook.Created); err != nil {
+			return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+		}
+		hooks = append(hooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+	}
+	return &hooks, nil
+}
+
+func (storage *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	query := statement("DeleteWebhook.query", `DELETE FROM webhooks WHERE id = $1`)
+
+	key := strconv.Itoa(id)
+	tag, err := storage.db.ExecEx(ctx, query, nil, id)
+	if err != nil {
+		return storage.internal(ctx, models.EntityWebhook, key, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.NotFound(models.EntityWebhook, key)
+	}
+	storage.outbox.forget()
+	return nil
+}
+
+func (storage *Storage) EnqueueWebhookEvents(ctx context.Context, events []models.WebhookEvent) error {
+	return storage.insertDeliveries(ctx, storage.db, events)
+}
+
+// insertDeliveries matches every event against every webhook in a single
+// statement, so a batch of posts pays for one.
+func (storage *Storage) insertDeliveries(ctx context.Context, db execer, events []models.WebhookEvent) error {
+	query := statement("EnqueueWebhookEvents.query", `INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT w.id, e.event, e.payload
+FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[]) WITH ORDINALITY AS e (event, forum, payload, n)
+JOIN webhooks w ON e.event = ANY (w.events) AND (w.forum IS NULL OR w.forum = e.forum)
+ORDER BY e.n, w.id`)
+
+	if len(events) == 0 {
+		return nil
+	}
+	names := make([]string, len(events))
+	forums := make([]string, len(events))
+	payloads := make([]string, len(events))
+	for i, event := range events {
+		payload, err := easyjson.Marshal(event)
+		if err != nil {
+			return models.Internal(models.EntityWebhook, event.Event, err)
+		}
+		names[i], forums[i], payloads[i] = event.Event, event.Forum, string(payload)
+	}
+
+	if _, err := db.ExecEx(ctx, query, nil, names, forums, payloads); err != nil {
+		return storage.internal(ctx, models.EntityWebhook, events[0].Event, err)
+	}
+	return nil
+}
+
+func (storage *Storage) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error) {
+	query := statement("ClaimWebhookDeliveries.query", `UPDATE webhook_deliveries d
+SET next_attempt_at = now() + $2::BIGINT * INTERVAL '1 millisecond'
+FROM webhooks w
+WHERE w.id = d.webhook_id AND d.id IN (
+    SELECT id FROM webhook_deliveries WHERE state = 'pending' AND next_attempt_at <= now()
+    ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+RETURNING d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.attempts`)
+
+	rows, err := storage.db.QueryEx(ctx, query, nil, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.PendingDelivery
+	for rows.Next() {
+		var delivery models.PendingDelivery
+		var payload string
+		err = rows.Scan(&delivery.ID, &delivery.Webhook, &delivery.URL, &delivery.Secret, &delivery.Event, &payload, &delivery.Attempts)
+		if err != nil {
+			return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+		}
+		delivery.Payload = []byte(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, "", err)
+	}
+	return deliveries, nil
+}
+
+func (storage *Storage) FinishWebhookAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt) error {
+	query := statement("FinishWebhookAttempt.query", `UPDATE webhook_deliveries
+SET state = $2, attempts = attempts + 1, last_status = NULLIF($3, 0), last_error = NULLIF($4, ''),
+    next_attempt_at = CASE WHEN $2 = 'pending' THEN $5 ELSE next_attempt_at END,
+    finished_at = CASE WHEN $2 = 'pending' THEN NULL ELSE now() END
+WHERE id = $1`)
+
+	_, err := storage.db.ExecEx(ctx, query, nil, id, attempt.State, attempt.Status, attempt.Error, attempt.Next)
+	if err != nil {
+		return storage.internal(ctx, models.EntityWebhook, strconv.FormatInt(id, 10), err)
+	}
+	return nil
+}
+
+func (storage *Storage) GetWebhookDeliveries(ctx context.Context, webhookID int, query models.DeliveriesQuery) (*models.WebhookDeliveries, error) {
+	queryWebhook := statement("GetWebhookDeliveries.queryWebhook", `SELECT id FROM webhooks WHERE id = $1`)
+	queryPage := statement("GetWebhookDeliveries.queryPage", `SELECT id, webhook_id, event, state, attempts,
+coalesce(last_status, 0), coalesce(last_error, ''), created_at, next_attempt_at, finished_at, payload
+FROM webhook_deliveries WHERE webhook_id = $1 AND ($2::BIGINT = 0 OR id < $2) AND ($3::TEXT = '' OR state = $3)
+ORDER BY id DESC LIMIT $4`)
+
+	key := strconv.Itoa(webhookID)
+	if err := storage.db.QueryRowEx(ctx, queryWebhook, nil, webhookID).Scan(&webhookID); err != nil {
+		return nil, notFoundOr(err, models.EntityWebhook, key)
+	}
+
+	rows, err := storage.db.QueryEx(ctx, queryPage, nil, webhookID, query.Since, query.State, query.Limit)
+	if err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, key, err)
+	}
+	defer rows.Close()
+
+	deliveries := models.WebhookDeliveries{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var next time.Time
+		var payload string
+		err = rows.Scan(&delivery.ID, &delivery.Webhook, &delivery.Event, &delivery.State, &delivery.Attempts,
+			&delivery.Status, &delivery.Error, &delivery.Created, &next, &delivery.Finished, &payload)
+		if err != nil {
+			return nil, storage.internal(ctx, models.EntityWebhook, key, err)
+		}
+		if delivery.State == models.DeliveryPending {
+			delivery.NextAttempt = &next
+		}
+		delivery.Payload = []byte(payload)
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, storage.internal(ctx, models.EntityWebhook, key, err)
+	}
+	return &deliveries, nil
+}
+
+// forget makes the next write look again whether any webhook is registered.
+func (outbox *webhookOutbox) forget() {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	outbox.checked = time.Time{}
+}
+
+// The webhook events writes report, shared by both backends. Posts are shown
+// as GET /post/{id}/details shows them, without their path.
+
+func userCreated(user *models.User) models.WebhookEvent {
+	shown := &models.User{Email: user.Email, Nickname: user.Nickname, Fullname: user.Fullname, About: user.About}
+	return models.WebhookEvent{Event: models.HookUserCreated, Created: time.Now().UTC(), User: shown}
+}
+
+func threadCreated(thread *models.Thread) models.WebhookEvent {
+	shown := *thread
+	return models.WebhookEvent{Event: models.HookThreadCreated, Forum: thread.Forum, Created: time.Now().UTC(), Thread: &shown}
+}
+
+func postsCreated(posts models.Posts) []models.WebhookEvent {
+	events := make([]models.WebhookEvent, len(posts))
+	for i := range posts {
+		events[i] = postEvent(models.HookPostCreated, &posts[i])
+	}
+	return events
+}
+
+func postEvent(event string, post *models.Post) models.WebhookEvent {
+	shown := *post
+	shown.Parents = nil
+	return models.WebhookEvent{Event: event, Forum: post.Forum, Created: time.Now().UTC(), Post: &shown}
+}
+
+// threadVoted and postVoted report a vote of nickname, with voice 0 when it
+// was withdrawn.
+func threadVoted(thread *models.Thread, nickname string, voice int) models.WebhookEvent {
+	event := threadCreated(thread)
+	event.Event = models.HookVoteChanged
+	event.Vote = &models.Vote{Nickname: nickname, Voice: voice}
+	return event
+}
+
+func postVoted(post *models.Post, nickname string, voice int) models.WebhookEvent {
+	event := postEvent(models.HookVoteChanged, post)
+	event.Vote = &models.Vote{Nickname: nickname, Voice: voice}
+	return event
+}
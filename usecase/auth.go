@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+	"time"
+)
+
+// DefaultTokenTTL is how long a login stays valid unless SetAuth says otherwise.
+const DefaultTokenTTL = 24 * time.Hour
+
+type authSettings struct {
+	tokenTTL time.Duration
+}
+
+// SetAuth configures whether anonymous requests may write and how long tokens
+// handed out by Login stay valid.
+func (service *Service) SetAuth(anonymous bool, tokenTTL time.Duration) {
+	service.policy.SetAnonymous(anonymous)
+	service.auth = authSettings{tokenTTL: tokenTTL}
+}
+
+// Login checks the credentials and opens a session for the user.
+func (service *Service) Login(ctx context.Context, credentials *models.Credentials) (*models.Session, error) {
+	nickname, hash, err := service.repository.GetPasswordHash(ctx, credentials.Nickname)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil || !auth.CheckPassword(hash, credentials.Password) {
+		return nil, models.Unauthorized("invalid nickname or password")
+	}
+
+	token, tokenHash, err := auth.NewToken()
+	if err != nil {
+		return nil, models.Internal(models.EntitySession, nickname, err)
+	}
+	session := &models.Session{Nickname: nickname, Expires: time.Now().Add(service.auth.tokenTTL).UTC()}
+	if err = service.repository.CreateSession(ctx, tokenHash, session); err != nil {
+		return nil, err
+	}
+	session.Token = token
+
+	return session, nil
+}
+
+// Logout ends the session of token; unknown tokens are ignored.
+func (service *Service) Logout(ctx context.Context, token string) error {
+	return service.repository.DeleteSession(ctx, auth.HashToken(token))
+}
+
+// Authenticate returns the nickname of the user token was handed out to.
+func (service *Service) Authenticate(ctx context.Context, token string) (string, error) {
+	session, err := service.repository.GetSession(ctx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return "", models.Unauthorized("invalid or expired token")
+		}
+		return "", err
+	}
+	return session.Nickname, nil
+}
+
+// hashPassword replaces the plain password of user, if any, with its hash.
+func hashPassword(user *models.User) error {
+	if user.Password == "" {
+		return nil
+	}
+	hash, err := auth.HashPassword(user.Password)
+	if err != nil {
+		return models.Internal(models.EntityUser, user.Nickname, err)
+	}
+	user.Password = ""
+	user.PasswordHash = hash
+	return nil
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"technopark-forum/logging"
+	"technopark-forum/models"
+)
+
+// Publisher hands events to the streams and sockets of every server instance.
+// Events published together, like the posts of a batch, go out together.
+type Publisher interface {
+	Publish(ctx context.Context, events ...models.Event) error
+}
+
+// SetPublisher makes new threads and posts, edits and votes announce
+// themselves through publisher. Without one nothing is announced.
+func (service *Service) SetPublisher(publisher Publisher) {
+	service.publisher = publisher
+}
+
+// publish announces events. A failure only costs the clients a live update,
+// clients catch up when they reconnect, so it does not fail the request.
+func (service *Service) publish(ctx context.Context, events ...models.Event) {
+	if service.publisher == nil || len(events) == 0 {
+		return
+	}
+	if err := service.publisher.Publish(ctx, events...); err != nil {
+		logging.For(ctx, service.log).Warn().Err(err).Str("event", events[0].Type).Int("thread", events[0].Thread).
+			Int("events", len(events)).Msg("publish failed")
+	}
+}
+
+// publishEdit announces post if the update changed it.
+func (service *Service) publishEdit(ctx context.Context, post *models.Post) {
+	if post.IsEdited {
+		service.publish(ctx, postEvent(models.EventEdit, post.Author, post))
+	}
+}
+
+// publishThreadEdit announces thread if update set its title or message.
+func (service *Service) publishThreadEdit(ctx context.Context, thread *models.Thread, update *models.ThreadUpdate) {
+	if update.Title != nil || update.Message != nil {
+		service.publish(ctx, threadEvent(models.EventEdit, thread.Author, thread))
+	}
+}
+
+// publishVote announces a vote event with the voice cast, 0 for a withdrawn
+// vote.
+func (service *Service) publishVote(ctx context.Context, event models.Event, voice int) {
+	event.Vote = &models.Vote{Nickname: event.User, Voice: voice}
+	service.publish(ctx, event)
+}
+
+// postEvent is an event about post, of the thread and forum it is in and of
+// user. Events show the post as GET /post/{id}/details does, without the path.
+func postEvent(kind string, user string, post *models.Post) models.Event {
+	shown := *post
+	shown.Parents = nil
+	return models.Event{Type: kind, Forum: post.Forum, Thread: post.Thread, User: user, Post: &shown}
+}
+
+// threadEvent is an event about thread, of the thread, its forum and user.
+func threadEvent(kind string, user string, thread *models.Thread) models.Event {
+	return models.Event{Type: kind, Forum: thread.Forum, Thread: thread.ID, User: user, Details: thread}
+}
+
+// LoadEvents fills in the posts and threads of events announced by another
+// instance, which only carry their ids. The posts are loaded at once; events
+// whose post or thread is gone by now are left out.
+func (service *Service) LoadEvents(ctx context.Context, events []models.Event) ([]models.Event, error) {
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		if event.Post != nil {
+			ids = append(ids, event.Post.ID)
+		}
+	}
+	posts, err := service.repository.GetPosts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]models.Event, 0, len(events))
+	for _, event := range events {
+		if event.Post != nil {
+			post, ok := posts[event.Post.ID]
+			if !ok {
+				continue
+			}
+			event.Post = post
+		}
+		if event.Details != nil {
+			thread, err := service.repository.GetThread(ctx, strconv.Itoa(event.Details.ID))
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			event.Details = thread
+		}
+		loaded = append(loaded, event)
+	}
+	return loaded, nil
+}
+
+// GetPostsAfter returns at most limit posts of the thread created after the
+// post with id since, oldest first, for streams resuming where they left off.
+func (service *Service) GetPostsAfter(ctx context.Context, thread *models.Thread, since int, limit int) (*models.Posts, error) {
+	slugOrID := strconv.Itoa(thread.ID)
+	posts, err := service.repository.GetThreadPosts(ctx, &slugOrID, []byte(strconv.Itoa(limit)), []byte(strconv.Itoa(since)), []byte("flat"), nil)
+
+	return posts, err
+}
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+	"github.com/mailru/easyjson"
+	"io"
+	"technopark-forum/models"
+)
+
+// ExportForum writes the forum with everything in it to w as newline-delimited
+// ExportRecords. Records are written as they are read, so the forum is never
+// held in memory whole. Once records went out an error can no longer be
+// answered with a status, so a failing read ends the export with an error
+// record; a failing w ends it as is.
+func (service *Service) ExportForum(ctx context.Context, slug string, w io.Writer) error {
+	var writeErr error
+	err := service.repository.ExportForum(ctx, slug, func(record *models.ExportRecord) error {
+		if _, writeErr = easyjson.MarshalToWriter(record, w); writeErr != nil {
+			return writeErr
+		}
+		_, writeErr = io.WriteString(w, "\n")
+		return writeErr
+	})
+	if err == nil || writeErr != nil {
+		return err
+	}
+	failure := &models.ExportRecord{Type: models.ExportError, Error: err.Error()}
+	if _, writeErr := easyjson.MarshalToWriter(failure, w); writeErr == nil {
+		_, _ = io.WriteString(w, "\n")
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"technopark-forum/models"
+)
+
+// GetForumFeed returns the forum with its limit latest threads, archived ones
+// left out.
+func (service *Service) GetForumFeed(ctx context.Context, slug string, limit int) (*models.Feed, error) {
+	forum, err := service.repository.GetForum(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	threads, err := service.repository.GetForumThreads(ctx, forum.Slug, []byte(strconv.Itoa(limit)), nil, []byte("true"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(*threads))
+	for i, thread := range *threads {
+		ids[i] = thread.ID
+	}
+	edited, err := service.repository.GetThreadEdits(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Feed{Forum: forum, Threads: *threads, Edited: edited}, nil
+}
+
+// GetThreadFeed returns the thread with its limit latest posts. Deleted posts
+// are left out, so there may be fewer.
+func (service *Service) GetThreadFeed(ctx context.Context, slugOrID string, limit int) (*models.Feed, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.Itoa(thread.ID)
+	posts, err := service.repository.GetThreadPosts(ctx, &id, []byte(strconv.Itoa(limit)), nil, []byte("flat"), []byte("true"))
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &models.Feed{Thread: thread, Posts: models.Posts{}}
+	ids := make([]int, 0, len(*posts))
+	for _, post := range *posts {
+		if !post.IsDeleted {
+			post.Parents = nil
+			feed.Posts = append(feed.Posts, post)
+			ids = append(ids, post.ID)
+		}
+	}
+	if feed.Edited, err = service.repository.GetPostEdits(ctx, ids); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"technopark-forum/auth"
+	"technopark-forum/diff"
+	"technopark-forum/models"
+	"technopark-forum/policy"
+)
+
+// editor is who edits made by the request are recorded under, "" when it is
+// anonymous.
+func editor(ctx context.Context) string {
+	nickname, _ := auth.User(ctx)
+	return nickname
+}
+
+// history numbers the versions of a text from the original one. stored holds
+// what every edit replaced, together with who made the edit and when, so
+// version n+1 is the text stored by edit n+1, or current after the last edit.
+func history(original models.Revision, stored models.Revisions, current models.Revision) models.Revisions {
+	versions := make(models.Revisions, 0, len(stored)+1)
+	for i := 0; i <= len(stored); i++ {
+		version := original
+		if i > 0 {
+			version.Editor, version.Created = stored[i-1].Editor, stored[i-1].Created
+		}
+		text := current
+		if i < len(stored) {
+			text = stored[i]
+		}
+		version.Number, version.Title, version.Message = i+1, text.Title, text.Message
+		if i > 0 {
+			previous := versions[i-1]
+			version.Diff = diff.Unified("title", previous.Title, version.Title) +
+				diff.Unified("message", previous.Message, version.Message)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// findRevision returns version number of versions.
+func findRevision(versions models.Revisions, number int) (models.Revision, error) {
+	if number < 1 || number > len(versions) {
+		return models.Revision{}, models.NotFound(models.EntityRevision, strconv.Itoa(number))
+	}
+	return versions[number-1], nil
+}
+
+func (service *Service) GetPostHistory(ctx context.Context, id *string) (*models.Revisions, error) {
+	details, err := service.repository.GetPostDetails(ctx, id, []byte("thread"))
+	if err != nil {
+		return nil, err
+	}
+	post := details.PostDetails
+	if details.ThreadDetails.State == models.ThreadDeleted {
+		return nil, models.NotFound(models.EntityPost, *id)
+	}
+	if post.IsDeleted {
+		return nil, models.Conflict(models.EntityPost, *id, "post is deleted")
+	}
+
+	stored, err := service.repository.GetPostRevisions(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	versions := history(models.Revision{Editor: post.Author, Created: post.Created}, *stored, models.Revision{Message: post.Message})
+
+	return &versions, nil
+}
+
+// RevertPost makes an earlier version the current message of the post, which
+// is recorded as an edit of its own.
+func (service *Service) RevertPost(ctx context.Context, id *string, revert *models.Revert) (*models.Post, error) {
+	if err := service.authorizePost(ctx, policy.Revert, id); err != nil {
+		return nil, err
+	}
+	versions, err := service.GetPostHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	version, err := findRevision(*versions, revert.Revision)
+	if err != nil {
+		return nil, err
+	}
+	post, err := service.repository.UpdatePostDetails(ctx, id, &models.PostUpdate{Message: &version.Message, Editor: editor(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	service.publishEdit(ctx, post)
+
+	return post, nil
+}
+
+func (service *Service) GetThreadHistory(ctx context.Context, slugOrID string) (*models.Revisions, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	return service.threadHistory(ctx, thread)
+}
+
+func (service *Service) threadHistory(ctx context.Context, thread *models.Thread) (*models.Revisions, error) {
+	stored, err := service.repository.GetThreadRevisions(ctx, thread.ID)
+	if err != nil {
+		return nil, err
+	}
+	versions := history(models.Revision{Editor: thread.Author, Created: thread.Created}, *stored,
+		models.Revision{Title: thread.Title, Message: thread.Message})
+
+	return &versions, nil
+}
+
+// RevertThread makes the title and message of an earlier version current,
+// which is recorded as an edit of its own.
+func (service *Service) RevertThread(ctx context.Context, slugOrID string, revert *models.Revert) (*models.Thread, error) {
+	thread, err := service.writableThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.policy.Authorize(ctx, policy.Revert, threadSubject(slugOrID, thread)); err != nil {
+		return nil, err
+	}
+	versions, err := service.threadHistory(ctx, thread)
+	if err != nil {
+		return nil, err
+	}
+	version, err := findRevision(*versions, revert.Revision)
+	if err != nil {
+		return nil, err
+	}
+	update := &models.ThreadUpdate{Title: &version.Title, Message: &version.Message, Editor: editor(ctx)}
+	thread, err = service.repository.UpdateThread(ctx, thread.ID, update)
+	if err != nil {
+		return nil, err
+	}
+	service.publishThreadEdit(ctx, thread, update)
+
+	return thread, nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"technopark-forum/models"
+	"technopark-forum/policy"
+)
+
+// SetUserRole makes the user a site admin or a plain user again.
+func (service *Service) SetUserRole(ctx context.Context, nickname string, update *models.UserRole) (*models.UserRole, error) {
+	if err := service.policy.Authorize(ctx, policy.AssignRoles, policy.Subject{Entity: models.EntityUser, Key: nickname}); err != nil {
+		return nil, err
+	}
+	role, err := service.repository.SetUserRole(ctx, nickname, update.Role)
+
+	return role, err
+}
+
+func (service *Service) GetForumModerators(ctx context.Context, slug string) (*models.Users, error) {
+	if _, err := service.repository.GetForum(ctx, slug); err != nil {
+		return nil, err
+	}
+	moderators, err := service.repository.GetForumModerators(ctx, slug)
+
+	return moderators, err
+}
+
+// AddModerator lets the user moderate the forum and returns its moderators.
+func (service *Service) AddModerator(ctx context.Context, slug string, nickname string) (*models.Users, error) {
+	forum, err := service.authorizeModerators(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	user, err := service.repository.GetUserProfile(ctx, nickname)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.repository.AddModerator(ctx, forum.Slug, user.Nickname); err != nil {
+		return nil, err
+	}
+	moderators, err := service.repository.GetForumModerators(ctx, forum.Slug)
+
+	return moderators, err
+}
+
+// RemoveModerator dismisses the user as a moderator of the forum and returns
+// the remaining moderators.
+func (service *Service) RemoveModerator(ctx context.Context, slug string, nickname string) (*models.Users, error) {
+	forum, err := service.authorizeModerators(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.repository.RemoveModerator(ctx, forum.Slug, nickname); err != nil {
+		return nil, err
+	}
+	moderators, err := service.repository.GetForumModerators(ctx, forum.Slug)
+
+	return moderators, err
+}
+
+// authorizeModerators returns the forum if the request may appoint its
+// moderators.
+func (service *Service) authorizeModerators(ctx context.Context, slug string) (*models.Forum, error) {
+	forum, err := service.repository.GetForum(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	subject := policy.Subject{Entity: models.EntityForum, Key: slug, Owner: forum.Author, Forum: forum.Slug}
+	if err = service.policy.Authorize(ctx, policy.AppointModerators, subject); err != nil {
+		return nil, err
+	}
+	return forum, nil
+}
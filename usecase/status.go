@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"technopark-forum/models"
+	"technopark-forum/policy"
+	"time"
+)
+
+// StatusMode selects how GetStatus counts entities.
+type StatusMode string
+
+const (
+	// StatusExact counts every table on each call.
+	StatusExact StatusMode = "exact"
+	// StatusCached serves an exact count for up to the cache TTL.
+	StatusCached StatusMode = "cached"
+	// StatusEstimated reads planner statistics instead of counting.
+	StatusEstimated StatusMode = "estimated"
+)
+
+type statusCache struct {
+	mu      sync.Mutex
+	mode    StatusMode
+	ttl     time.Duration
+	status  models.Status
+	expires time.Time
+}
+
+// SetStatusMode switches GetStatus to mode; ttl only matters for StatusCached.
+func (service *Service) SetStatusMode(mode StatusMode, ttl time.Duration) {
+	service.status.mu.Lock()
+	defer service.status.mu.Unlock()
+
+	service.status.mode = mode
+	service.status.ttl = ttl
+	service.status.expires = time.Time{}
+}
+
+func (service *Service) GetStatus(ctx context.Context) (*models.Status, error) {
+	cache := &service.status
+	cache.mu.Lock()
+	mode := cache.mode
+	if mode == StatusCached && time.Now().Before(cache.expires) {
+		status := cache.status
+		cache.mu.Unlock()
+		return &status, nil
+	}
+	cache.mu.Unlock()
+
+	switch mode {
+	case StatusEstimated:
+		return service.repository.EstimateStatus(ctx)
+	case StatusCached:
+		status, err := service.repository.GetStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cache.mu.Lock()
+		cache.status = *status
+		cache.expires = time.Now().Add(cache.ttl)
+		cache.mu.Unlock()
+		return status, nil
+	default:
+		return service.repository.GetStatus(ctx)
+	}
+}
+
+func (service *Service) Clear(ctx context.Context) error {
+	if err := service.policy.Authorize(ctx, policy.Clear, policy.Subject{Entity: models.EntityForum, Key: "all"}); err != nil {
+		return err
+	}
+	err := service.repository.Clear(ctx)
+
+	service.status.mu.Lock()
+	service.status.expires = time.Time{}
+	service.status.mu.Unlock()
+	return err
+}
@@ -1,63 +1,104 @@
 package usecase
 
 import (
-	"github.com/jackc/pgx"
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"strings"
+	"technopark-forum/auth"
+	"technopark-forum/logging"
 	"technopark-forum/models"
+	"technopark-forum/policy"
 	"technopark-forum/repository"
 )
 
 type Service struct {
-	repository *repository.Storage
+	repository repository.ForumRepository
+	log        zerolog.Logger
+	status     statusCache
+	auth       authSettings
+	policy     *policy.Policy
+	publisher  Publisher
 }
 
 // service
 
-func (service *Service) GetStatus() (*models.Status, error) {
-	status, err := service.repository.GetStatus()
-
-	return status, err
-}
-func (service *Service) Clear() error {
-	err := service.repository.Clear()
-	return err
-}
-
 // user
 
-func NewForumService(repository *repository.Storage) *Service {
-	return &Service{repository: repository}
+func NewForumService(repository repository.ForumRepository, log zerolog.Logger) *Service {
+	return &Service{
+		repository: repository,
+		log:        log,
+		auth:       authSettings{tokenTTL: DefaultTokenTTL},
+		policy:     policy.New(repository),
+	}
 }
 
-func (service *Service) CreateUser(user *models.User) (*models.Users, error) {
-	users, err := service.repository.CreateUser(user)
+func (service *Service) CreateUser(ctx context.Context, user *models.User) (*models.Users, error) {
+	if user.Password == "" && !service.policy.Anonymous() {
+		return nil, models.FieldErrors{{Field: "password", Message: "is required"}}
+	}
+	if err := hashPassword(user); err != nil {
+		return nil, err
+	}
+	users, err := service.repository.CreateUser(ctx, user)
 
 	return users, err
 }
 
-func (service *Service) GetUserProfile(nickname string) (*models.User, error) {
-	user, err := service.repository.GetUserProfile(nickname)
+func (service *Service) GetUserProfile(ctx context.Context, nickname string) (*models.User, error) {
+	user, err := service.repository.GetUserProfile(ctx, nickname)
+	if err != nil {
+		return nil, err
+	}
+	reputation, err := service.repository.GetReputation(ctx, user.Nickname)
+	if err != nil {
+		return nil, err
+	}
+	user.Reputation = &reputation
 
-	return user, err
+	return user, nil
 }
 
-func (service *Service) UpdateUserProfile(oldUser *models.User) (*models.User, error) {
-	newUser, err := service.repository.UpdateUserProfile(oldUser)
+func (service *Service) UpdateUserProfile(ctx context.Context, oldUser *models.User) (*models.User, error) {
+	if err := service.policy.ActAs(ctx, oldUser.Nickname); err != nil {
+		return nil, err
+	}
+	// The legacy API lets anyone edit a profile, but a password is only ever
+	// changed by its owner or the operator, or the account would be anyone's.
+	if oldUser.Password != "" {
+		user, ok := auth.User(ctx)
+		if !ok && !auth.Operator(ctx) {
+			return nil, models.Unauthorized("sign in to change the password of " + oldUser.Nickname)
+		}
+		if ok && !strings.EqualFold(user, oldUser.Nickname) {
+			return nil, models.Forbidden(models.EntityUser, oldUser.Nickname, "signed in as "+user)
+		}
+	}
+	if err := hashPassword(oldUser); err != nil {
+		return nil, err
+	}
+	newUser, err := service.repository.UpdateUserProfile(ctx, oldUser)
 
 	return newUser, err
 }
 
 // forum
 
-func (service *Service) CreateForum(forum *models.Forum) (*models.Forum, error) {
-	user, err := service.repository.GetUserProfile(forum.Author)
+func (service *Service) CreateForum(ctx context.Context, forum *models.Forum) (*models.Forum, error) {
+	if err := service.policy.ActAs(ctx, forum.Author); err != nil {
+		return nil, err
+	}
+	user, err := service.repository.GetUserProfile(ctx, forum.Author)
 	if err != nil {
 		return nil, err
 	}
 
-	err = service.repository.CreateForum(forum)
+	err = service.repository.CreateForum(ctx, forum)
 	if err != nil {
-		if pgError, ok := err.(pgx.PgError); ok && pgError.Code == "23505" {
-			newForum, _ := service.repository.GetForum(forum.Slug)
+		if errors.Is(err, models.ErrConflict) {
+			logging.For(ctx, service.log).Debug().Str("forum", forum.Slug).Msg("forum already exists")
+			newForum, _ := service.repository.GetForum(ctx, forum.Slug)
 			return newForum, err
 		}
 		return nil, err
@@ -67,114 +108,326 @@ func (service *Service) CreateForum(forum *models.Forum) (*models.Forum, error)
 	return forum, nil
 }
 
-func (service *Service) GetForum(slug string) (*models.Forum, error) {
-	forum, err := service.repository.GetForum(slug)
+func (service *Service) GetForum(ctx context.Context, slug string) (*models.Forum, error) {
+	forum, err := service.repository.GetForum(ctx, slug)
 
 	return forum, err
 }
 
-func (service *Service) CreateThread(slug string, threadData *models.Thread) (*models.Thread, error) {
-	user, err := service.repository.GetUserProfile(threadData.Author)
+func (service *Service) CreateThread(ctx context.Context, slug string, threadData *models.Thread) (*models.Thread, error) {
+	if err := service.policy.ActAs(ctx, threadData.Author); err != nil {
+		return nil, err
+	}
+	user, err := service.repository.GetUserProfile(ctx, threadData.Author)
 	if err != nil {
-		return nil, models.UserNotFound(threadData.Author)
+		return nil, err
 	}
-	forum, err := service.repository.GetForum(slug)
+	forum, err := service.repository.GetForum(ctx, slug)
 	if err != nil {
-		return nil, models.ForumNotFound(threadData.Slug)
+		return nil, err
 	}
 	if threadData.Slug != "" {
-		threadExisting, err := service.repository.GetThread(threadData.Slug)
+		threadExisting, err := service.repository.GetThread(ctx, threadData.Slug)
 		if err == nil {
-			return threadExisting, models.Conflict
+			logging.For(ctx, service.log).Debug().Str("thread", threadData.Slug).Msg("thread already exists")
+			return threadExisting, models.Conflict(models.EntityThread, threadData.Slug, "")
+		}
+		if !errors.Is(err, models.ErrNotFound) {
+			return nil, err
 		}
 	}
 
-	thread, err := service.repository.CreateThread(user, forum, threadData)
+	thread, err := service.repository.CreateThread(ctx, user, forum, threadData)
 	if err != nil {
-		if thread != nil {
-			return thread, err
-		} else {
-			return nil, err
-		}
-		//if pgError, ok := err.(pgx.PgError); ok && pgError.Code == "23505" {
-		//	newThread, _ := service.repository.GetThread(strconv.Itoa(threadData.ID))
-		//	return newThread, models.Conflict
-		//}
-		//return nil, err
+		return thread, err
 	}
 
 	thread.Forum = forum.Slug
 	thread.Author = user.Nickname
-	//thread, err := service.repository.GetThread(strconv.Itoa(threadData.ID))
-	//if err != nil {
-	//	return nil, err
-	//}
+	service.publish(ctx, threadEvent(models.EventThread, thread.Author, thread))
 
 	return thread, nil
 }
 
-func (service *Service) GetForumUsers(slug string, limit []byte, since []byte, desc []byte) (*models.Users, error) {
-	_, err := service.GetForum(slug)
+func (service *Service) GetForumUsers(ctx context.Context, slug string, limit []byte, since []byte, desc []byte) (*models.Users, error) {
+	_, err := service.GetForum(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := service.repository.GetForumUsers(ctx, slug, limit, since, desc)
+	return users, err
+}
+
+// GetForumLeaderboard returns the limit authors of the forum with the most
+// reputation earned in it.
+func (service *Service) GetForumLeaderboard(ctx context.Context, slug string, limit int) (*models.Users, error) {
+	forum, err := service.GetForum(ctx, slug)
 	if err != nil {
-		return nil, models.ForumNotFound(slug)
+		return nil, err
 	}
 
-	users, err := service.repository.GetForumUsers(slug, limit, since, desc)
+	users, err := service.repository.GetForumLeaderboard(ctx, forum.Slug, limit)
 	return users, err
 }
 
-func (service *Service) GetForumThreads(slug string, limit []byte, since []byte, desc []byte) (*models.Threads, error) {
-	_, err := service.GetForum(slug)
+func (service *Service) GetForumThreads(ctx context.Context, slug string, limit []byte, since []byte, desc []byte, archived bool) (*models.Threads, error) {
+	_, err := service.GetForum(ctx, slug)
 	if err != nil {
-		return nil, models.ForumNotFound(slug)
+		return nil, err
 	}
 
-	thread, err := service.repository.GetForumThreads(slug, limit, since, desc)
+	thread, err := service.repository.GetForumThreads(ctx, slug, limit, since, desc, archived)
 	return thread, err
 }
 
-func (service *Service) CreatePosts(slugOrID interface{}, postsArr *models.Posts) (*models.Posts, error) {
-	posts, err := service.repository.CreatePosts(slugOrID, postsArr)
+func (service *Service) CreatePosts(ctx context.Context, slugOrID interface{}, postsArr *models.Posts) (*models.Posts, error) {
+	for _, post := range *postsArr {
+		if err := service.policy.ActAs(ctx, post.Author); err != nil {
+			return nil, err
+		}
+	}
+	posts, err := service.repository.CreatePosts(ctx, slugOrID, postsArr)
+	if err != nil || posts == nil {
+		return posts, err
+	}
+	events := make([]models.Event, len(*posts))
+	for i := range *posts {
+		post := &(*posts)[i]
+		events[i] = postEvent(models.EventPost, post.Author, post)
+	}
+	service.publish(ctx, events...)
 
-	return posts, err
+	return posts, nil
 }
 
-func (service *Service) GetThread(slugOrID interface{}) (*models.Thread, error) {
-	thread, err := service.repository.GetThread(slugOrID)
+func (service *Service) GetThread(ctx context.Context, slugOrID interface{}) (*models.Thread, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
 
 	return thread, err
 }
 
-func (service *Service) UpdateThread(slugOrID string, threadUpd *models.ThreadUpdate) (*models.Thread, error) {
-	thread, err := service.repository.GetThread(slugOrID)
+func (service *Service) UpdateThread(ctx context.Context, slugOrID string, threadUpd *models.ThreadUpdate) (*models.Thread, error) {
+	thread, err := service.writableThread(ctx, slugOrID)
 	if err != nil {
 		return nil, err
 	}
-	thread, err = service.repository.UpdateThread(thread.ID, threadUpd)
+	if err = service.policy.Authorize(ctx, policy.EditThread, threadSubject(slugOrID, thread)); err != nil {
+		return nil, err
+	}
+	threadUpd.Editor = editor(ctx)
+	thread, err = service.repository.UpdateThread(ctx, thread.ID, threadUpd)
+	if err != nil {
+		return nil, err
+	}
+	service.publishThreadEdit(ctx, thread, threadUpd)
 
-	return thread, err
+	return thread, nil
+}
+
+func (service *Service) GetThreadPosts(ctx context.Context, slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, error) {
+	posts, err := service.repository.GetThreadPosts(ctx, slugOrID, limit, since, sort, desc)
+
+	return posts, err
+}
+
+func (service *Service) PutVote(ctx context.Context, slugOrID interface{}, vote *models.Vote) (*models.Thread, error) {
+	if err := service.policy.ActAs(ctx, vote.Nickname); err != nil {
+		return nil, err
+	}
+	if _, err := service.writableThread(ctx, slugOrID.(string)); err != nil {
+		return nil, err
+	}
+	thread, err := service.repository.PutVote(ctx, slugOrID, vote)
+	if err != nil {
+		return nil, err
+	}
+	service.publishVote(ctx, threadEvent(models.EventVote, vote.Nickname, thread), vote.Voice)
+
+	return thread, nil
+}
+
+// DeleteVote withdraws the vote nickname cast on the thread; withdrawing a
+// vote that was never cast changes nothing.
+func (service *Service) DeleteVote(ctx context.Context, slugOrID string, nickname string) (*models.Thread, error) {
+	if err := service.policy.ActAs(ctx, nickname); err != nil {
+		return nil, err
+	}
+	thread, err := service.writableThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	thread, err = service.repository.DeleteVote(ctx, thread.ID, nickname)
+	if err != nil {
+		return nil, err
+	}
+	service.publishVote(ctx, threadEvent(models.EventVote, nickname, thread), 0)
+
+	return thread, nil
 }
 
-func (service *Service) GetThreadPosts(slugOrID *string, limit []byte, since []byte, sort []byte, desc []byte) (*models.Posts, int) {
-	posts, status := service.repository.GetThreadPosts(slugOrID, limit, since, sort, desc)
+func (service *Service) GetThreadVotes(ctx context.Context, slugOrID string, query *models.VotersQuery) (*models.ThreadVotes, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := service.repository.GetThreadVotes(ctx, thread.ID, *query)
+	return votes, err
+}
 
-	return posts, status
+// threadStateSources lists, for every state a thread can be moved to, the
+// states it may come from. Leaving ThreadDeleted is left to RestoreThread.
+var threadStateSources = map[string][]string{
+	models.ThreadOpen:     {models.ThreadClosed, models.ThreadArchived},
+	models.ThreadClosed:   {models.ThreadOpen},
+	models.ThreadArchived: {models.ThreadOpen, models.ThreadClosed},
+	models.ThreadDeleted:  {models.ThreadOpen, models.ThreadClosed, models.ThreadArchived},
+}
+
+func (service *Service) SetThreadState(ctx context.Context, slugOrID string, update *models.ThreadStateUpdate) (*models.Thread, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	action := policy.ModerateThread
+	if update.State == models.ThreadDeleted {
+		action = policy.Delete
+	}
+	if err = service.policy.Authorize(ctx, action, threadSubject(slugOrID, thread)); err != nil {
+		return nil, err
+	}
+	thread, err = service.repository.SetThreadState(ctx, slugOrID, threadStateSources[update.State], update.State)
+
+	return thread, err
 }
 
-func (service *Service) PutVote(slugOrID interface{}, vote *models.Vote) (*models.Thread, error) {
-	thread, err := service.repository.PutVote(slugOrID, vote)
+// RestoreThread brings a deleted thread back as an open one.
+func (service *Service) RestoreThread(ctx context.Context, slugOrID string) (*models.Thread, error) {
+	if err := service.policy.Authorize(ctx, policy.Restore, policy.Subject{Entity: models.EntityThread, Key: slugOrID}); err != nil {
+		return nil, err
+	}
+	thread, err := service.repository.SetThreadState(ctx, slugOrID, []string{models.ThreadDeleted}, models.ThreadOpen)
 
 	return thread, err
 }
 
-func (service *Service) GetPostDetails(id *string, related []byte) (*models.PostDetails, int) {
-	postDetails, status := service.repository.GetPostDetails(id, related)
+// writableThread returns the thread unless it is archived and so read-only.
+func (service *Service) writableThread(ctx context.Context, slugOrID string) (*models.Thread, error) {
+	thread, err := service.repository.GetThread(ctx, slugOrID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.State == models.ThreadArchived {
+		return nil, models.Conflict(models.EntityThread, slugOrID, "thread is archived")
+	}
+	return thread, nil
+}
+
+// threadSubject describes thread for the policy.
+func threadSubject(slugOrID string, thread *models.Thread) policy.Subject {
+	return policy.Subject{Entity: models.EntityThread, Key: slugOrID, Owner: thread.Author, Forum: thread.Forum}
+}
+
+// writablePost returns the post unless its thread is archived, treating posts
+// of deleted threads as missing.
+func (service *Service) writablePost(ctx context.Context, id *string) (*models.Post, error) {
+	details, err := service.repository.GetPostDetails(ctx, id, []byte("thread"))
+	if err != nil {
+		return nil, err
+	}
+	switch details.ThreadDetails.State {
+	case models.ThreadDeleted:
+		return nil, models.NotFound(models.EntityPost, *id)
+	case models.ThreadArchived:
+		return nil, models.Conflict(models.EntityPost, *id, "thread is archived")
+	}
+	return details.PostDetails, nil
+}
+
+// authorizePost asks the policy about changes to a writable post.
+func (service *Service) authorizePost(ctx context.Context, action policy.Action, id *string) error {
+	post, err := service.writablePost(ctx, id)
+	if err != nil {
+		return err
+	}
+	return service.policy.Authorize(ctx, action, policy.Subject{Entity: models.EntityPost, Key: *id, Owner: post.Author, Forum: post.Forum})
+}
+
+func (service *Service) GetPostDetails(ctx context.Context, id *string, related []byte) (*models.PostDetails, error) {
+	postDetails, err := service.repository.GetPostDetails(ctx, id, related)
+
+	return postDetails, err
+}
+
+func (service *Service) UpdatePostDetails(ctx context.Context, id *string, postUpd *models.PostUpdate) (*models.Post, error) {
+	if err := service.authorizePost(ctx, policy.EditPost, id); err != nil {
+		return nil, err
+	}
+	postUpd.Editor = editor(ctx)
+	post, err := service.repository.UpdatePostDetails(ctx, id, postUpd)
+	if err != nil {
+		return nil, err
+	}
+	service.publishEdit(ctx, post)
+
+	return post, nil
+}
+
+// PutPostVote records a vote on a post; voting again replaces the earlier
+// voice, as on threads.
+func (service *Service) PutPostVote(ctx context.Context, id *string, vote *models.Vote) (*models.Post, error) {
+	if err := service.policy.ActAs(ctx, vote.Nickname); err != nil {
+		return nil, err
+	}
+	if _, err := service.writablePost(ctx, id); err != nil {
+		return nil, err
+	}
+	post, err := service.repository.PutPostVote(ctx, id, vote)
+	if err != nil {
+		return nil, err
+	}
+	service.publishVote(ctx, postEvent(models.EventVote, vote.Nickname, post), vote.Voice)
+
+	return post, nil
+}
+
+func (service *Service) DeletePost(ctx context.Context, id *string) (*models.Post, error) {
+	if err := service.authorizePost(ctx, policy.Delete, id); err != nil {
+		return nil, err
+	}
+	post, err := service.repository.DeletePost(ctx, id)
 
-	return postDetails, status
+	return post, err
 }
 
-func (service *Service) UpdatePostDetails(id *string, postUpd *models.PostUpdate) (*models.Post, int) {
-	post, status := service.repository.UpdatePostDetails(id, postUpd)
+func (service *Service) RestorePost(ctx context.Context, id *string) (*models.Post, error) {
+	if err := service.authorizePost(ctx, policy.Restore, id); err != nil {
+		return nil, err
+	}
+	post, err := service.repository.RestorePost(ctx, id)
+
+	return post, err
+}
+
+func (service *Service) PurgePost(ctx context.Context, id *string) (int, error) {
+	if err := service.authorizePost(ctx, policy.Purge, id); err != nil {
+		return 0, err
+	}
+	purged, err := service.repository.PurgePost(ctx, id)
+
+	return purged, err
+}
+
+// search
+
+func (service *Service) Search(ctx context.Context, query *models.SearchQuery) (*models.SearchResults, error) {
+	if query.Forum != "" {
+		if _, err := service.GetForum(ctx, query.Forum); err != nil {
+			return nil, err
+		}
+	}
 
-	return post, status
+	results, err := service.repository.Search(ctx, *query)
+	return results, err
 }
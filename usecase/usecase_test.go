@@ -0,0 +1,558 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+	"technopark-forum/repository"
+	"testing"
+	"time"
+)
+
+// fakeRepository keeps just enough state for the business rules under test.
+// Methods that a test case does not expect to be reached panic through the
+// embedded nil interface.
+type fakeRepository struct {
+	repository.ForumRepository
+
+	users   map[string]*models.User
+	forums  map[string]*models.Forum
+	threads map[string]*models.Thread
+
+	passwords map[string]string
+	sessions  map[string]models.Session
+
+	createForumErr error
+	createdThreads int
+	statusCalls    int
+	estimateCalls  int
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		users:   map[string]*models.User{},
+		forums:  map[string]*models.Forum{},
+		threads: map[string]*models.Thread{},
+
+		passwords: map[string]string{},
+		sessions:  map[string]models.Session{},
+	}
+}
+
+func (repo *fakeRepository) GetUserProfile(_ context.Context, nickname string) (*models.User, error) {
+	user, ok := repo.users[nickname]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, nickname)
+	}
+	return user, nil
+}
+
+func (repo *fakeRepository) UpdateUserProfile(_ context.Context, user *models.User) (*models.User, error) {
+	stored, ok := repo.users[user.Nickname]
+	if !ok {
+		return nil, models.NotFound(models.EntityUser, user.Nickname)
+	}
+	if user.PasswordHash != "" {
+		repo.passwords[user.Nickname] = user.PasswordHash
+	}
+	return stored, nil
+}
+
+func (repo *fakeRepository) GetPasswordHash(_ context.Context, nickname string) (string, string, error) {
+	user, ok := repo.users[nickname]
+	if !ok {
+		return "", "", models.NotFound(models.EntityUser, nickname)
+	}
+	return user.Nickname, repo.passwords[nickname], nil
+}
+
+func (repo *fakeRepository) CreateSession(_ context.Context, tokenHash string, session *models.Session) error {
+	repo.sessions[tokenHash] = *session
+	return nil
+}
+
+func (repo *fakeRepository) GetSession(_ context.Context, tokenHash string) (*models.Session, error) {
+	session, ok := repo.sessions[tokenHash]
+	if !ok {
+		return nil, models.NotFound(models.EntitySession, "token")
+	}
+	return &session, nil
+}
+
+func (repo *fakeRepository) GetUserRole(_ context.Context, nickname string) (string, error) {
+	if _, ok := repo.users[nickname]; !ok {
+		return "", models.NotFound(models.EntityUser, nickname)
+	}
+	return models.RoleUser, nil
+}
+
+func (repo *fakeRepository) IsModerator(_ context.Context, forum string, nickname string) (bool, error) {
+	return false, nil
+}
+
+func (repo *fakeRepository) CreateForum(_ context.Context, forum *models.Forum) error {
+	if repo.createForumErr != nil {
+		return repo.createForumErr
+	}
+	repo.forums[forum.Slug] = forum
+	return nil
+}
+
+func (repo *fakeRepository) GetForum(_ context.Context, slug string) (*models.Forum, error) {
+	forum, ok := repo.forums[slug]
+	if !ok {
+		return nil, models.NotFound(models.EntityForum, slug)
+	}
+	return forum, nil
+}
+
+func (repo *fakeRepository) GetThread(_ context.Context, slugOrID interface{}) (*models.Thread, error) {
+	thread, ok := repo.threads[slugOrID.(string)]
+	if !ok {
+		return nil, models.NotFound(models.EntityThread, slugOrID.(string))
+	}
+	return thread, nil
+}
+
+func (repo *fakeRepository) CreateThread(_ context.Context, user *models.User, forum *models.Forum, thread *models.Thread) (*models.Thread, error) {
+	repo.createdThreads++
+	thread.ID = repo.createdThreads
+	return thread, nil
+}
+
+func (repo *fakeRepository) GetForumUsers(_ context.Context, slug interface{}, limit []byte, since []byte, desc []byte) (*models.Users, error) {
+	return &models.Users{}, nil
+}
+
+func (repo *fakeRepository) UpdateThread(_ context.Context, threadID int, threadUpdate *models.ThreadUpdate) (*models.Thread, error) {
+	updated := models.Thread{ID: threadID}
+	for _, thread := range repo.threads {
+		if thread.ID == threadID {
+			updated = *thread
+		}
+	}
+	if threadUpdate.Title != nil {
+		updated.Title = *threadUpdate.Title
+	}
+	if threadUpdate.Message != nil {
+		updated.Message = *threadUpdate.Message
+	}
+	return &updated, nil
+}
+
+func (repo *fakeRepository) GetStatus(_ context.Context) (*models.Status, error) {
+	repo.statusCalls++
+	return &models.Status{Forum: len(repo.forums), Thread: len(repo.threads), User: len(repo.users)}, nil
+}
+
+func (repo *fakeRepository) EstimateStatus(_ context.Context) (*models.Status, error) {
+	repo.estimateCalls++
+	return &models.Status{}, nil
+}
+
+func (repo *fakeRepository) Clear(_ context.Context) error {
+	repo.forums = map[string]*models.Forum{}
+	return nil
+}
+
+func TestCreateForum(t *testing.T) {
+	tests := []struct {
+		name       string
+		forum      models.Forum
+		createErr  error
+		wantErr    bool
+		wantForum  bool
+		wantAuthor string
+	}{
+		{
+			name:    "author not found",
+			forum:   models.Forum{Slug: "go", Author: "nobody"},
+			wantErr: true,
+		},
+		{
+			name:       "author nickname is taken from profile",
+			forum:      models.Forum{Slug: "go", Author: "gopher"},
+			wantForum:  true,
+			wantAuthor: "Gopher",
+		},
+		{
+			name:       "duplicate slug returns existing forum",
+			forum:      models.Forum{Slug: "existing", Author: "gopher"},
+			createErr:  models.Conflict(models.EntityForum, "existing", ""),
+			wantErr:    true,
+			wantForum:  true,
+			wantAuthor: "Gopher",
+		},
+		{
+			name:      "unexpected storage error",
+			forum:     models.Forum{Slug: "go", Author: "gopher"},
+			createErr: errors.New("connection refused"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.users["gopher"] = &models.User{Nickname: "Gopher"}
+			repo.forums["existing"] = &models.Forum{Slug: "existing", Author: "Gopher"}
+			repo.createForumErr = tt.createErr
+			service := NewForumService(repo, zerolog.Nop())
+
+			forum := tt.forum
+			got, err := service.CreateForum(auth.WithUser(context.Background(), forum.Author), &forum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateForum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got != nil) != tt.wantForum {
+				t.Fatalf("CreateForum() forum = %v, wantForum %v", got, tt.wantForum)
+			}
+			if got != nil && got.Author != tt.wantAuthor {
+				t.Errorf("CreateForum() author = %q, want %q", got.Author, tt.wantAuthor)
+			}
+		})
+	}
+}
+
+func TestCreateThread(t *testing.T) {
+	tests := []struct {
+		name       string
+		forumSlug  string
+		thread     models.Thread
+		signedIn   string
+		anonymous  bool
+		wantErr    error
+		wantEntity models.Entity
+		wantID     int
+		wantCreate int
+	}{
+		{
+			name:       "author not found",
+			forumSlug:  "go",
+			thread:     models.Thread{Author: "nobody"},
+			signedIn:   "nobody",
+			wantErr:    models.ErrNotFound,
+			wantEntity: models.EntityUser,
+		},
+		{
+			name:       "forum not found",
+			forumSlug:  "missing",
+			thread:     models.Thread{Author: "gopher"},
+			signedIn:   "gopher",
+			wantErr:    models.ErrNotFound,
+			wantEntity: models.EntityForum,
+		},
+		{
+			name:      "slug conflict returns existing thread",
+			forumSlug: "go",
+			thread:    models.Thread{Author: "gopher", Slug: "taken"},
+			signedIn:  "gopher",
+			wantErr:   models.ErrConflict,
+			wantID:    42,
+		},
+		{
+			name:       "created with canonical forum and author",
+			forumSlug:  "go",
+			thread:     models.Thread{Author: "gopher", Slug: "fresh"},
+			signedIn:   "Gopher",
+			wantID:     1,
+			wantCreate: 1,
+		},
+		{
+			name:      "signed in as someone else",
+			forumSlug: "go",
+			thread:    models.Thread{Author: "gopher", Slug: "fresh"},
+			signedIn:  "mallory",
+			wantErr:   models.ErrForbidden,
+		},
+		{
+			name:      "anonymous without the legacy api",
+			forumSlug: "go",
+			thread:    models.Thread{Author: "gopher", Slug: "fresh"},
+			wantErr:   models.ErrUnauthorized,
+		},
+		{
+			name:       "anonymous with the legacy api",
+			forumSlug:  "go",
+			thread:     models.Thread{Author: "gopher", Slug: "fresh"},
+			anonymous:  true,
+			wantID:     1,
+			wantCreate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.users["gopher"] = &models.User{Nickname: "Gopher"}
+			repo.forums["go"] = &models.Forum{Slug: "Go"}
+			repo.threads["taken"] = &models.Thread{ID: 42, Slug: "taken"}
+			service := NewForumService(repo, zerolog.Nop())
+			service.SetAuth(tt.anonymous, time.Hour)
+
+			ctx := context.Background()
+			if tt.signedIn != "" {
+				ctx = auth.WithUser(ctx, tt.signedIn)
+			}
+			thread := tt.thread
+			got, err := service.CreateThread(ctx, tt.forumSlug, &thread)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateThread() error = %v, want %v", err, tt.wantErr)
+				}
+				var notFound *models.NotFoundError
+				if tt.wantEntity != "" && (!errors.As(err, &notFound) || notFound.Entity != tt.wantEntity) {
+					t.Fatalf("CreateThread() error = %v, want missing %s", err, tt.wantEntity)
+				}
+			} else if err != nil {
+				t.Fatalf("CreateThread() unexpected error = %v", err)
+			}
+			if repo.createdThreads != tt.wantCreate {
+				t.Errorf("CreateThread() stored %d threads, want %d", repo.createdThreads, tt.wantCreate)
+			}
+			if tt.wantID == 0 {
+				return
+			}
+			if got == nil || got.ID != tt.wantID {
+				t.Fatalf("CreateThread() thread = %v, want id %d", got, tt.wantID)
+			}
+			if err == nil && (got.Forum != "Go" || got.Author != "Gopher") {
+				t.Errorf("CreateThread() forum/author = %q/%q, want Go/Gopher", got.Forum, got.Author)
+			}
+		})
+	}
+}
+
+func TestGetForumUsers(t *testing.T) {
+	tests := []struct {
+		name    string
+		slug    string
+		wantErr bool
+	}{
+		{name: "existing forum", slug: "go"},
+		{name: "missing forum", slug: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.forums["go"] = &models.Forum{Slug: "go"}
+			service := NewForumService(repo, zerolog.Nop())
+
+			_, err := service.GetForumUsers(context.Background(), tt.slug, nil, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetForumUsers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateThread(t *testing.T) {
+	title, message := "new title", "new message"
+	tests := []struct {
+		name     string
+		slugOrID string
+		signedIn string
+		update   models.ThreadUpdate
+		want     models.Thread
+		wantErr  bool
+	}{
+		{name: "existing thread", slugOrID: "taken", signedIn: "gopher", update: models.ThreadUpdate{Title: &title}, want: models.Thread{ID: 42, Title: title, Message: "old message"}},
+		{name: "message only", slugOrID: "taken", signedIn: "gopher", update: models.ThreadUpdate{Message: &message}, want: models.Thread{ID: 42, Title: "old title", Message: message}},
+		{name: "missing thread", slugOrID: "missing", signedIn: "gopher", wantErr: true},
+		{name: "archived thread", slugOrID: "archived", signedIn: "gopher", wantErr: true},
+		{name: "thread of someone else", slugOrID: "taken", signedIn: "mallory", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.users["gopher"] = &models.User{Nickname: "gopher"}
+			repo.users["mallory"] = &models.User{Nickname: "mallory"}
+			repo.threads["taken"] = &models.Thread{ID: 42, Slug: "taken", Author: "gopher", Title: "old title", Message: "old message", State: models.ThreadOpen}
+			repo.threads["archived"] = &models.Thread{ID: 43, Slug: "archived", Author: "gopher", State: models.ThreadArchived}
+			service := NewForumService(repo, zerolog.Nop())
+
+			update := tt.update
+			got, err := service.UpdateThread(auth.WithUser(context.Background(), tt.signedIn), tt.slugOrID, &update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateThread() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != tt.want.ID || got.Title != tt.want.Title || got.Message != tt.want.Message) {
+				t.Errorf("UpdateThread() thread = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          StatusMode
+		wantStatus    int
+		wantEstimates int
+	}{
+		{name: "exact counts every call", mode: StatusExact, wantStatus: 3},
+		{name: "cached counts once", mode: StatusCached, wantStatus: 1},
+		{name: "estimated never counts", mode: StatusEstimated, wantEstimates: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			service := NewForumService(repo, zerolog.Nop())
+			service.SetStatusMode(tt.mode, time.Hour)
+
+			for i := 0; i < 3; i++ {
+				if _, err := service.GetStatus(context.Background()); err != nil {
+					t.Fatalf("GetStatus: %s", err)
+				}
+			}
+			if repo.statusCalls != tt.wantStatus || repo.estimateCalls != tt.wantEstimates {
+				t.Errorf("counted %d times and estimated %d times, want %d and %d",
+					repo.statusCalls, repo.estimateCalls, tt.wantStatus, tt.wantEstimates)
+			}
+		})
+	}
+}
+
+func TestClearInvalidatesStatusCache(t *testing.T) {
+	repo := newFakeRepository()
+	repo.forums["pirates"] = &models.Forum{Slug: "pirates"}
+	service := NewForumService(repo, zerolog.Nop())
+	service.SetStatusMode(StatusCached, time.Hour)
+
+	if status, _ := service.GetStatus(context.Background()); status.Forum != 1 {
+		t.Fatalf("status before clear = %+v", status)
+	}
+	if err := service.Clear(context.Background()); !errors.Is(err, models.ErrUnauthorized) {
+		t.Fatalf("anonymous Clear() error = %v, want unauthorized", err)
+	}
+	if err := service.Clear(auth.WithOperator(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := service.GetStatus(context.Background()); status.Forum != 0 {
+		t.Errorf("status after clear = %+v, want a fresh count", status)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	hash, err := auth.HashPassword("treasure!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		credentials models.Credentials
+		wantErr     error
+	}{
+		{name: "valid password", credentials: models.Credentials{Nickname: "gopher", Password: "treasure!"}},
+		{name: "wrong password", credentials: models.Credentials{Nickname: "gopher", Password: "doubloons"}, wantErr: models.ErrUnauthorized},
+		{name: "user without password", credentials: models.Credentials{Nickname: "legacy", Password: ""}, wantErr: models.ErrUnauthorized},
+		{name: "unknown user", credentials: models.Credentials{Nickname: "nobody", Password: "treasure!"}, wantErr: models.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.users["gopher"] = &models.User{Nickname: "Gopher"}
+			repo.users["legacy"] = &models.User{Nickname: "legacy"}
+			repo.passwords["gopher"] = hash
+			service := NewForumService(repo, zerolog.Nop())
+
+			credentials := tt.credentials
+			session, err := service.Login(context.Background(), &credentials)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || session.Token == "" || session.Nickname != "Gopher" {
+				t.Fatalf("Login() = %+v, %v", session, err)
+			}
+
+			nickname, err := service.Authenticate(context.Background(), session.Token)
+			if err != nil || nickname != "Gopher" {
+				t.Errorf("Authenticate() = %q, %v; want Gopher", nickname, err)
+			}
+			if _, err = service.Authenticate(context.Background(), "forged"); !errors.Is(err, models.ErrUnauthorized) {
+				t.Errorf("Authenticate(forged) error = %v, want unauthorized", err)
+			}
+		})
+	}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantErr   error
+		wantSaved bool
+	}{
+		{name: "anonymous with the legacy api", ctx: context.Background(), wantErr: models.ErrUnauthorized},
+		{name: "signed in as someone else", ctx: auth.WithUser(context.Background(), "mallory"), wantErr: models.ErrForbidden},
+		{name: "signed in as the user", ctx: auth.WithUser(context.Background(), "Gopher"), wantSaved: true},
+		{name: "operator", ctx: auth.WithOperator(context.Background()), wantSaved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.users["gopher"] = &models.User{Nickname: "gopher"}
+			service := NewForumService(repo, zerolog.Nop())
+			service.SetAuth(true, time.Hour)
+
+			_, err := service.UpdateUserProfile(tt.ctx, &models.User{Nickname: "gopher", Password: "taken over"})
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("UpdateUserProfile() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, saved := repo.passwords["gopher"]; saved != tt.wantSaved {
+				t.Errorf("UpdateUserProfile() saved a password = %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+
+	// Without a password the legacy API may still edit the profile.
+	repo := newFakeRepository()
+	repo.users["gopher"] = &models.User{Nickname: "gopher"}
+	service := NewForumService(repo, zerolog.Nop())
+	service.SetAuth(true, time.Hour)
+	fullname := &models.User{Nickname: "gopher", Fullname: "Gopher"}
+	if _, err := service.UpdateUserProfile(context.Background(), fullname); err != nil {
+		t.Errorf("anonymous UpdateUserProfile() without a password error = %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	edited := created.Add(time.Hour)
+	stored := models.Revisions{
+		{Editor: "will", Created: created.Add(time.Minute), Message: "ahoy"},
+		{Editor: "jack", Created: edited, Message: "ahoy matey"},
+	}
+
+	versions := history(models.Revision{Editor: "jack", Created: created}, stored, models.Revision{Message: "avast"})
+	if len(versions) != 3 {
+		t.Fatalf("history() = %+v, want 3 versions", versions)
+	}
+	want := []models.Revision{
+		{Number: 1, Editor: "jack", Created: created, Message: "ahoy"},
+		{Number: 2, Editor: "will", Created: created.Add(time.Minute), Message: "ahoy matey",
+			Diff: "--- a/message\n+++ b/message\n@@ -1,1 +1,1 @@\n-ahoy\n+ahoy matey\n"},
+		{Number: 3, Editor: "jack", Created: edited, Message: "avast",
+			Diff: "--- a/message\n+++ b/message\n@@ -1,1 +1,1 @@\n-ahoy matey\n+avast\n"},
+	}
+	for i := range want {
+		if versions[i] != want[i] {
+			t.Errorf("version %d = %+v, want %+v", i+1, versions[i], want[i])
+		}
+	}
+
+	if _, err := findRevision(versions, 4); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("findRevision(4) error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+)
+
+// SetWebhooks makes the writes of the service queue deliveries of what they
+// did to the webhooks that take it. The repository queues them as part of
+// the write, so a stored change is never left unreported. Without it nothing
+// is queued.
+func (service *Service) SetWebhooks(enabled bool) {
+	service.repository.SetWebhooks(enabled)
+}
+
+// CreateWebhook registers hook, with a generated secret unless it brings its
+// own, and returns it with the secret.
+func (service *Service) CreateWebhook(ctx context.Context, hook *models.Webhook) (*models.Webhook, error) {
+	if hook.Secret == "" {
+		secret, _, err := auth.NewToken()
+		if err != nil {
+			return nil, models.Internal(models.EntityWebhook, hook.URL, err)
+		}
+		hook.Secret = secret
+	}
+	if err := service.repository.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (service *Service) GetWebhooks(ctx context.Context) (*models.Webhooks, error) {
+	hooks, err := service.repository.GetWebhooks(ctx)
+
+	return hooks, err
+}
+
+func (service *Service) DeleteWebhook(ctx context.Context, id string) error {
+	webhookID, err := strconv.Atoi(id)
+	if err != nil {
+		return models.NotFound(models.EntityWebhook, id)
+	}
+	return service.repository.DeleteWebhook(ctx, webhookID)
+}
+
+// GetWebhookDeliveries returns a page of the delivery log of the webhook,
+// newest first.
+func (service *Service) GetWebhookDeliveries(ctx context.Context, id string, query *models.DeliveriesQuery) (*models.WebhookDeliveries, error) {
+	webhookID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, models.NotFound(models.EntityWebhook, id)
+	}
+	deliveries, err := service.repository.GetWebhookDeliveries(ctx, webhookID, *query)
+
+	return deliveries, err
+}
@@ -0,0 +1,434 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"technopark-forum/auth"
+	"technopark-forum/models"
+	"time"
+)
+
+const (
+	MinLimit = 1
+	MaxLimit = 10000
+	// DefaultSearchLimit is the page size of GET /search without a limit.
+	DefaultSearchLimit = 20
+	// DefaultLeaderboardLimit is the length of a forum leaderboard without a limit.
+	DefaultLeaderboardLimit = 20
+	// DefaultVotersLimit is the page size of GET /thread/{slug_or_id}/votes
+	// without a limit.
+	DefaultVotersLimit = 100
+	// DefaultDeliveriesLimit is the page size of the webhook delivery log
+	// without a limit.
+	DefaultDeliveriesLimit = 100
+	// DefaultFeedLimit is how many threads or posts a feed without a limit
+	// lists.
+	DefaultFeedLimit = 30
+	// MinWebhookSecret is the length of the shortest secret a webhook may be
+	// given; generated ones are longer.
+	MinWebhookSecret = 16
+)
+
+var (
+	nicknamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	emailPattern    = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+	slugPattern     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// checker accumulates field errors so a request reports all of them at once.
+type checker struct {
+	errors models.FieldErrors
+}
+
+func (c *checker) check(ok bool, field string, message string) {
+	if !ok {
+		c.errors = append(c.errors, models.FieldError{Field: field, Message: message})
+	}
+}
+
+func (c *checker) required(value string, field string) bool {
+	ok := strings.TrimSpace(value) != ""
+	c.check(ok, field, "is required")
+	return ok
+}
+
+func (c *checker) nickname(value string, field string) {
+	if c.required(value, field) {
+		c.check(nicknamePattern.MatchString(value), field, "may only contain letters, digits, '_' and '.'")
+	}
+}
+
+func (c *checker) email(value string, field string) {
+	c.check(emailPattern.MatchString(value), field, "is not a valid email address")
+}
+
+func (c *checker) slug(value string, field string) {
+	c.check(slugPattern.MatchString(value), field, "may only contain letters, digits, '-' and '_'")
+}
+
+// password accepts an empty value, which leaves the password unset.
+func (c *checker) password(value string, field string) {
+	if value == "" {
+		return
+	}
+	c.check(len(value) >= auth.MinPasswordLength, field, fmt.Sprintf("must be at least %d characters", auth.MinPasswordLength))
+	c.check(len(value) <= auth.MaxPasswordLength, field, fmt.Sprintf("must be at most %d bytes", auth.MaxPasswordLength))
+}
+
+func (c *checker) err() error {
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return c.errors
+}
+
+// User checks a profile being created.
+func User(user *models.User) error {
+	c := new(checker)
+	c.nickname(user.Nickname, "nickname")
+	if c.required(user.Email, "email") {
+		c.email(user.Email, "email")
+	}
+	c.required(user.Fullname, "fullname")
+	c.password(user.Password, "password")
+	return c.err()
+}
+
+// UserUpdate checks a profile update, where empty fields are left unchanged.
+func UserUpdate(user *models.User) error {
+	c := new(checker)
+	c.nickname(user.Nickname, "nickname")
+	if user.Email != "" {
+		c.email(user.Email, "email")
+	}
+	c.password(user.Password, "password")
+	return c.err()
+}
+
+func UserRole(update *models.UserRole) error {
+	c := new(checker)
+	switch update.Role {
+	case models.RoleUser, models.RoleAdmin:
+	default:
+		c.check(false, "role", "must be user or admin")
+	}
+	return c.err()
+}
+
+// Credentials checks a login request.
+func Credentials(credentials *models.Credentials) error {
+	c := new(checker)
+	c.nickname(credentials.Nickname, "nickname")
+	c.required(credentials.Password, "password")
+	return c.err()
+}
+
+func Forum(forum *models.Forum) error {
+	c := new(checker)
+	c.required(forum.Title, "title")
+	c.nickname(forum.Author, "user")
+	if c.required(forum.Slug, "slug") {
+		c.slug(forum.Slug, "slug")
+	}
+	return c.err()
+}
+
+func Thread(thread *models.Thread) error {
+	c := new(checker)
+	c.required(thread.Title, "title")
+	c.nickname(thread.Author, "author")
+	c.required(thread.Message, "message")
+	if thread.Slug != "" {
+		c.slug(thread.Slug, "slug")
+	}
+	return c.err()
+}
+
+func ThreadUpdate(update *models.ThreadUpdate) error {
+	c := new(checker)
+	if update.Title != nil {
+		c.required(*update.Title, "title")
+	}
+	if update.Message != nil {
+		c.required(*update.Message, "message")
+	}
+	return c.err()
+}
+
+func ThreadState(update *models.ThreadStateUpdate) error {
+	c := new(checker)
+	switch update.State {
+	case models.ThreadOpen, models.ThreadClosed, models.ThreadArchived, models.ThreadDeleted:
+	default:
+		c.check(false, "state", "must be open, closed, archived or deleted")
+	}
+	return c.err()
+}
+
+func Revert(revert *models.Revert) error {
+	c := new(checker)
+	c.check(revert.Revision >= 1, "revision", "must be a positive number")
+	return c.err()
+}
+
+func Posts(posts models.Posts) error {
+	c := new(checker)
+	for i, post := range posts {
+		prefix := fmt.Sprintf("[%d].", i)
+		c.nickname(post.Author, prefix+"author")
+		c.required(post.Message, prefix+"message")
+		c.check(post.Parent >= 0, prefix+"parent", "must not be negative")
+	}
+	return c.err()
+}
+
+func PostUpdate(update *models.PostUpdate) error {
+	c := new(checker)
+	if update.Message != nil {
+		c.required(*update.Message, "message")
+	}
+	return c.err()
+}
+
+func Vote(vote *models.Vote) error {
+	c := new(checker)
+	c.nickname(vote.Nickname, "nickname")
+	c.check(vote.Voice == -1 || vote.Voice == 1, "voice", "must be -1 or 1")
+	return c.err()
+}
+
+// ForumUsersQuery checks the query of GET /forum/{slug}/users, paged by nickname.
+func ForumUsersQuery(limit, since, desc []byte) error {
+	c := new(checker)
+	c.page(limit, desc)
+	if len(since) > 0 {
+		c.check(nicknamePattern.Match(since), "since", "must be a nickname")
+	}
+	return c.err()
+}
+
+// VotersQuery checks the query of GET /thread/{slug_or_id}/votes, paged by
+// nickname, and returns it parsed.
+func VotersQuery(limit, since, desc []byte) (*models.VotersQuery, error) {
+	c := new(checker)
+	c.page(limit, desc)
+	query := &models.VotersQuery{Limit: DefaultVotersLimit, Since: string(since), Desc: string(desc) == "true"}
+	if len(limit) > 0 {
+		query.Limit, _ = strconv.Atoi(string(limit))
+	}
+	if len(since) > 0 {
+		c.check(nicknamePattern.Match(since), "since", "must be a nickname")
+	}
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// RetractVote checks the nickname whose vote DELETE /thread/{slug_or_id}/vote
+// withdraws.
+func RetractVote(nickname []byte) error {
+	c := new(checker)
+	c.nickname(string(nickname), "nickname")
+	return c.err()
+}
+
+// LastEventID checks the Last-Event-ID header of a resumed thread stream and
+// returns the post id in it, 0 if the header is missing.
+func LastEventID(header []byte) (int, error) {
+	c := new(checker)
+	id := 0
+	if len(header) > 0 {
+		var err error
+		id, err = strconv.Atoi(string(header))
+		c.check(err == nil && id >= 0, "Last-Event-ID", "must be a post id")
+	}
+	return id, c.err()
+}
+
+// SocketRequest checks a subscription request sent over a socket.
+func SocketRequest(request *models.SocketRequest) error {
+	c := new(checker)
+	c.check(request.Action == models.SocketSubscribe || request.Action == models.SocketUnsubscribe,
+		"action", "must be subscribe or unsubscribe")
+	c.check(request.Topic == models.TopicForum || request.Topic == models.TopicThread || request.Topic == models.TopicUser,
+		"topic", "must be forum, thread or user")
+	c.required(request.Key, "key")
+	return c.err()
+}
+
+// ForumThreadsQuery checks the query of GET /forum/{slug}/threads, paged by
+// creation time.
+func ForumThreadsQuery(limit, since, desc, archived []byte) error {
+	c := new(checker)
+	c.page(limit, desc)
+	if len(since) > 0 {
+		_, err := time.Parse(time.RFC3339, string(since))
+		c.check(err == nil, "since", "must be an RFC3339 timestamp")
+	}
+	c.boolean(archived, "archived")
+	return c.err()
+}
+
+// ThreadPostsQuery checks the query of GET /thread/{slug_or_id}/posts, paged
+// by post id.
+func ThreadPostsQuery(limit, since, sort, desc []byte) error {
+	c := new(checker)
+	c.page(limit, desc)
+	if len(since) > 0 {
+		_, err := strconv.Atoi(string(since))
+		c.check(err == nil, "since", "must be a post id")
+	}
+	switch string(sort) {
+	case "", "flat", "tree", "parent_tree":
+	default:
+		c.check(false, "sort", "must be flat, tree or parent_tree")
+	}
+	return c.err()
+}
+
+// Related checks the related parameter of GET /post/{id}/details.
+func Related(related []byte) error {
+	c := new(checker)
+	if len(related) > 0 {
+		for _, item := range strings.Split(string(related), ",") {
+			switch item {
+			case "user", "forum", "thread":
+			default:
+				c.check(false, "related", fmt.Sprintf("unknown item %q, expected user, forum or thread", item))
+			}
+		}
+	}
+	return c.err()
+}
+
+// SearchQuery checks the query of GET /search and returns it parsed.
+func SearchQuery(text, forum, author, since, limit []byte) (*models.SearchQuery, error) {
+	c := new(checker)
+	query := &models.SearchQuery{Text: string(text), Forum: string(forum), Author: string(author), Limit: DefaultSearchLimit}
+	c.required(query.Text, "q")
+	if query.Forum != "" {
+		c.slug(query.Forum, "forum")
+	}
+	if query.Author != "" {
+		c.check(nicknamePattern.MatchString(query.Author), "author", "must be a nickname")
+	}
+	if len(since) > 0 {
+		cursor, err := models.ParseSearchCursor(string(since))
+		c.check(err == nil, "since", "must be the next value of a previous page")
+		query.Since = cursor
+	}
+	if len(limit) > 0 {
+		value, err := strconv.Atoi(string(limit))
+		c.check(err == nil && value >= MinLimit && value <= MaxLimit, "limit",
+			fmt.Sprintf("must be a number between %d and %d", MinLimit, MaxLimit))
+		query.Limit = value
+	}
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// Webhook checks a webhook to be created: an http(s) URL, the events it
+// takes and, if given, its forum and secret. Users belong to no forum, so
+// only webhooks of every forum take user.created.
+func Webhook(hook *models.Webhook) error {
+	c := new(checker)
+	if c.required(hook.URL, "url") {
+		target, err := url.Parse(hook.URL)
+		c.check(err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "",
+			"url", "must be an http or https URL")
+	}
+	if hook.Forum != "" {
+		c.slug(hook.Forum, "forum")
+	}
+	c.check(len(hook.Events) > 0, "events", "is required")
+	for _, event := range hook.Events {
+		known := false
+		for _, name := range models.HookEvents {
+			known = known || event == name
+		}
+		c.check(known, "events", fmt.Sprintf("must be some of %s, not %q", strings.Join(models.HookEvents, ", "), event))
+		c.check(event != models.HookUserCreated || hook.Forum == "", "events",
+			models.HookUserCreated+" is only sent to webhooks of every forum")
+	}
+	if hook.Secret != "" {
+		c.check(len(hook.Secret) >= MinWebhookSecret, "secret",
+			fmt.Sprintf("must be at least %d characters long", MinWebhookSecret))
+	}
+	return c.err()
+}
+
+// DeliveriesQuery checks the query of GET /webhooks/{id}/deliveries and
+// returns it parsed.
+func DeliveriesQuery(limit, since, state []byte) (*models.DeliveriesQuery, error) {
+	c := new(checker)
+	c.page(limit, nil)
+	query := &models.DeliveriesQuery{Limit: DefaultDeliveriesLimit, State: string(state)}
+	if len(limit) > 0 {
+		query.Limit, _ = strconv.Atoi(string(limit))
+	}
+	if len(since) > 0 {
+		var err error
+		query.Since, err = strconv.ParseInt(string(since), 10, 64)
+		c.check(err == nil && query.Since > 0, "since", "must be a delivery id")
+	}
+	switch query.State {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		c.check(false, "state", "must be pending, delivered or failed")
+	}
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// LeaderboardQuery checks the limit of GET /forum/{slug}/leaderboard and
+// returns it, DefaultLeaderboardLimit if it is missing.
+func LeaderboardQuery(limit []byte) (int, error) {
+	c := new(checker)
+	value := DefaultLeaderboardLimit
+	if len(limit) > 0 {
+		var err error
+		value, err = strconv.Atoi(string(limit))
+		c.check(err == nil && value >= MinLimit && value <= MaxLimit, "limit",
+			fmt.Sprintf("must be a number between %d and %d", MinLimit, MaxLimit))
+	}
+	return value, c.err()
+}
+
+// FeedQuery checks the limit of the forum and thread feeds and returns it,
+// DefaultFeedLimit if it is missing.
+func FeedQuery(limit []byte) (int, error) {
+	c := new(checker)
+	value := DefaultFeedLimit
+	if len(limit) > 0 {
+		var err error
+		value, err = strconv.Atoi(string(limit))
+		c.check(err == nil && value >= MinLimit && value <= MaxLimit, "limit",
+			fmt.Sprintf("must be a number between %d and %d", MinLimit, MaxLimit))
+	}
+	return value, c.err()
+}
+
+func (c *checker) page(limit, desc []byte) {
+	if len(limit) > 0 {
+		value, err := strconv.Atoi(string(limit))
+		c.check(err == nil && value >= MinLimit && value <= MaxLimit, "limit",
+			fmt.Sprintf("must be a number between %d and %d", MinLimit, MaxLimit))
+	}
+	c.boolean(desc, "desc")
+}
+
+func (c *checker) boolean(value []byte, field string) {
+	switch string(value) {
+	case "", "true", "false":
+	default:
+		c.check(false, field, "must be true or false")
+	}
+}
@@ -0,0 +1,228 @@
+package validation
+
+import (
+	"errors"
+	"technopark-forum/models"
+	"testing"
+)
+
+func fields(err error) []string {
+	var fieldErrors models.FieldErrors
+	if !errors.As(err, &fieldErrors) {
+		return nil
+	}
+	names := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		names = append(names, fieldError.Field)
+	}
+	return names
+}
+
+func searchErr(text, forum, author, since, limit string) error {
+	_, err := SearchQuery([]byte(text), []byte(forum), []byte(author), []byte(since), []byte(limit))
+	return err
+}
+
+func leaderboardErr(limit string) error {
+	_, err := LeaderboardQuery([]byte(limit))
+	return err
+}
+
+func feedErr(limit string) error {
+	_, err := FeedQuery([]byte(limit))
+	return err
+}
+
+func votersErr(limit, since, desc string) error {
+	_, err := VotersQuery([]byte(limit), []byte(since), []byte(desc))
+	return err
+}
+
+func deliveriesErr(limit, since, state string) error {
+	_, err := DeliveriesQuery([]byte(limit), []byte(since), []byte(state))
+	return err
+}
+
+func lastEventIDErr(header string) error {
+	_, err := LastEventID([]byte(header))
+	return err
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{
+			name: "valid user",
+			err:  User(&models.User{Nickname: "j.sparrow_1", Email: "jack@sea.org", Fullname: "Jack"}),
+		},
+		{
+			name: "invalid user",
+			err:  User(&models.User{Nickname: "jack sparrow", Email: "jack", Fullname: " "}),
+			want: []string{"nickname", "email", "fullname"},
+		},
+		{
+			name: "user password too short",
+			err:  User(&models.User{Nickname: "jack", Email: "jack@sea.org", Fullname: "Jack", Password: "rum"}),
+			want: []string{"password"},
+		},
+		{
+			name: "login without password",
+			err:  Credentials(&models.Credentials{Nickname: "jack"}),
+			want: []string{"password"},
+		},
+		{
+			name: "unknown role",
+			err:  UserRole(&models.UserRole{Role: "moderator"}),
+			want: []string{"role"},
+		},
+		{
+			name: "partial user update",
+			err:  UserUpdate(&models.User{Nickname: "jack", About: "captain"}),
+		},
+		{
+			name: "forum slug with spaces",
+			err:  Forum(&models.Forum{Title: "Pirates", Author: "jack", Slug: "black pearl"}),
+			want: []string{"slug"},
+		},
+		{
+			name: "thread without slug",
+			err:  Thread(&models.Thread{Title: "t", Author: "jack", Message: "m"}),
+		},
+		{
+			name: "thread state",
+			err:  ThreadState(&models.ThreadStateUpdate{State: "locked"}),
+			want: []string{"state"},
+		},
+		{
+			name: "posts report their index",
+			err:  Posts(models.Posts{{Author: "jack", Message: "ok"}, {Author: "", Message: "", Parent: -1}}),
+			want: []string{"[1].author", "[1].message", "[1].parent"},
+		},
+		{
+			name: "vote voice",
+			err:  Vote(&models.Vote{Nickname: "jack", Voice: 42}),
+			want: []string{"voice"},
+		},
+		{
+			name: "revert without revision",
+			err:  Revert(&models.Revert{}),
+			want: []string{"revision"},
+		},
+		{
+			name: "negative limit",
+			err:  ForumUsersQuery([]byte("-1"), nil, []byte("yes")),
+			want: []string{"limit", "desc"},
+		},
+		{
+			name: "threads since must be a timestamp",
+			err:  ForumThreadsQuery([]byte("10"), []byte("yesterday"), nil, []byte("yes")),
+			want: []string{"since", "archived"},
+		},
+		{
+			name: "threads since with fractional seconds",
+			err:  ForumThreadsQuery(nil, []byte("2017-01-01T00:00:00.000Z"), []byte("true"), nil),
+		},
+		{
+			name: "posts since must be an id",
+			err:  ThreadPostsQuery([]byte("10"), []byte("abc"), []byte("nested"), nil),
+			want: []string{"since", "sort"},
+		},
+		{
+			name: "search with filters and cursor",
+			err:  searchErr("black pearl", "pirates", "j.sparrow", models.SearchCursor{Rank: 0.5, Kind: "post", ID: 7}.String(), "50"),
+		},
+		{
+			name: "search without text",
+			err:  searchErr(" ", "black pearl", "", "not-a-cursor", "0"),
+			want: []string{"q", "forum", "since", "limit"},
+		},
+		{
+			name: "leaderboard limit",
+			err:  leaderboardErr("abc"),
+			want: []string{"limit"},
+		},
+		{
+			name: "feed limit",
+			err:  feedErr("0"),
+			want: []string{"limit"},
+		},
+		{
+			name: "voters page",
+			err:  votersErr("10", "j.sparrow", "true"),
+		},
+		{
+			name: "voters since must be a nickname",
+			err:  votersErr("0", "jack sparrow", "yes"),
+			want: []string{"limit", "desc", "since"},
+		},
+		{
+			name: "retract vote without nickname",
+			err:  RetractVote(nil),
+			want: []string{"nickname"},
+		},
+		{
+			name: "last event id",
+			err:  lastEventIDErr("post-7"),
+			want: []string{"Last-Event-ID"},
+		},
+		{
+			name: "socket subscription",
+			err:  SocketRequest(&models.SocketRequest{Action: models.SocketSubscribe, Topic: models.TopicThread, Key: "42"}),
+		},
+		{
+			name: "socket request",
+			err:  SocketRequest(&models.SocketRequest{Action: "follow", Topic: "post"}),
+			want: []string{"action", "topic", "key"},
+		},
+		{
+			name: "webhook of a forum",
+			err:  Webhook(&models.Webhook{URL: "https://ci.example.org/hooks", Forum: "pirates", Events: []string{models.HookPostCreated}}),
+		},
+		{
+			name: "webhook without target",
+			err:  Webhook(&models.Webhook{URL: "ftp://ci.example.org", Forum: "black pearl", Secret: "short"}),
+			want: []string{"url", "forum", "events", "secret"},
+		},
+		{
+			name: "user events only for global webhooks",
+			err:  Webhook(&models.Webhook{URL: "http://localhost:8080", Forum: "pirates", Events: []string{models.HookUserCreated, "post.deleted"}}),
+			want: []string{"events", "events"},
+		},
+		{
+			name: "delivery log page",
+			err:  deliveriesErr("0", "x", "lost"),
+			want: []string{"limit", "since", "state"},
+		},
+		{
+			name: "related items",
+			err:  Related([]byte("user,votes")),
+			want: []string{"related"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fields(tt.err)
+			if tt.want == nil {
+				if tt.err != nil {
+					t.Fatalf("unexpected error: %s", tt.err)
+				}
+				return
+			}
+			if !errors.Is(tt.err, models.ErrValidation) {
+				t.Fatalf("error %v does not match ErrValidation", tt.err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fields = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("fields = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package webhooks delivers the events queued in the webhook outbox as signed
+// HTTP POSTs, retrying failed deliveries with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"technopark-forum/models"
+	"time"
+)
+
+const (
+	DefaultInterval    = time.Second
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 8
+
+	// batch is how many deliveries a dispatcher claims at once.
+	batch = 16
+	// firstRetry and lastRetry bound the backoff between attempts.
+	firstRetry = 10 * time.Second
+	lastRetry  = time.Hour
+)
+
+// Headers every delivery carries. The signature is "sha256=" and the hex
+// HMAC-SHA256, keyed by the webhook secret, of the timestamp, a dot and the
+// body, so receivers can also turn away replays of old deliveries.
+const (
+	HeaderEvent     = "X-Forum-Event"
+	HeaderDelivery  = "X-Forum-Delivery"
+	HeaderTimestamp = "X-Forum-Timestamp"
+	HeaderSignature = "X-Forum-Signature"
+)
+
+// Outbox is the storage deliveries are claimed from and reported to.
+type Outbox interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error)
+	FinishWebhookAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt) error
+}
+
+// Dispatcher attempts the deliveries that are due. Several instances may
+// dispatch from one database: a claimed delivery is left alone by the others
+// until its attempt had time to finish.
+type Dispatcher struct {
+	outbox      Outbox
+	client      *http.Client
+	maxAttempts int
+	log         zerolog.Logger
+}
+
+// NewDispatcher gives every attempt timeout to get an answer and gives up on
+// a delivery after maxAttempts failed ones.
+func NewDispatcher(outbox Outbox, timeout time.Duration, maxAttempts int, log zerolog.Logger) *Dispatcher {
+	return &Dispatcher{
+		outbox:      outbox,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+		log:         log,
+	}
+}
+
+// Run attempts the due deliveries every interval until ctx is done.
+func (dispatcher *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A full batch suggests more are due, so those go out without waiting.
+		for {
+			attempted, err := dispatcher.DeliverDue(ctx)
+			if err != nil && ctx.Err() == nil {
+				dispatcher.log.Error().Err(err).Msg("webhook dispatch failed")
+			}
+			if err != nil || attempted < batch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims one batch of due deliveries, attempts them concurrently
+// and returns how many it attempted.
+func (dispatcher *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	// The lease outlasts the attempt, so a delivery is only claimed again if
+	// this instance died before recording the outcome.
+	lease := 2*dispatcher.client.Timeout + time.Minute
+	deliveries, err := dispatcher.outbox.ClaimWebhookDeliveries(ctx, batch, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery models.PendingDelivery) {
+			defer wg.Done()
+			attempt := dispatcher.attempt(ctx, delivery)
+			if ctx.Err() != nil {
+				// Cut short by shutdown: the delivery is claimed again once
+				// the lease runs out, without counting this attempt.
+				return
+			}
+			if err := dispatcher.outbox.FinishWebhookAttempt(ctx, delivery.ID, attempt); err != nil {
+				dispatcher.log.Error().Err(err).Int64("delivery", delivery.ID).Msg("recording webhook attempt failed")
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// attempt POSTs delivery and decides what becomes of it.
+func (dispatcher *Dispatcher) attempt(ctx context.Context, delivery models.PendingDelivery) models.DeliveryAttempt {
+	status, err := dispatcher.post(ctx, delivery, time.Now())
+	if err == nil {
+		return models.DeliveryAttempt{State: models.DeliveryDelivered, Status: status}
+	}
+
+	attempt := models.DeliveryAttempt{State: models.DeliveryPending, Status: status, Error: err.Error()}
+	attempts := delivery.Attempts + 1
+	if attempts >= dispatcher.maxAttempts {
+		attempt.State = models.DeliveryFailed
+	} else {
+		attempt.Next = time.Now().Add(Backoff(attempts)).UTC()
+	}
+	dispatcher.log.Warn().Err(err).Int64("delivery", delivery.ID).Int("webhook", delivery.Webhook).
+		Int("attempts", attempts).Str("state", attempt.State).Msg("webhook delivery failed")
+	return attempt
+}
+
+// post sends delivery and returns the status it was answered with. Anything
+// but a 2xx answer is an error.
+func (dispatcher *Dispatcher) post(ctx context.Context, delivery models.PendingDelivery, now time.Time) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "technopark-forum-webhooks")
+	request.Header.Set(HeaderEvent, delivery.Event)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	// Draining the body lets the connection be reused.
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// Sign returns the X-Forum-Signature of body sent at timestamp.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait after the attempts-th failed attempt: 10s,
+// doubling every time, but never over an hour.
+func Backoff(attempts int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < attempts && wait < lastRetry; i++ {
+		wait *= 2
+	}
+	if wait > lastRetry {
+		return lastRetry
+	}
+	return wait
+}
@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"technopark-forum/models"
+	"technopark-forum/repository"
+	"testing"
+	"time"
+)
+
+// outbox hands out its deliveries once and records the attempts reported.
+type outbox struct {
+	mu       sync.Mutex
+	pending  []models.PendingDelivery
+	attempts map[int64]models.DeliveryAttempt
+}
+
+func (outbox *outbox) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingDelivery, error) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	claimed := outbox.pending
+	outbox.pending = nil
+	return claimed, nil
+}
+
+func (outbox *outbox) FinishWebhookAttempt(ctx context.Context, id int64, attempt models.DeliveryAttempt) error {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	outbox.attempts[id] = attempt
+	return nil
+}
+
+// receiver answers every POST with status and records the request.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (receiver *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.requests = append(receiver.requests, r)
+	receiver.bodies = append(receiver.bodies, body)
+	w.WriteHeader(receiver.status)
+}
+
+func TestDispatcher(t *testing.T) {
+	hook := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	delivery := func(id int64, attempts int) models.PendingDelivery {
+		return models.PendingDelivery{ID: id, Webhook: 1, URL: server.URL, Secret: "0123456789abcdef",
+			Event: models.HookPostCreated, Payload: []byte(`{"event":"post.created"}`), Attempts: attempts}
+	}
+	outbox := &outbox{attempts: make(map[int64]models.DeliveryAttempt)}
+	dispatcher := NewDispatcher(outbox, time.Second, 3, zerolog.Nop())
+
+	outbox.pending = []models.PendingDelivery{delivery(1, 0), delivery(2, 2)}
+	before := time.Now()
+	if attempted, err := dispatcher.DeliverDue(context.Background()); err != nil || attempted != 2 {
+		t.Fatalf("DeliverDue = %d, %v; want 2 attempted", attempted, err)
+	}
+	retry := outbox.attempts[1]
+	if retry.State != models.DeliveryPending || retry.Status != http.StatusInternalServerError || retry.Error == "" ||
+		retry.Next.Before(before.Add(firstRetry)) || retry.Next.After(time.Now().Add(firstRetry)) {
+		t.Errorf("first failed attempt = %+v; want pending, retried in %s", retry, firstRetry)
+	}
+	if last := outbox.attempts[2]; last.State != models.DeliveryFailed || !last.Next.IsZero() {
+		t.Errorf("last failed attempt = %+v; want failed", last)
+	}
+
+	hook.status = http.StatusNoContent
+	outbox.pending = []models.PendingDelivery{delivery(1, 1)}
+	if _, err := dispatcher.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if done := outbox.attempts[1]; done.State != models.DeliveryDelivered || done.Status != http.StatusNoContent || done.Error != "" {
+		t.Errorf("successful attempt = %+v; want delivered", done)
+	}
+
+	request, body := hook.requests[2], hook.bodies[2]
+	timestamp := request.Header.Get(HeaderTimestamp)
+	if request.Method != http.MethodPost || request.Header.Get(HeaderEvent) != models.HookPostCreated ||
+		request.Header.Get(HeaderDelivery) != "1" || string(body) != `{"event":"post.created"}` {
+		t.Errorf("delivered %s with %v and body %s", request.Method, request.Header, body)
+	}
+	if got := request.Header.Get(HeaderSignature); got != Sign("0123456789abcdef", timestamp, body) {
+		t.Errorf("signature = %s, want the HMAC of the timestamp and body", got)
+	}
+}
+
+func TestDispatcherOutbox(t *testing.T) {
+	hook := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	storage := repository.NewMemoryStorage()
+	ctx := context.Background()
+	webhook := &models.Webhook{URL: server.URL, Events: []string{models.HookUserCreated}, Secret: "0123456789abcdef"}
+	if err := storage.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+	event := models.WebhookEvent{Event: models.HookUserCreated, User: &models.User{Nickname: "alice"}}
+	if err := storage.EnqueueWebhookEvents(ctx, []models.WebhookEvent{event}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher(storage, time.Second, DefaultMaxAttempts, zerolog.Nop())
+	if attempted, err := dispatcher.DeliverDue(ctx); err != nil || attempted != 1 {
+		t.Fatalf("DeliverDue = %d, %v; want 1 attempted", attempted, err)
+	}
+	if attempted, err := dispatcher.DeliverDue(ctx); err != nil || attempted != 0 {
+		t.Errorf("DeliverDue again = %d, %v; want none due", attempted, err)
+	}
+
+	log, err := storage.GetWebhookDeliveries(ctx, webhook.ID, models.DeliveriesQuery{Limit: 10})
+	if err != nil || len(*log) != 1 || (*log)[0].State != models.DeliveryDelivered || (*log)[0].Status != http.StatusOK || (*log)[0].Attempts != 1 {
+		t.Fatalf("GetWebhookDeliveries = %+v, %v; want one delivered", log, err)
+	}
+	if len(hook.bodies) != 1 || string(hook.bodies[0]) != string((*log)[0].Payload) {
+		t.Errorf("received %q, want the logged payload %s", hook.bodies, (*log)[0].Payload)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 9: 2560 * time.Second, 10: time.Hour, 50: time.Hour}
+	for attempts, wait := range want {
+		if got := Backoff(attempts); got != wait {
+			t.Errorf("Backoff(%d) = %s, want %s", attempts, got, wait)
+		}
+	}
+}